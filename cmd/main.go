@@ -8,6 +8,7 @@
 package main
 
 import (
+	"context"
 	"log"
 	"net/http"
 
@@ -35,6 +36,13 @@ func main() {
 
 	r := router.SetupRouter(db, ghService, tfService)
 
+	// Incidents are managed by another service, so besides the checks made
+	// on zone changes every safe zone is re-checked on a timer.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	safeZoneService := services.NewSafeZoneService(db.DB, router.NewDisasterZoneService(db))
+	go safeZoneService.RefreshCompromisedEvery(ctx, services.CompromisedRefreshInterval)
+
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
 	log.Println("Server running on port ", config.MAP_MGMT_APP_PORT)
@@ -23,6 +23,9 @@ var (
 	GRAPHHOPPER_URL   string
 	TOMTOM_API_KEY    string
 	TOMTOM_URL        string
+	// ACTIVE_ZONE_STATUSES is an optional comma-separated list of the
+	// incident status IDs whose zones are avoided, "3" when unset.
+	ACTIVE_ZONE_STATUSES string
 )
 
 func LoadConfig() {
@@ -46,6 +49,7 @@ func LoadConfig() {
 			TOMTOM_API_KEY = getString(vaultSecrets, "TOMTOM_API_KEY", os.Getenv("TOMTOM_API_KEY"))
 			GRAPHHOPPER_URL = getString(vaultSecrets, "GRAPHHOPPER_URL", os.Getenv("GRAPHHOPPER_URL"))
 			TOMTOM_URL = getString(vaultSecrets, "TOMTOM_URL", os.Getenv("TOMTOM_URL"))
+			ACTIVE_ZONE_STATUSES = getString(vaultSecrets, "ACTIVE_ZONE_STATUSES", os.Getenv("ACTIVE_ZONE_STATUSES"))
 		}
 		log.Printf("DEBUG - All vault secrets : %v", vaultSecrets)
 	} else {
@@ -88,6 +92,9 @@ func LoadConfig() {
 	if TOMTOM_URL == "" {
 		TOMTOM_URL = os.Getenv("TOMTOM_URL")
 	}
+	if ACTIVE_ZONE_STATUSES == "" {
+		ACTIVE_ZONE_STATUSES = os.Getenv("ACTIVE_ZONE_STATUSES")
+	}
 	if MAP_MGMT_DB_HOST == "" || MAP_MGMT_DB_PASS == "" || MAP_MGMT_DB_NAME == "" || MAP_MGMT_DB_PORT == "" || MAP_MGMT_DB_USER == "" || JWT_SECRET == "" || GRAPHHOPPER_KEY == "" || TOMTOM_API_KEY == "" || GRAPHHOPPER_URL == "" || TOMTOM_URL == "" {
 		log.Fatal("Missing environment variables")
 	}
@@ -7,8 +7,11 @@
 package handlers
 
 import (
+	"disaster-response-map-api/internal/models"
 	"disaster-response-map-api/internal/services"
+	"errors"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 )
@@ -27,17 +30,321 @@ func NewDisasterZoneHandler(dzService services.DisasterZoneServiceInterface) *Di
 
 // GetDisasterZones godoc
 // @Summary      Retrieve Disaster Zones
-// @Description  Retrieves a list of disaster zones from the database.
+// @Description  Retrieves a list of disaster zones from the database, optionally filtered. Spatial filters apply to the zone outline, or to the disc of its radius plus buffer when it has none.
 // @Tags         DisasterZone
-// @Produce      json
-// @Success      200  {array}   DisasterZone
+// @Produce      json,application/geo+json,application/gpx+xml,application/vnd.google-earth.kml+xml
+// @Param        bbox          query     string  false  "Bounding box as minLon,minLat,maxLon,maxLat"  example("-6.3,53.3,-6.2,53.4")
+// @Param        near          query     string  false  "Point as lat,lon; requires within"  example("53.349805,-6.26031")
+// @Param        within        query     number  false  "Distance from near in metres"  example(1000)
+// @Param        type          query     int     false  "Incident type ID"
+// @Param        min_severity  query     int     false  "Minimum severity ID (also accepted as severity>=N)"
+// @Param        status        query     int     false  "Status ID"
+// @Param        as_of         query     string  false  "Return the zones as they were at this RFC3339 time"  example("2025-04-11T10:00:00Z")
+// @Param        format  query     string  false  "Response format: json, geojson, gpx or kml"
+// @Success      200  {array}   models.DisasterZone
+// @Failure      400  {object}  map[string]string  "Invalid filter"
 // @Failure      500  {object}  map[string]string  "Internal Server Error"
 // @Router       /zones [get]
 func (h *DisasterZoneHandler) GetDisasterZones(c *gin.Context) {
-	zones, err := h.DZService.GetDisasterZones()
+	filter, err := parseDisasterZoneFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	zones, err := h.DZService.GetDisasterZones(filter)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch disaster zones"})
 		return
 	}
-	c.JSON(http.StatusOK, zones)
+	respond(c, "zones", zones, func() services.FeatureCollection {
+		return services.DisasterZonesToFeatureCollection(zones)
+	})
+}
+
+// GetDisasterZone godoc
+// @Summary      Retrieve a Disaster Zone
+// @Description  Retrieves a single disaster zone by its incident ID.
+// @Tags         DisasterZone
+// @Produce      json,application/geo+json,application/gpx+xml,application/vnd.google-earth.kml+xml
+// @Param        id   path      int  true  "Incident ID"
+// @Param        format  query     string  false  "Response format: json, geojson, gpx or kml"
+// @Success      200  {object}  models.DisasterZone
+// @Failure      400  {object}  map[string]string  "Invalid zone ID"
+// @Failure      404  {object}  map[string]string  "Disaster zone not found"
+// @Failure      500  {object}  map[string]string  "Internal Server Error"
+// @Router       /zones/{id} [get]
+func (h *DisasterZoneHandler) GetDisasterZone(c *gin.Context) {
+	id, ok := parseZoneID(c)
+	if !ok {
+		return
+	}
+
+	zone, err := h.DZService.GetDisasterZone(id)
+	if errors.Is(err, services.ErrDisasterZoneNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Disaster zone not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch disaster zone"})
+		return
+	}
+	respond(c, "zone", zone, func() services.FeatureCollection {
+		return services.DisasterZonesToFeatureCollection([]models.DisasterZone{zone})
+	})
+}
+
+// SetZoneGeometry godoc
+// @Summary      Set Disaster Zone Geometry
+// @Description  Stores a GeoJSON Polygon or MultiPolygon (holes allowed) as the outline of a disaster zone. The outline replaces the severity-based circle when routing.
+// @Tags         DisasterZone
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id        path      int                  true  "Incident ID"
+// @Param        geometry  body      models.ZoneGeometry  true  "GeoJSON Polygon or MultiPolygon"
+// @Success      200  {object}  models.DisasterZone
+// @Failure      400  {object}  map[string]string  "Invalid geometry"
+// @Failure      404  {object}  map[string]string  "Disaster zone not found"
+// @Failure      401  {object}  map[string]string  "Unauthorized"
+// @Failure      500  {object}  map[string]string  "Internal Server Error"
+// @Router       /zones/{id}/geometry [put]
+func (h *DisasterZoneHandler) SetZoneGeometry(c *gin.Context) {
+	id, ok := parseZoneID(c)
+	if !ok {
+		return
+	}
+
+	var geometry models.ZoneGeometry
+	if err := c.ShouldBindJSON(&geometry); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payload"})
+		return
+	}
+
+	if err := h.DZService.SetZoneGeometry(id, geometry); err != nil {
+		writeZoneError(c, err, "Failed to store zone geometry")
+		return
+	}
+
+	h.GetDisasterZone(c)
+}
+
+// ClearZoneGeometry godoc
+// @Summary      Remove Disaster Zone Geometry
+// @Description  Removes the drawn outline of a disaster zone so it is treated as a circle again.
+// @Tags         DisasterZone
+// @Security     BearerAuth
+// @Param        id   path  int  true  "Incident ID"
+// @Success      204
+// @Failure      404  {object}  map[string]string  "Disaster zone geometry not found"
+// @Failure      401  {object}  map[string]string  "Unauthorized"
+// @Failure      500  {object}  map[string]string  "Internal Server Error"
+// @Router       /zones/{id}/geometry [delete]
+func (h *DisasterZoneHandler) ClearZoneGeometry(c *gin.Context) {
+	id, ok := parseZoneID(c)
+	if !ok {
+		return
+	}
+
+	err := h.DZService.ClearZoneGeometry(id)
+	if errors.Is(err, services.ErrDisasterZoneNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Disaster zone geometry not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete zone geometry"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// GetDisasterZoneHistory godoc
+// @Summary      Retrieve Disaster Zone History
+// @Description  Lists every recorded version of a disaster zone, oldest first, with the period each version was valid for.
+// @Tags         DisasterZone
+// @Produce      json
+// @Param        id   path      int  true  "Zone ID"
+// @Success      200  {array}   models.DisasterZoneVersion
+// @Failure      400  {object}  map[string]string  "Invalid zone ID"
+// @Failure      404  {object}  map[string]string  "Disaster zone not found"
+// @Failure      500  {object}  map[string]string  "Internal Server Error"
+// @Router       /zones/{id}/history [get]
+func (h *DisasterZoneHandler) GetDisasterZoneHistory(c *gin.Context) {
+	id, ok := parseZoneID(c)
+	if !ok {
+		return
+	}
+
+	versions, err := h.DZService.GetDisasterZoneHistory(id)
+	if err != nil {
+		writeZoneError(c, err, "Failed to fetch disaster zone history")
+		return
+	}
+	c.JSON(http.StatusOK, versions)
+}
+
+// CreateDisasterZone godoc
+// @Summary      Create Disaster Zone
+// @Description  Creates a hazard area drawn on the map that is not tied to an incident report. Either latitude/longitude or a geometry is required; new zones take the first active status unless status_id says otherwise, and cannot start in the final status. Routing picks the zone up immediately.
+// @Tags         DisasterZone
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        zone  body      models.DisasterZoneCreate  true  "Disaster zone"
+// @Success      201   {object}  models.DisasterZone
+// @Failure      400   {object}  map[string]string  "Invalid disaster zone"
+// @Failure      401   {object}  map[string]string  "Unauthorized"
+// @Failure      500   {object}  map[string]string  "Internal Server Error"
+// @Router       /zones [post]
+func (h *DisasterZoneHandler) CreateDisasterZone(c *gin.Context) {
+	var req models.DisasterZoneCreate
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payload"})
+		return
+	}
+
+	zone, err := h.DZService.CreateDisasterZone(req)
+	if err != nil {
+		writeZoneError(c, err, "Failed to create disaster zone")
+		return
+	}
+	c.JSON(http.StatusCreated, zone)
+}
+
+// ReplaceDisasterZone godoc
+// @Summary      Replace Disaster Zone
+// @Description  Replaces every field of a manually drawn zone. Zones backed by an incident report cannot be changed here. Status may only move forward through the incident statuses in status_id order, and the last is final.
+// @Tags         DisasterZone
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id    path      int                        true  "Zone ID"
+// @Param        zone  body      models.DisasterZoneCreate  true  "Disaster zone"
+// @Success      200   {object}  models.DisasterZone
+// @Failure      400   {object}  map[string]string  "Invalid disaster zone"
+// @Failure      404   {object}  map[string]string  "Disaster zone not found"
+// @Failure      409   {object}  map[string]string  "Zone not editable or invalid status transition"
+// @Failure      401   {object}  map[string]string  "Unauthorized"
+// @Failure      500   {object}  map[string]string  "Internal Server Error"
+// @Router       /zones/{id} [put]
+func (h *DisasterZoneHandler) ReplaceDisasterZone(c *gin.Context) {
+	id, ok := parseZoneID(c)
+	if !ok {
+		return
+	}
+
+	var req models.DisasterZoneCreate
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payload"})
+		return
+	}
+
+	zone, err := h.DZService.ReplaceDisasterZone(id, req)
+	if err != nil {
+		writeZoneError(c, err, "Failed to update disaster zone")
+		return
+	}
+	c.JSON(http.StatusOK, zone)
+}
+
+// PatchDisasterZone godoc
+// @Summary      Update Disaster Zone
+// @Description  Changes the given fields of a manually drawn zone, e.g. to move it to another status.
+// @Tags         DisasterZone
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id     path      int                       true  "Zone ID"
+// @Param        patch  body      models.DisasterZonePatch  true  "Fields to change"
+// @Success      200    {object}  models.DisasterZone
+// @Failure      400    {object}  map[string]string  "Invalid disaster zone"
+// @Failure      404    {object}  map[string]string  "Disaster zone not found"
+// @Failure      409    {object}  map[string]string  "Zone not editable or invalid status transition"
+// @Failure      401    {object}  map[string]string  "Unauthorized"
+// @Failure      500    {object}  map[string]string  "Internal Server Error"
+// @Router       /zones/{id} [patch]
+func (h *DisasterZoneHandler) PatchDisasterZone(c *gin.Context) {
+	id, ok := parseZoneID(c)
+	if !ok {
+		return
+	}
+
+	var req models.DisasterZonePatch
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payload"})
+		return
+	}
+
+	zone, err := h.DZService.PatchDisasterZone(id, req)
+	if err != nil {
+		writeZoneError(c, err, "Failed to update disaster zone")
+		return
+	}
+	c.JSON(http.StatusOK, zone)
+}
+
+// DeleteDisasterZone godoc
+// @Summary      Delete Disaster Zone
+// @Description  Deletes a manually drawn zone. Zones backed by an incident report cannot be deleted here.
+// @Tags         DisasterZone
+// @Security     BearerAuth
+// @Param        id   path  int  true  "Zone ID"
+// @Success      204
+// @Failure      404  {object}  map[string]string  "Disaster zone not found"
+// @Failure      409  {object}  map[string]string  "Zone not editable"
+// @Failure      401  {object}  map[string]string  "Unauthorized"
+// @Failure      500  {object}  map[string]string  "Internal Server Error"
+// @Router       /zones/{id} [delete]
+func (h *DisasterZoneHandler) DeleteDisasterZone(c *gin.Context) {
+	id, ok := parseZoneID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.DZService.DeleteDisasterZone(id); err != nil {
+		writeZoneError(c, err, "Failed to delete disaster zone")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// writeZoneError maps disaster zone service errors to HTTP responses.
+func writeZoneError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, services.ErrInvalidDisasterZone), errors.Is(err, services.ErrInvalidGeometry):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrDisasterZoneNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Disaster zone not found"})
+	case errors.Is(err, services.ErrZoneNotEditable), errors.Is(err, services.ErrInvalidStatusTransition):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
+
+// parseZoneID reads the :id path parameter, writing a 400 response if it is not an integer.
+func parseZoneID(c *gin.Context) (int, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid zone ID"})
+		return 0, false
+	}
+	return id, true
+}
+
+// GetIncidentStatuses godoc
+// @Summary      List Incident Statuses
+// @Description  Lists the incident statuses and which of them make a zone active for routing and evacuation by default.
+// @Tags         DisasterZone
+// @Produce      json
+// @Success      200  {array}   models.IncidentStatus
+// @Failure      500  {object}  map[string]string
+// @Router       /incident-statuses [get]
+func (h *DisasterZoneHandler) GetIncidentStatuses(c *gin.Context) {
+	statuses, err := h.DZService.GetIncidentStatuses()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch incident statuses"})
+		return
+	}
+	c.JSON(http.StatusOK, statuses)
 }
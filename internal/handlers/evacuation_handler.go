@@ -7,15 +7,25 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
+	"strconv"
 
+	"disaster-response-map-api/internal/models"
 	"disaster-response-map-api/internal/services"
+	"disaster-response-map-api/pkg/middleware"
 
 	"github.com/gin-gonic/gin"
 )
 
 type EvacuationServiceInterface interface {
-	GetEvacuationRoute(dangerPoint [2]float64, incidentTypeID int, safePoint *[2]float64) (services.EvacuationRouteResponse, error)
+	GetEvacuationRoute(dangerPoint [2]float64, incidentTypeID int, safePoint *[2]float64, opts services.EvacuationOptions) (services.EvacuationRouteResponse, error)
+	PlanEvacuation(origins []services.PlanOrigin, incidentTypeID int, opts services.EvacuationOptions) (services.EvacuationPlan, error)
+	SaveEvacuation(evacuation models.Evacuation) (models.Evacuation, error)
+	GetEvacuation(evacuationID int) (models.Evacuation, error)
+	GetEvacuations(filter services.EvacuationFilter) ([]models.Evacuation, error)
+	SetEvacuationStatus(evacuationID int, status string) (models.Evacuation, error)
+	GetIsochrone(point [2]float64, minutes, bands int, mode services.TravelMode) (services.FeatureCollection, error)
 }
 
 type EvacuationHandler struct {
@@ -36,17 +46,46 @@ type EvacuationRequest struct {
 	DangerPoint    [2]float64  `json:"danger_point" example:"[53.349805, -6.26031]"`
 	IncidentTypeID int         `json:"incident_type_id" example:"3"`
 	SafePoint      *[2]float64 `json:"safe_point,omitempty" example:"[53.3440, -6.2670]"`
+	// Needs lists facilities the safe zone must offer: medical, wheelchair,
+	// pets, water, power or bed.
+	Needs []string `json:"needs,omitempty" example:"wheelchair,pets"`
+	// Mode is how the evacuee travels: foot (the default), car, bike,
+	// wheelchair or bus.
+	Mode string `json:"mode,omitempty" example:"wheelchair"`
+	// Save keeps the route so it can be reviewed and tracked under
+	// /evacuations; the response then carries its evacuation_id. Saving
+	// needs a Bearer JWT, whose subject is kept as requested_by.
+	Save bool `json:"save,omitempty" example:"true"`
+	// IncidentID is the disaster zone being evacuated, kept with a saved
+	// route. It defaults to the zone the danger point lies in.
+	IncidentID *int `json:"incident_id,omitempty" example:"42"`
+}
+
+// evacuationOptions reads the needs and travel mode of an evacuation request.
+func evacuationOptions(needValues []string, modeValue string) (services.EvacuationOptions, error) {
+	needs, err := services.ParseNeeds(needValues)
+	if err != nil {
+		return services.EvacuationOptions{}, err
+	}
+	mode, err := services.ParseTravelMode(modeValue)
+	if err != nil {
+		return services.EvacuationOptions{}, err
+	}
+	return services.EvacuationOptions{Needs: needs, Mode: mode}, nil
 }
 
 // GetEvacuationRoute godoc
 // @Summary      Calculate Evacuation Route
-// @Description  Calculates an evacuation route from a danger point to a safe zone. If safe_point is omitted, the API determines the nearest safe zone matching the incident type.
+// @Description  Calculates an evacuation route from a danger point to a safe zone. If safe_point is omitted, the API routes to the nearest open safe zones with room left matching the incident type, picks the one with the shortest travel time and returns it as safe_zone, with every zone compared ranked in alternatives. When needs are given, only safe zones offering all of them are considered. mode picks how the evacuee travels (foot, car, bike, wheelchair or bus) and is echoed in the response; wheelchair routes avoid steps and steep grades and only go to wheelchair accessible zones. With save, the route is kept and can be tracked under /evacuations/{id}; saving needs a Bearer JWT, whose subject is recorded as requested_by. Routes avoid every active disaster zone except those the danger point lies in (start_zones), and hazard_distance reports how far the route still runs inside active zones. Car and bus routes also carry traffic, the travel time adjusted for live traffic along the route with its congested stretches, when traffic data could be had.
 // @Tags         Evacuation
 // @Accept       json
-// @Produce      json
+// @Produce      json,application/geo+json,application/gpx+xml,application/vnd.google-earth.kml+xml
 // @Param        evacuationRequest  body      EvacuationRequest  true  "Evacuation Request"
+// @Param        format  query     string  false  "Response format: json, geojson, gpx or kml"
 // @Success      200  {object}  services.EvacuationRouteResponse
-// @Failure      400  {object}  map[string]string  "Invalid request payload"
+// @Failure      400  {object}  map[string]string  "Invalid request payload, need or mode"
+// @Failure      401  {object}  map[string]string  "Saving without a valid token"
+// @Failure      404  {object}  map[string]string  "No suitable safe zone available"
 // @Failure      500  {object}  map[string]string  "Internal server error"
 // @Router       /evacuation [post]
 func (h *EvacuationHandler) GetEvacuationRoute(c *gin.Context) {
@@ -56,11 +95,269 @@ func (h *EvacuationHandler) GetEvacuationRoute(c *gin.Context) {
 		return
 	}
 
-	route, err := h.Service.GetEvacuationRoute(req.DangerPoint, req.IncidentTypeID, req.SafePoint)
+	requestedBy := c.GetString(middleware.SubjectKey)
+	if req.Save && requestedBy == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Saving an evacuation requires a token with a subject"})
+		return
+	}
+
+	opts, err := evacuationOptions(req.Needs, req.Mode)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	route, err := h.Service.GetEvacuationRoute(req.DangerPoint, req.IncidentTypeID, req.SafePoint, opts)
+	if errors.Is(err, services.ErrNoSafeZoneAvailable) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, route)
+	if req.Save {
+		evacuation := services.NewEvacuation(req.DangerPoint, req.SafePoint, req.IncidentTypeID, route)
+		if req.IncidentID != nil {
+			evacuation.IncidentID = req.IncidentID
+		}
+		evacuation.RequestedBy = &requestedBy
+		saved, err := h.Service.SaveEvacuation(evacuation)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		route.EvacuationID = &saved.EvacuationID
+	}
+
+	respond(c, "evacuation", route, func() services.FeatureCollection {
+		collection := services.RouteToFeatureCollection(route.Paths)
+		if route.SafeZone != nil {
+			for _, feature := range collection.Features {
+				feature.Properties["safe_zone"] = route.SafeZone
+				feature.Properties["alternatives"] = route.Alternatives
+			}
+		}
+		if route.Traffic != nil && len(collection.Features) > 0 {
+			collection.Features[0].Properties["traffic"] = route.Traffic
+		}
+		return collection
+	})
+}
+
+// EvacuationPlanRequest defines the expected JSON payload for an evacuation plan.
+// swagger:model EvacuationPlanRequest
+type EvacuationPlanRequest struct {
+	IncidentTypeID int                   `json:"incident_type_id" example:"3"`
+	Origins        []services.PlanOrigin `json:"origins"`
+	// Needs lists facilities every safe zone used must offer.
+	Needs []string `json:"needs,omitempty" example:"water"`
+	// Mode is how evacuees travel: foot (the default), car, bike, wheelchair
+	// or bus.
+	Mode string `json:"mode,omitempty" example:"bus"`
+}
+
+// PlanEvacuation godoc
+// @Summary      Plan Evacuation of Many Origins
+// @Description  Assigns the people of many origins to the nearest open safe zones matching the incident type, without putting more people in a zone than it has room for and keeping the travel time of all evacuees added together as small as possible. An origin may be split over several zones, and mode sets how evacuees travel as for /evacuation. Returns each origin's assignments with their routes and the load on each safe zone; people that cannot be placed are reported as unassigned. The plan does not reserve capacity.
+// @Tags         Evacuation
+// @Accept       json
+// @Produce      json,application/geo+json,application/gpx+xml,application/vnd.google-earth.kml+xml
+// @Param        evacuationPlanRequest  body      EvacuationPlanRequest  true  "Evacuation Plan Request"
+// @Param        format  query     string  false  "Response format: json, geojson, gpx or kml"
+// @Success      200  {object}  services.EvacuationPlan
+// @Failure      400  {object}  map[string]string  "Invalid request payload, origin, need or mode"
+// @Failure      404  {object}  map[string]string  "No suitable safe zone available"
+// @Failure      500  {object}  map[string]string  "Internal server error"
+// @Router       /evacuation/plan [post]
+func (h *EvacuationHandler) PlanEvacuation(c *gin.Context) {
+	var req EvacuationPlanRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		return
+	}
+
+	opts, err := evacuationOptions(req.Needs, req.Mode)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	plan, err := h.Service.PlanEvacuation(req.Origins, req.IncidentTypeID, opts)
+	switch {
+	case errors.Is(err, services.ErrInvalidEvacuationPlan):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, services.ErrNoSafeZoneAvailable):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	respond(c, "evacuation-plan", plan, func() services.FeatureCollection {
+		return services.EvacuationPlanToFeatureCollection(plan)
+	})
+}
+
+// GetIsochrone godoc
+// @Summary      Reachable area
+// @Description  Returns the area reachable from a point within the given minutes as GeoJSON polygons, one per time band. The time limit is split into bands of equal length, and each band's polygon covers everything reachable by its upper limit in minutes, so the polygons overlap. The areas leave out every active disaster zone except those the point lies in, listed as start_zones, along with land only reachable through one. profile sets how residents travel as mode does for /evacuation; GraphHopper's Isochrone API takes no custom model, so wheelchair and bus use the plain foot and car profiles.
+// @Tags         Evacuation
+// @Produce      json,application/geo+json,application/gpx+xml,application/vnd.google-earth.kml+xml
+// @Param        point    query     string  true   "Start point in latitude,longitude format"  example("53.349805,-6.26031")
+// @Param        minutes  query     int     true   "Time limit in minutes, at most 60"  example(15)
+// @Param        profile  query     string  false  "foot (default), car, bike, wheelchair or bus"
+// @Param        bands    query     int     false  "Number of time bands, 3 by default and at most 6"
+// @Param        format   query     string  false  "Response format: json, geojson, gpx or kml"
+// @Success      200  {object}  services.FeatureCollection
+// @Failure      400  {object}  map[string]string  "Invalid point, minutes, profile or bands"
+// @Failure      500  {object}  map[string]string  "Failed to compute isochrone"
+// @Router       /isochrone [get]
+func (h *EvacuationHandler) GetIsochrone(c *gin.Context) {
+	point, err := parseFloatList(c.Query("point"), 2)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid point: expected latitude,longitude"})
+		return
+	}
+	minutes, err := strconv.Atoi(c.Query("minutes"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid minutes: expected an integer"})
+		return
+	}
+	bands, err := parseOptionalInt(c, "bands")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	mode, err := services.ParseTravelMode(c.Query("profile"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var bandCount int
+	if bands != nil {
+		bandCount = *bands
+	}
+	isochrone, err := h.Service.GetIsochrone([2]float64{point[0], point[1]}, minutes, bandCount, mode)
+	switch {
+	case errors.Is(err, services.ErrInvalidIsochrone):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute isochrone", "details": err.Error()})
+		return
+	}
+
+	respond(c, "isochrone", isochrone, func() services.FeatureCollection {
+		return isochrone
+	})
+}
+
+// GetEvacuations godoc
+// @Summary      List saved evacuations
+// @Description  Lists evacuation routes saved through /evacuation, newest first, optionally filtered by the disaster zone being evacuated, the destination safe zone and status.
+// @Tags         Evacuation
+// @Produce      json
+// @Param        incident_id  query     int     false  "Disaster zone being evacuated"
+// @Param        zone_id      query     int     false  "Destination safe zone"
+// @Param        status       query     string  false  "issued, in_progress, arrived or cancelled"
+// @Success      200  {array}   models.Evacuation
+// @Failure      400  {object}  map[string]string  "Invalid filter"
+// @Failure      500  {object}  map[string]string  "Internal server error"
+// @Router       /evacuations [get]
+func (h *EvacuationHandler) GetEvacuations(c *gin.Context) {
+	filter, err := parseEvacuationFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	evacuations, err := h.Service.GetEvacuations(filter)
+	if err != nil {
+		writeEvacuationError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, evacuations)
+}
+
+// GetEvacuation godoc
+// @Summary      Retrieve a saved evacuation
+// @Description  Retrieves a saved evacuation route with the safe zone as it was when the route was issued.
+// @Tags         Evacuation
+// @Produce      json
+// @Param        id   path      int  true  "Evacuation ID"
+// @Success      200  {object}  models.Evacuation
+// @Failure      400  {object}  map[string]string  "Invalid evacuation ID"
+// @Failure      404  {object}  map[string]string  "Evacuation not found"
+// @Router       /evacuations/{id} [get]
+func (h *EvacuationHandler) GetEvacuation(c *gin.Context) {
+	evacuationID, ok := parseEvacuationID(c)
+	if !ok {
+		return
+	}
+	evacuation, err := h.Service.GetEvacuation(evacuationID)
+	if err != nil {
+		writeEvacuationError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, evacuation)
+}
+
+// UpdateEvacuationStatus godoc
+// @Summary      Update a saved evacuation's status
+// @Description  Moves a saved evacuation from issued to in_progress and then arrived, or to cancelled before it arrives. Arrived and cancelled evacuations are final. Arrival does not check anyone in to the safe zone.
+// @Tags         Evacuation
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id      path      int                            true  "Evacuation ID"
+// @Param        status  body      models.EvacuationStatusChange  true  "New status"
+// @Success      200  {object}  models.Evacuation
+// @Failure      400  {object}  map[string]string  "Invalid evacuation ID or status"
+// @Failure      401  {object}  map[string]string  "Unauthorized"
+// @Failure      404  {object}  map[string]string  "Evacuation not found"
+// @Failure      409  {object}  map[string]string  "Status transition not allowed"
+// @Router       /evacuations/{id} [patch]
+func (h *EvacuationHandler) UpdateEvacuationStatus(c *gin.Context) {
+	evacuationID, ok := parseEvacuationID(c)
+	if !ok {
+		return
+	}
+	var change models.EvacuationStatusChange
+	if err := c.ShouldBindJSON(&change); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payload"})
+		return
+	}
+	evacuation, err := h.Service.SetEvacuationStatus(evacuationID, change.Status)
+	if err != nil {
+		writeEvacuationError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, evacuation)
+}
+
+func parseEvacuationID(c *gin.Context) (int, bool) {
+	evacuationID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid evacuation ID"})
+		return 0, false
+	}
+	return evacuationID, true
+}
+
+// writeEvacuationError maps saved evacuation errors to HTTP responses.
+func writeEvacuationError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidEvacuationStatus):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrEvacuationNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Evacuation not found"})
+	case errors.Is(err, services.ErrInvalidStatusTransition):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
 }
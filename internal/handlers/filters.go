@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"disaster-response-map-api/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// parseSpatialFilter reads the bbox=minLon,minLat,maxLon,maxLat and
+// near=lat,lon&within=metres query parameters.
+func parseSpatialFilter(c *gin.Context) (services.SpatialFilter, error) {
+	var filter services.SpatialFilter
+
+	if raw := c.Query("bbox"); raw != "" {
+		values, err := parseFloatList(raw, 4)
+		if err != nil {
+			return filter, fmt.Errorf("invalid bbox: %v", err)
+		}
+		if values[0] > values[2] || values[1] > values[3] {
+			return filter, fmt.Errorf("invalid bbox: expected minLon,minLat,maxLon,maxLat")
+		}
+		filter.BBox = &services.BoundingBox{MinLon: values[0], MinLat: values[1], MaxLon: values[2], MaxLat: values[3]}
+	}
+
+	near, within := c.Query("near"), c.Query("within")
+	if near != "" || within != "" {
+		if near == "" || within == "" {
+			return filter, fmt.Errorf("near and within must be used together")
+		}
+		point, err := parseFloatList(near, 2)
+		if err != nil {
+			return filter, fmt.Errorf("invalid near: %v", err)
+		}
+		distance, err := strconv.ParseFloat(within, 64)
+		if err != nil || distance < 0 {
+			return filter, fmt.Errorf("invalid within: expected a distance in metres")
+		}
+		filter.Near = &[2]float64{point[0], point[1]}
+		filter.Within = distance
+	}
+	return filter, nil
+}
+
+// parseDisasterZoneFilter reads the /zones filters. The minimum severity can be
+// given as min_severity=N or as severity>=N, and as_of takes an RFC3339 time.
+func parseDisasterZoneFilter(c *gin.Context) (services.DisasterZoneFilter, error) {
+	spatial, err := parseSpatialFilter(c)
+	if err != nil {
+		return services.DisasterZoneFilter{}, err
+	}
+	filter := services.DisasterZoneFilter{SpatialFilter: spatial}
+
+	if filter.TypeID, err = parseOptionalInt(c, "type"); err != nil {
+		return filter, err
+	}
+	if filter.StatusID, err = parseOptionalInt(c, "status"); err != nil {
+		return filter, err
+	}
+	// "?severity>=3" reaches us as the key "severity>" with the value "3".
+	severityKey := "min_severity"
+	if _, ok := c.GetQuery("severity>"); ok {
+		severityKey = "severity>"
+	}
+	if filter.MinSeverity, err = parseOptionalInt(c, severityKey); err != nil {
+		return filter, err
+	}
+	if raw := c.Query("as_of"); raw != "" {
+		asOf, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return filter, fmt.Errorf("invalid as_of: expected an RFC3339 timestamp")
+		}
+		filter.AsOf = &asOf
+	}
+	return filter, nil
+}
+
+func parseSafeZoneFilter(c *gin.Context) (services.SafeZoneFilter, error) {
+	spatial, err := parseSpatialFilter(c)
+	if err != nil {
+		return services.SafeZoneFilter{}, err
+	}
+	filter := services.SafeZoneFilter{SpatialFilter: spatial}
+	if filter.IncidentTypeID, err = parseOptionalInt(c, "type"); err != nil {
+		return filter, err
+	}
+	includeInactive, err := parseOptionalBool(c, "include_inactive")
+	if err != nil {
+		return filter, err
+	}
+	filter.IncludeInactive = includeInactive != nil && *includeInactive
+	if filter.Compromised, err = parseOptionalBool(c, "compromised"); err != nil {
+		return filter, err
+	}
+	return filter, nil
+}
+
+func parseEvacuationFilter(c *gin.Context) (services.EvacuationFilter, error) {
+	var filter services.EvacuationFilter
+	var err error
+	if filter.IncidentID, err = parseOptionalInt(c, "incident_id"); err != nil {
+		return filter, err
+	}
+	if filter.ZoneID, err = parseOptionalInt(c, "zone_id"); err != nil {
+		return filter, err
+	}
+	if status := c.Query("status"); status != "" {
+		filter.Status = &status
+	}
+	return filter, nil
+}
+
+func parseOptionalInt(c *gin.Context, key string) (*int, error) {
+	raw := c.Query(key)
+	if raw == "" {
+		return nil, nil
+	}
+	value, err := strconv.Atoi(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: expected an integer", strings.TrimSuffix(key, ">"))
+	}
+	return &value, nil
+}
+
+func parseOptionalBool(c *gin.Context, key string) (*bool, error) {
+	raw := c.Query(key)
+	if raw == "" {
+		return nil, nil
+	}
+	value, err := strconv.ParseBool(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: expected true or false", key)
+	}
+	return &value, nil
+}
+
+func parseFloatList(raw string, count int) ([]float64, error) {
+	parts := strings.Split(raw, ",")
+	if len(parts) != count {
+		return nil, fmt.Errorf("expected %d comma-separated numbers", count)
+	}
+	values := make([]float64, count)
+	for i, part := range parts {
+		value, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a number", part)
+		}
+		values[i] = value
+	}
+	return values, nil
+}
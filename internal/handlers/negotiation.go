@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"net/http"
+
+	"disaster-response-map-api/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	mimeGeoJSON = "application/geo+json"
+	mimeGPX     = "application/gpx+xml"
+	mimeKML     = "application/vnd.google-earth.kml+xml"
+)
+
+// respond writes payload as plain JSON by default. The FeatureCollection built
+// by toGeoJSON is returned instead for Accept: application/geo+json or
+// format=geojson, and is converted to a GPX or KML download for format=gpx or
+// format=kml. name is used as the document and file name.
+func respond(c *gin.Context, name string, payload interface{}, toGeoJSON func() services.FeatureCollection) {
+	format := c.Query("format")
+	if format == "" && c.NegotiateFormat(gin.MIMEJSON, mimeGeoJSON) == mimeGeoJSON {
+		format = "geojson"
+	}
+
+	var (
+		body        []byte
+		contentType string
+		err         error
+	)
+	switch format {
+	case "", "json":
+		c.JSON(http.StatusOK, payload)
+		return
+	case "geojson":
+		c.Header("Content-Type", mimeGeoJSON)
+		c.JSON(http.StatusOK, toGeoJSON())
+		return
+	case "gpx":
+		body, err = services.FeatureCollectionToGPX(toGeoJSON(), name)
+		contentType = mimeGPX
+	case "kml":
+		body, err = services.FeatureCollectionToKML(toGeoJSON(), name)
+		contentType = mimeKML
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported format, expected json, geojson, gpx or kml"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to encode " + format, "details": err.Error()})
+		return
+	}
+	c.Header("Content-Disposition", `attachment; filename="`+name+`.`+format+`"`)
+	c.Data(http.StatusOK, contentType, body)
+}
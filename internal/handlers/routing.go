@@ -7,7 +7,10 @@
 package handlers
 
 import (
+	"log"
 	"net/http"
+	"strconv"
+	"time"
 
 	"disaster-response-map-api/internal/services"
 
@@ -17,6 +20,8 @@ import (
 type RoutingHandler struct {
 	GHService services.GraphHopperServiceInterface
 	DZService services.DisasterZoneServiceInterface
+	// Traffic, when set, adjusts the travel time of /route for live traffic.
+	Traffic services.TrafficServiceInterface
 }
 
 // NewRoutingHandler creates a new instance of RoutingHandler.
@@ -34,11 +39,14 @@ func NewRoutingHandler(ghService services.GraphHopperServiceInterface, dzService
 // @Summary      Calculate Safe Route
 // @Description  Calculates a route between two points that avoids disaster zones by using a custom model.
 // @Tags         Routing
-// @Produce      json
+// @Produce      json,application/geo+json,application/gpx+xml,application/vnd.google-earth.kml+xml
 // @Param        origin       query     string  true  "Origin coordinates in latitude,longitude format"  example("53.349805,-6.26031")
 // @Param        destination  query     string  true  "Destination coordinates in latitude,longitude format"  example("53.3478,-6.2597")
+// @Param        avoidance  query     string  false  "How strongly to avoid zones: strict, balanced (default) or minimal"
+// @Param        statuses   query     string  false  "Comma-separated status IDs of the zones to avoid, default from GET /incident-statuses"  example("3,4")
+// @Param        format  query     string  false  "Response format: json, geojson, gpx or kml"
 // @Success      200  {object}  services.RouteResponse
-// @Failure      400  {object}  map[string]string  "Missing required parameters"
+// @Failure      400  {object}  map[string]string  "Missing required parameters or invalid avoidance"
 // @Failure      500  {object}  map[string]string  "Failed to fetch safe route"
 // @Router       /routing [get]
 func (h *RoutingHandler) GetSafeRouting(c *gin.Context) {
@@ -48,23 +56,69 @@ func (h *RoutingHandler) GetSafeRouting(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing required parameters"})
 		return
 	}
+	avoidance, err := services.ParseAvoidanceLevel(c.Query("avoidance"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	statuses, err := services.ParseStatusIDs(c.Query("statuses"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(statuses) == 0 {
+		statuses = h.DZService.ActiveStatuses()
+	}
 
-	zones, err := h.DZService.GetActiveDisasterZones()
+	zonesAsOf := time.Now().UTC()
+	zones, err := h.DZService.GetActiveDisasterZones(statuses)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch disaster zones"})
 		return
 	}
 
-	route, err := h.GHService.GetSafeRoute(origin, destination, zones)
+	areas, err := h.DZService.DissolveDisasterZones(zones, avoidance)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to prepare disaster zones"})
+		c.Error(err)
+		return
+	}
+
+	route, err := h.GHService.GetSafeRoute(origin, destination, areas)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch safe route"})
 		c.Error(err)
 		return
 	}
+	route.ZonesAsOf = &zonesAsOf
+	route.Avoidance = avoidance
+	route.ActiveStatuses = statuses
 
-	c.JSON(http.StatusOK, route)
+	respond(c, "route", route, func() services.FeatureCollection {
+		collection := services.RouteToFeatureCollection(route.Paths)
+		for _, feature := range collection.Features {
+			feature.Properties["avoided_zones"] = route.AvoidedZones
+			feature.Properties["avoidance"] = route.Avoidance
+			feature.Properties["active_statuses"] = route.ActiveStatuses
+		}
+		return collection
+	})
 }
 
+// GetDefaultRoute godoc
+// @Summary      Calculate Route
+// @Description  Calculates a car route between two points without avoiding disaster zones. The travel time is also adjusted for live traffic sampled along the route, returned as traffic with the congested stretches; it is left out when no traffic data could be had.
+// @Tags         Routing
+// @Produce      json,application/geo+json,application/gpx+xml,application/vnd.google-earth.kml+xml
+// @Param        origin       query     string  true   "Origin coordinates in latitude,longitude format"  example("53.349805,-6.26031")
+// @Param        destination  query     string  true   "Destination coordinates in latitude,longitude format"  example("53.3478,-6.2597")
+// @Param        traffic      query     bool    false  "Set to false to skip the traffic-adjusted ETA"
+// @Param        format       query     string  false  "Response format: json, geojson, gpx or kml"
+// @Success      200  {object}  services.RouteResponse
+// @Failure      400  {object}  map[string]string  "Missing required parameters"
+// @Failure      500  {object}  map[string]string  "Failed to fetch route"
+// @Router       /route [get]
 func (h *RoutingHandler) GetDefaultRoute(c *gin.Context) {
 	origin := c.Query("origin")
 	destination := c.Query("destination")
@@ -72,12 +126,34 @@ func (h *RoutingHandler) GetDefaultRoute(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing required parameters"})
 		return
 	}
+	withTraffic := true
+	if value := c.Query("traffic"); value != "" {
+		var err error
+		if withTraffic, err = strconv.ParseBool(value); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid traffic parameter"})
+			return
+		}
+	}
 
 	route, err := h.GHService.GetRoute(origin, destination)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch route", "details": err.Error()})
 		return
 	}
+	if withTraffic && h.Traffic != nil && len(route.Paths) > 0 {
+		eta, err := services.EstimateTrafficETA(h.Traffic, route.Paths[0])
+		if err != nil {
+			log.Printf("Error estimating traffic along route: %v", err)
+		} else {
+			route.Traffic = &eta
+		}
+	}
 
-	c.JSON(http.StatusOK, route)
+	respond(c, "route", route, func() services.FeatureCollection {
+		collection := services.RouteToFeatureCollection(route.Paths)
+		if route.Traffic != nil && len(collection.Features) > 0 {
+			collection.Features[0].Properties["traffic"] = route.Traffic
+		}
+		return collection
+	})
 }
@@ -1,7 +1,9 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
+	"strconv"
 
 	"disaster-response-map-api/internal/models"
 	"disaster-response-map-api/internal/services"
@@ -22,17 +24,26 @@ type CreateSafeZoneRequest struct {
 	ZoneLat        float64 `json:"zone_lat"        example:"53.12345"`
 	ZoneLon        float64 `json:"zone_lon"        example:"-6.98765"`
 	IncidentTypeID int     `json:"incident_type_id" example:"3"`
+	// IncidentTypeIDs lists further incident types the zone serves.
+	IncidentTypeIDs []int `json:"incident_type_ids,omitempty" example:"1,3"`
+	Capacity        *int  `json:"capacity,omitempty" example:"200"`
+	IsOpen          *bool `json:"is_open,omitempty" example:"true"`
+	// Facilities defaults to none when omitted.
+	Facilities *models.SafeZoneFacilities `json:"facilities,omitempty"`
+	// ExternalRef must be unique; a replace that omits it keeps the old one.
+	ExternalRef *string `json:"external_ref,omitempty" example:"DCC-0042"`
 }
 
 // CreateSafeZone godoc
 // @Summary      Create new safe zone
-// @Description  Inserts a new safe zone record into the DB.
+// @Description  Inserts a new safe zone record into the DB. A zone can serve several incident types: incident_type_id is its primary type and incident_type_ids lists any others.
 // @Tags         SafeZone
 // @Accept       json
 // @Produce      json
 // @Param        safeZoneRequest  body      CreateSafeZoneRequest  true  "New Safe Zone Data"
 // @Success      201  {object}  map[string]interface{}  "Creation success"
-// @Failure      400  {object}  map[string]string       "Invalid payload"
+// @Failure      400  {object}  map[string]string       "Invalid payload, coordinates out of range, empty name or unknown incident type"
+// @Failure      409  {object}  map[string]string       "external_ref used by another safe zone"
 // @Failure      500  {object}  map[string]string       "Internal server error"
 // @Router       /safezones [post]
 func (h *SafeZoneHandler) CreateSafeZone(c *gin.Context) {
@@ -43,15 +54,20 @@ func (h *SafeZoneHandler) CreateSafeZone(c *gin.Context) {
 	}
 
 	safeZone := models.SafeZoneCreate{
-		ZoneName:       req.ZoneName,
-		ZoneLat:        req.ZoneLat,
-		ZoneLon:        req.ZoneLon,
-		IncidentTypeID: req.IncidentTypeID,
+		ZoneName:        req.ZoneName,
+		ZoneLat:         req.ZoneLat,
+		ZoneLon:         req.ZoneLon,
+		IncidentTypeID:  req.IncidentTypeID,
+		IncidentTypeIDs: req.IncidentTypeIDs,
+		Capacity:        req.Capacity,
+		IsOpen:          req.IsOpen,
+		Facilities:      req.Facilities,
+		ExternalRef:     req.ExternalRef,
 	}
 
 	newID, err := h.Service.CreateSafeZone(safeZone)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		writeSafeZoneError(c, err)
 		return
 	}
 
@@ -64,17 +80,336 @@ func (h *SafeZoneHandler) CreateSafeZone(c *gin.Context) {
 
 // GetSafeZones handles GET requests to retrieve all safe zones.
 // @Summary      Retrieve Safe Zones
-// @Description  Retrieves a list of safe zones from the database.
+// @Description  Retrieves a list of safe zones from the database, optionally filtered.
 // @Tags         SafeZone
-// @Produce      json
+// @Produce      json,application/geo+json,application/gpx+xml,application/vnd.google-earth.kml+xml
+// @Param        bbox    query     string  false  "Bounding box as minLon,minLat,maxLon,maxLat"  example("-6.3,53.3,-6.2,53.4")
+// @Param        near    query     string  false  "Point as lat,lon; requires within"  example("53.349805,-6.26031")
+// @Param        within  query     number  false  "Distance from near in metres"  example(1000)
+// @Param        type    query     int     false  "Incident type ID"
+// @Param        include_inactive  query  bool  false  "Also list deactivated safe zones"
+// @Param        compromised       query  bool  false  "Only safe zones that are (true) or are not (false) inside an active disaster zone"
+// @Param        format  query     string  false  "Response format: json, geojson, gpx or kml"
 // @Success      200  {array}   models.SafeZone
+// @Failure      400  {object}  map[string]string  "Invalid filter"
 // @Failure      500  {object}  map[string]string  "Internal Server Error"
 // @Router       /safezones [get]
 func (h *SafeZoneHandler) GetSafeZones(c *gin.Context) {
-	safeZones, err := h.Service.GetSafeZones()
+	filter, err := parseSafeZoneFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	safeZones, err := h.Service.GetSafeZones(filter)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch safe zones"})
 		return
 	}
-	c.JSON(http.StatusOK, safeZones)
+	respond(c, "safezones", safeZones, func() services.FeatureCollection {
+		return services.SafeZonesToFeatureCollection(safeZones)
+	})
+}
+
+// GetSafeZone godoc
+// @Summary      Retrieve a safe zone
+// @Description  Retrieves one safe zone by ID, including deactivated zones.
+// @Tags         SafeZone
+// @Produce      json
+// @Param        id   path      int  true  "Safe zone ID"
+// @Success      200  {object}  models.SafeZone
+// @Failure      400  {object}  map[string]string  "Invalid safe zone ID"
+// @Failure      404  {object}  map[string]string  "Safe zone not found"
+// @Router       /safezones/{id} [get]
+func (h *SafeZoneHandler) GetSafeZone(c *gin.Context) {
+	zoneID, ok := parseSafeZoneID(c)
+	if !ok {
+		return
+	}
+	safeZone, err := h.Service.GetSafeZone(zoneID)
+	if err != nil {
+		writeSafeZoneError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, safeZone)
+}
+
+// ReplaceSafeZone godoc
+// @Summary      Replace a safe zone
+// @Description  Overwrites a safe zone's details. Occupancy is kept, and the capacity may not drop below it. external_ref is kept when omitted.
+// @Tags         SafeZone
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id               path      int                    true  "Safe zone ID"
+// @Param        safeZoneRequest  body      CreateSafeZoneRequest  true  "Safe Zone Data"
+// @Success      200  {object}  models.SafeZone
+// @Failure      400  {object}  map[string]string  "Invalid payload"
+// @Failure      401  {object}  map[string]string  "Unauthorized"
+// @Failure      404  {object}  map[string]string  "Safe zone not found"
+// @Failure      409  {object}  map[string]string  "Capacity below occupancy, or external_ref used by another safe zone"
+// @Router       /safezones/{id} [put]
+func (h *SafeZoneHandler) ReplaceSafeZone(c *gin.Context) {
+	zoneID, ok := parseSafeZoneID(c)
+	if !ok {
+		return
+	}
+	var req CreateSafeZoneRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payload"})
+		return
+	}
+
+	safeZone, err := h.Service.ReplaceSafeZone(zoneID, models.SafeZoneCreate{
+		ZoneName:        req.ZoneName,
+		ZoneLat:         req.ZoneLat,
+		ZoneLon:         req.ZoneLon,
+		IncidentTypeID:  req.IncidentTypeID,
+		IncidentTypeIDs: req.IncidentTypeIDs,
+		Capacity:        req.Capacity,
+		IsOpen:          req.IsOpen,
+		Facilities:      req.Facilities,
+		ExternalRef:     req.ExternalRef,
+	})
+	if err != nil {
+		writeSafeZoneError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, safeZone)
+}
+
+// PatchSafeZone godoc
+// @Summary      Update a safe zone
+// @Description  Changes only the given fields of a safe zone.
+// @Tags         SafeZone
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id     path      int                   true  "Safe zone ID"
+// @Param        patch  body      models.SafeZonePatch  true  "Fields to change"
+// @Success      200  {object}  models.SafeZone
+// @Failure      400  {object}  map[string]string  "Invalid payload"
+// @Failure      401  {object}  map[string]string  "Unauthorized"
+// @Failure      404  {object}  map[string]string  "Safe zone not found"
+// @Failure      409  {object}  map[string]string  "Capacity below occupancy"
+// @Router       /safezones/{id} [patch]
+func (h *SafeZoneHandler) PatchSafeZone(c *gin.Context) {
+	zoneID, ok := parseSafeZoneID(c)
+	if !ok {
+		return
+	}
+	var patch models.SafeZonePatch
+	if err := c.ShouldBindJSON(&patch); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payload"})
+		return
+	}
+
+	safeZone, err := h.Service.PatchSafeZone(zoneID, patch)
+	if err != nil {
+		writeSafeZoneError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, safeZone)
+}
+
+// DeleteSafeZone godoc
+// @Summary      Delete a safe zone
+// @Description  Permanently deletes a safe zone. Zones with people checked in cannot be deleted; deactivate them instead.
+// @Tags         SafeZone
+// @Security     BearerAuth
+// @Param        id   path      int  true  "Safe zone ID"
+// @Success      204
+// @Failure      401  {object}  map[string]string  "Unauthorized"
+// @Failure      404  {object}  map[string]string  "Safe zone not found"
+// @Failure      409  {object}  map[string]string  "People are checked in"
+// @Router       /safezones/{id} [delete]
+func (h *SafeZoneHandler) DeleteSafeZone(c *gin.Context) {
+	zoneID, ok := parseSafeZoneID(c)
+	if !ok {
+		return
+	}
+	if err := h.Service.DeleteSafeZone(zoneID); err != nil {
+		writeSafeZoneError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// DeactivateSafeZone godoc
+// @Summary      Deactivate a safe zone
+// @Description  Hides a safe zone from listings and evacuation while keeping its record.
+// @Tags         SafeZone
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   path      int  true  "Safe zone ID"
+// @Success      200  {object}  models.SafeZone
+// @Failure      401  {object}  map[string]string  "Unauthorized"
+// @Failure      404  {object}  map[string]string  "Safe zone not found"
+// @Router       /safezones/{id}/deactivate [post]
+func (h *SafeZoneHandler) DeactivateSafeZone(c *gin.Context) {
+	h.setActive(c, false)
+}
+
+// ReactivateSafeZone godoc
+// @Summary      Reactivate a safe zone
+// @Description  Makes a deactivated safe zone available again.
+// @Tags         SafeZone
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   path      int  true  "Safe zone ID"
+// @Success      200  {object}  models.SafeZone
+// @Failure      401  {object}  map[string]string  "Unauthorized"
+// @Failure      404  {object}  map[string]string  "Safe zone not found"
+// @Router       /safezones/{id}/reactivate [post]
+func (h *SafeZoneHandler) ReactivateSafeZone(c *gin.Context) {
+	h.setActive(c, true)
+}
+
+func (h *SafeZoneHandler) setActive(c *gin.Context, active bool) {
+	zoneID, ok := parseSafeZoneID(c)
+	if !ok {
+		return
+	}
+	safeZone, err := h.Service.SetSafeZoneActive(zoneID, active)
+	if err != nil {
+		writeSafeZoneError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, safeZone)
+}
+
+// CheckIn godoc
+// @Summary      Check people in to a safe zone
+// @Description  Adds people to a safe zone's occupancy. Closed zones and check-ins beyond capacity are refused.
+// @Tags         SafeZone
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id      path      int                     true  "Safe zone ID"
+// @Param        change  body      models.OccupancyChange  true  "Number of people"
+// @Success      200  {object}  models.SafeZone
+// @Failure      400  {object}  map[string]string  "Invalid payload"
+// @Failure      401  {object}  map[string]string  "Unauthorized"
+// @Failure      404  {object}  map[string]string  "Safe zone not found"
+// @Failure      409  {object}  map[string]string  "Safe zone closed, deactivated or full"
+// @Failure      500  {object}  map[string]string  "Internal server error"
+// @Router       /safezones/{id}/check-in [post]
+func (h *SafeZoneHandler) CheckIn(c *gin.Context) {
+	h.changeOccupancy(c, h.Service.CheckIn)
+}
+
+// CheckOut godoc
+// @Summary      Check people out of a safe zone
+// @Description  Removes people from a safe zone's occupancy.
+// @Tags         SafeZone
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id      path      int                     true  "Safe zone ID"
+// @Param        change  body      models.OccupancyChange  true  "Number of people"
+// @Success      200  {object}  models.SafeZone
+// @Failure      400  {object}  map[string]string  "Invalid payload"
+// @Failure      401  {object}  map[string]string  "Unauthorized"
+// @Failure      404  {object}  map[string]string  "Safe zone not found"
+// @Failure      409  {object}  map[string]string  "More people than are checked in"
+// @Failure      500  {object}  map[string]string  "Internal server error"
+// @Router       /safezones/{id}/check-out [post]
+func (h *SafeZoneHandler) CheckOut(c *gin.Context) {
+	h.changeOccupancy(c, h.Service.CheckOut)
+}
+
+func (h *SafeZoneHandler) changeOccupancy(c *gin.Context, change func(zoneID, count int) (models.SafeZone, error)) {
+	zoneID, ok := parseSafeZoneID(c)
+	if !ok {
+		return
+	}
+	var req models.OccupancyChange
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payload"})
+		return
+	}
+
+	safeZone, err := change(zoneID, req.Count)
+	if err != nil {
+		writeSafeZoneError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, safeZone)
+}
+
+// maxImportSize caps the size of an uploaded shelter list.
+const maxImportSize = 10 << 20
+
+// ImportSafeZones godoc
+// @Summary      Import safe zones
+// @Description  Creates safe zones from a CSV or GeoJSON shelter list, sent as the request body with Content-Type text/csv or application/geo+json. Entries whose external_ref matches an existing zone update it instead. All entries are validated and applied in a single transaction, which is rolled back if any entry is rejected or dry_run is set. The report lists the outcome of every entry.
+// @Tags         SafeZone
+// @Accept       text/csv,application/geo+json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        dry_run  query     bool  false  "Validate and report without saving anything"
+// @Success      200  {object}  services.SafeZoneImportReport
+// @Failure      400  {object}  map[string]string  "Unreadable file or invalid dry_run"
+// @Failure      401  {object}  map[string]string  "Unauthorized"
+// @Failure      415  {object}  map[string]string  "Unsupported content type"
+// @Failure      422  {object}  services.SafeZoneImportReport  "Some entries were rejected; nothing was saved"
+// @Failure      500  {object}  map[string]string  "Internal server error"
+// @Router       /safezones/import [post]
+func (h *SafeZoneHandler) ImportSafeZones(c *gin.Context) {
+	dryRun, err := parseOptionalBool(c, "dry_run")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	body := http.MaxBytesReader(c.Writer, c.Request.Body, maxImportSize)
+	var rows []services.SafeZoneImportRow
+	switch c.ContentType() {
+	case "text/csv":
+		rows, err = services.ParseSafeZoneCSV(body)
+	case mimeGeoJSON, gin.MIMEJSON:
+		rows, err = services.ParseSafeZoneGeoJSON(body)
+	default:
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "Unsupported content type, expected text/csv or application/geo+json"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	report, err := h.Service.ImportSafeZones(rows, dryRun != nil && *dryRun)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	status := http.StatusOK
+	if report.Rejected > 0 && !report.DryRun {
+		status = http.StatusUnprocessableEntity
+	}
+	c.JSON(status, report)
+}
+
+func parseSafeZoneID(c *gin.Context) (int, bool) {
+	zoneID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid safe zone ID"})
+		return 0, false
+	}
+	return zoneID, true
+}
+
+// writeSafeZoneError maps safe zone service errors to HTTP responses.
+func writeSafeZoneError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidSafeZone), errors.Is(err, services.ErrInvalidOccupancy):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrSafeZoneNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Safe zone not found"})
+	case errors.Is(err, services.ErrSafeZoneClosed), errors.Is(err, services.ErrSafeZoneFull), errors.Is(err, services.ErrNotEnoughOccupants),
+		errors.Is(err, services.ErrSafeZoneInactive), errors.Is(err, services.ErrSafeZoneOccupied), errors.Is(err, services.ErrDuplicateExternalRef):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
 }
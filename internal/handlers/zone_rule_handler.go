@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"disaster-response-map-api/internal/models"
+	"disaster-response-map-api/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ZoneRuleHandler struct {
+	Service services.ZoneRuleServiceInterface
+}
+
+func NewZoneRuleHandler(service services.ZoneRuleServiceInterface) *ZoneRuleHandler {
+	return &ZoneRuleHandler{Service: service}
+}
+
+// GetZoneRules godoc
+// @Summary      List Zone Rules
+// @Description  Lists the rules mapping incident type and severity to zone radius, buffer and avoidance.
+// @Tags         Admin
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {array}   models.ZoneRule
+// @Failure      401  {object}  map[string]string  "Unauthorized"
+// @Failure      500  {object}  map[string]string  "Internal Server Error"
+// @Router       /admin/zone-rules [get]
+func (h *ZoneRuleHandler) GetZoneRules(c *gin.Context) {
+	rules, err := h.Service.GetZoneRules()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch zone rules"})
+		return
+	}
+	c.JSON(http.StatusOK, rules)
+}
+
+// UpsertZoneRule godoc
+// @Summary      Create or Replace Zone Rule
+// @Description  Creates the rule for an incident type and severity, replacing any existing rule for the same pair. Omit type_id for a rule that applies to all incident types.
+// @Tags         Admin
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        rule  body      models.ZoneRule  true  "Zone rule"
+// @Success      200   {object}  models.ZoneRule
+// @Failure      400   {object}  map[string]string  "Invalid zone rule"
+// @Failure      401   {object}  map[string]string  "Unauthorized"
+// @Failure      500   {object}  map[string]string  "Internal Server Error"
+// @Router       /admin/zone-rules [put]
+func (h *ZoneRuleHandler) UpsertZoneRule(c *gin.Context) {
+	var rule models.ZoneRule
+	if err := c.ShouldBindJSON(&rule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payload"})
+		return
+	}
+
+	saved, err := h.Service.UpsertZoneRule(rule)
+	if errors.Is(err, services.ErrInvalidZoneRule) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store zone rule"})
+		return
+	}
+	c.JSON(http.StatusOK, saved)
+}
+
+// DeleteZoneRule godoc
+// @Summary      Delete Zone Rule
+// @Description  Deletes a zone rule. Zones it applied to fall back to the generic rule or the default sizing.
+// @Tags         Admin
+// @Security     BearerAuth
+// @Param        id   path  int  true  "Rule ID"
+// @Success      204
+// @Failure      400  {object}  map[string]string  "Invalid rule ID"
+// @Failure      401  {object}  map[string]string  "Unauthorized"
+// @Failure      404  {object}  map[string]string  "Zone rule not found"
+// @Failure      500  {object}  map[string]string  "Internal Server Error"
+// @Router       /admin/zone-rules/{id} [delete]
+func (h *ZoneRuleHandler) DeleteZoneRule(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid rule ID"})
+		return
+	}
+
+	err = h.Service.DeleteZoneRule(id)
+	if errors.Is(err, services.ErrZoneRuleNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Zone rule not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete zone rule"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -6,11 +6,72 @@
 // @BasePath /
 package models
 
+import (
+	"encoding/json"
+	"time"
+)
+
 // swagger:model DisasterZone
 type DisasterZone struct {
-	IncidentID   int     `json:"incident_id" example:"int"`
+	IncidentID   int     `json:"incident_id" example:"42"`
 	IncidentName string  `json:"incident_name" example:"Flood Zone"`
+	TypeID       int     `json:"type_id" example:"1"`
+	SeverityID   int     `json:"severity_id" example:"2"`
+	StatusID     int     `json:"status_id" example:"3"`
 	Latitude     float64 `json:"latitude" example:"53.349805"`
 	Longitude    float64 `json:"longitude" example:"-6.26031"`
 	Radius       float64 `json:"radius" example:"30.5"`
+	// Buffer is the extra margin in metres that routing keeps around the zone.
+	Buffer float64 `json:"buffer" example:"10"`
+	// Avoidance ranges from 0 (ignored by routing) to 1 (impassable).
+	Avoidance float64 `json:"avoidance" example:"1"`
+	// Geometry is the zone outline when one has been drawn. Zones without a
+	// geometry are treated as a circle of Radius metres around Latitude/Longitude.
+	Geometry *ZoneGeometry `json:"geometry,omitempty"`
+	// Source is "incident" for zones backed by an incident report and "manual"
+	// for hazard areas drawn through the API. Only manual zones can be edited.
+	Source string `json:"source" example:"incident"`
+}
+
+// DisasterZoneCreate is the full description of a manually drawn zone, used
+// both to create one and to replace it. Latitude and Longitude may be omitted
+// when a geometry is given; the outline's centre is used instead.
+// swagger:model DisasterZoneCreate
+type DisasterZoneCreate struct {
+	ZoneName   string        `json:"zone_name" example:"Flooded underpass"`
+	TypeID     int           `json:"type_id" example:"1"`
+	SeverityID int           `json:"severity_id" example:"2"`
+	StatusID   int           `json:"status_id" example:"3"`
+	Latitude   *float64      `json:"latitude,omitempty" example:"53.349805"`
+	Longitude  *float64      `json:"longitude,omitempty" example:"-6.26031"`
+	Geometry   *ZoneGeometry `json:"geometry,omitempty"`
+}
+
+// DisasterZonePatch holds the fields of a manually drawn zone to change; nil
+// fields are left as they are.
+// swagger:model DisasterZonePatch
+type DisasterZonePatch struct {
+	ZoneName   *string       `json:"zone_name,omitempty" example:"Flooded underpass"`
+	TypeID     *int          `json:"type_id,omitempty" example:"1"`
+	SeverityID *int          `json:"severity_id,omitempty" example:"3"`
+	StatusID   *int          `json:"status_id,omitempty" example:"4"`
+	Latitude   *float64      `json:"latitude,omitempty" example:"53.349805"`
+	Longitude  *float64      `json:"longitude,omitempty" example:"-6.26031"`
+	Geometry   *ZoneGeometry `json:"geometry,omitempty"`
+}
+
+// ZoneGeometry is a GeoJSON Polygon or MultiPolygon geometry.
+// swagger:model ZoneGeometry
+type ZoneGeometry struct {
+	Type        string          `json:"type" example:"Polygon"`
+	Coordinates json.RawMessage `json:"coordinates" swaggertype:"array,number"`
+}
+
+// DisasterZoneVersion is a disaster zone as it was between ValidFrom and
+// ValidTo. ValidTo is null for the current version.
+// swagger:model DisasterZoneVersion
+type DisasterZoneVersion struct {
+	DisasterZone
+	ValidFrom time.Time  `json:"valid_from" example:"2025-04-11T09:30:00Z"`
+	ValidTo   *time.Time `json:"valid_to" example:"2025-04-11T11:00:00Z"`
 }
@@ -0,0 +1,63 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Evacuation is an evacuation route that was issued and kept, so it can be
+// reviewed and its progress tracked.
+// swagger:model Evacuation
+type Evacuation struct {
+	EvacuationID int `json:"evacuation_id" example:"12"`
+	// IncidentID is the disaster zone being evacuated, if known.
+	IncidentID     *int       `json:"incident_id" example:"42"`
+	IncidentTypeID int        `json:"incident_type_id" example:"3"`
+	Origin         [2]float64 `json:"origin" example:"53.349805,-6.26031"`
+	Destination    [2]float64 `json:"destination" example:"53.344,-6.267"`
+	// ZoneID is the destination safe zone, nil for routes to a given point
+	// or once the zone has been deleted. SafeZone is the zone as it was when
+	// the route was issued.
+	ZoneID   *int      `json:"zone_id" example:"8"`
+	SafeZone *SafeZone `json:"safe_zone,omitempty"`
+	Mode     string    `json:"mode" example:"foot"`
+	// Distance in metres and Time in milliseconds along the route.
+	Distance float64 `json:"distance" example:"1060.8"`
+	Time     int     `json:"time" example:"780435"`
+	// Route is the GeoJSON LineString of the route.
+	Route json.RawMessage `json:"route" swaggertype:"object"`
+	// Conditions are the hazards the route was planned around, nil for
+	// evacuations kept before they were recorded.
+	Conditions *EvacuationConditions `json:"conditions,omitempty"`
+	// RequestedBy is the subject of the JWT the evacuation was saved with.
+	RequestedBy *string `json:"requested_by" example:"dispatch-3"`
+	// Status is issued, in_progress, arrived or cancelled.
+	Status    string    `json:"status" example:"issued"`
+	CreatedAt time.Time `json:"created_at" example:"2025-04-11T10:00:00Z"`
+	UpdatedAt time.Time `json:"updated_at" example:"2025-04-11T10:20:00Z"`
+}
+
+// EvacuationConditions are the hazards a kept evacuation was routed around,
+// as they were when the route was issued.
+type EvacuationConditions struct {
+	// StartZones are the active zones the origin lay in, which the route was
+	// allowed to cross.
+	StartZones []int `json:"start_zones"`
+	// HazardDistance is how far in metres the route ran inside active zones;
+	// HazardZones breaks it down by zone.
+	HazardDistance float64          `json:"hazard_distance" example:"120.5"`
+	HazardZones    []HazardExposure `json:"hazard_zones"`
+	// ActiveZones are the active disaster zones, with their sizes.
+	ActiveZones []DisasterZone `json:"active_zones"`
+}
+
+// HazardExposure is how far a route runs inside one disaster zone.
+type HazardExposure struct {
+	IncidentID int     `json:"incident_id" example:"42"`
+	Distance   float64 `json:"distance" example:"120.5"`
+}
+
+// EvacuationStatusChange moves an evacuation to a new status.
+type EvacuationStatusChange struct {
+	Status string `json:"status" example:"in_progress"`
+}
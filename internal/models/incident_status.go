@@ -0,0 +1,9 @@
+package models
+
+// IncidentStatus describes an incident status and whether zones with it are
+// treated as active by routing and evacuation.
+type IncidentStatus struct {
+	StatusID   int    `json:"status_id" example:"3"`
+	StatusName string `json:"status_name" example:"active"`
+	Active     bool   `json:"active" example:"true"`
+}
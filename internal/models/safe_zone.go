@@ -1,15 +1,78 @@
 package models
 
+import "time"
+
 type SafeZoneCreate struct {
 	ZoneName       string  `json:"zone_name"`
 	ZoneLat        float64 `json:"zone_lat"`
 	ZoneLon        float64 `json:"zone_lon"`
 	IncidentTypeID int     `json:"incident_type_id"`
+	// IncidentTypeIDs lists further incident types the zone serves.
+	// IncidentTypeID, when given, is added to them as the primary type.
+	IncidentTypeIDs []int `json:"incident_type_ids,omitempty"`
+	// Capacity is the number of people the zone can hold; nil means no limit.
+	Capacity *int `json:"capacity,omitempty"`
+	// IsOpen defaults to true when omitted.
+	IsOpen *bool `json:"is_open,omitempty"`
+	// Facilities defaults to none when omitted.
+	Facilities *SafeZoneFacilities `json:"facilities,omitempty"`
+	// ExternalRef is the zone's reference in the shelter list it was
+	// imported from; imports update the zone with a matching reference.
+	ExternalRef *string `json:"external_ref,omitempty"`
+}
+
+// SafeZoneFacilities describes what a safe zone can offer evacuees.
+type SafeZoneFacilities struct {
+	MedicalStaff     bool `json:"medical_staff" example:"true"`
+	WheelchairAccess bool `json:"wheelchair_access" example:"true"`
+	PetsAllowed      bool `json:"pets_allowed" example:"false"`
+	Water            bool `json:"water" example:"true"`
+	Power            bool `json:"power" example:"true"`
+	// Beds is the number of beds, 0 when there are none.
+	Beds int `json:"beds" example:"150"`
 }
+
 type SafeZone struct {
-	ZoneID         int     `json:"zone_id" example:"1"`
-	ZoneName       string  `json:"zone_name" example:"Safe Zone 1"`
-	ZoneLat        float64 `json:"zone_lat" example:"53.12345"`
-	ZoneLon        float64 `json:"zone_lon" example:"-6.98765"`
-	IncidentTypeID int     `json:"incident_type_id" example:"3"`
+	ZoneID   int     `json:"zone_id" example:"1"`
+	ZoneName string  `json:"zone_name" example:"Safe Zone 1"`
+	ZoneLat  float64 `json:"zone_lat" example:"53.12345"`
+	ZoneLon  float64 `json:"zone_lon" example:"-6.98765"`
+	// IncidentTypeID is the zone's primary incident type; IncidentTypeIDs
+	// holds every type it serves, including the primary one.
+	IncidentTypeID  int   `json:"incident_type_id" example:"3"`
+	IncidentTypeIDs []int `json:"incident_type_ids" example:"1,3"`
+	Capacity        *int  `json:"capacity" example:"200"`
+	Occupancy       int   `json:"occupancy" example:"120"`
+	IsOpen          bool  `json:"is_open" example:"true"`
+	// Active is false once the zone has been deactivated.
+	Active bool `json:"active" example:"true"`
+	// RemainingCapacity is Capacity less Occupancy, or nil when there is no limit.
+	RemainingCapacity *int `json:"remaining_capacity" example:"80"`
+	// CompromisedBy is the active disaster zone the safe zone lies in, if any,
+	// and CompromisedAt when that was detected.
+	CompromisedBy *int       `json:"compromised_by" example:"42"`
+	CompromisedAt *time.Time `json:"compromised_at,omitempty" example:"2025-04-11T10:00:00Z"`
+	// Facilities is what the zone can offer evacuees.
+	Facilities  SafeZoneFacilities `json:"facilities"`
+	ExternalRef *string            `json:"external_ref" example:"DCC-0042"`
+}
+
+// SafeZonePatch holds the fields to change on a safe zone; nil fields are
+// left as they are.
+type SafeZonePatch struct {
+	ZoneName       *string  `json:"zone_name,omitempty"`
+	ZoneLat        *float64 `json:"zone_lat,omitempty"`
+	ZoneLon        *float64 `json:"zone_lon,omitempty"`
+	IncidentTypeID *int     `json:"incident_type_id,omitempty"`
+	// IncidentTypeIDs replaces the incident types the zone serves.
+	IncidentTypeIDs []int `json:"incident_type_ids,omitempty"`
+	Capacity        *int  `json:"capacity,omitempty"`
+	IsOpen          *bool `json:"is_open,omitempty"`
+	// Facilities replaces all of the zone's facilities.
+	Facilities *SafeZoneFacilities `json:"facilities,omitempty"`
+}
+
+// OccupancyChange is the number of people checking in to or out of a safe zone.
+type OccupancyChange struct {
+	Count int `json:"count" example:"4"`
 }
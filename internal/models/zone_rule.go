@@ -0,0 +1,16 @@
+package models
+
+// ZoneRule maps an incident type and severity to the size of the zone drawn
+// around the incident and how strongly routing avoids it.
+// swagger:model ZoneRule
+type ZoneRule struct {
+	RuleID int `json:"rule_id" example:"1"`
+	// TypeID is the incident type the rule applies to; null applies to every type
+	// that has no rule of its own for the severity.
+	TypeID     *int    `json:"type_id" example:"4"`
+	SeverityID int     `json:"severity_id" example:"3"`
+	Radius     float64 `json:"radius" example:"500"`
+	Buffer     float64 `json:"buffer" example:"100"`
+	// Avoidance ranges from 0 (ignored by routing) to 1 (impassable).
+	Avoidance float64 `json:"avoidance" example:"1"`
+}
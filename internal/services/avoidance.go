@@ -0,0 +1,89 @@
+package services
+
+import (
+	"disaster-response-map-api/internal/models"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// AvoidanceLevel sets how strongly safe routing avoids disaster zones.
+type AvoidanceLevel string
+
+const (
+	// AvoidanceStrict makes every zone impassable, as routing did originally.
+	AvoidanceStrict AvoidanceLevel = "strict"
+	// AvoidanceBalanced uses each zone's rule avoidance with buffer rings.
+	AvoidanceBalanced AvoidanceLevel = "balanced"
+	// AvoidanceMinimal halves each zone's avoidance and ignores buffers.
+	AvoidanceMinimal AvoidanceLevel = "minimal"
+
+	// bufferRings is the number of rings a zone buffer is split into; the
+	// penalty grows from the outer edge of the buffer towards the zone.
+	bufferRings = 3
+)
+
+var ErrInvalidAvoidance = errors.New("invalid avoidance level")
+
+// ParseAvoidanceLevel reads an avoidance query value, defaulting to balanced.
+func ParseAvoidanceLevel(value string) (AvoidanceLevel, error) {
+	switch level := AvoidanceLevel(value); level {
+	case "":
+		return AvoidanceBalanced, nil
+	case AvoidanceStrict, AvoidanceBalanced, AvoidanceMinimal:
+		return level, nil
+	default:
+		return "", fmt.Errorf("%w: %q, expected strict, balanced or minimal", ErrInvalidAvoidance, value)
+	}
+}
+
+// zoneCorePriority returns the GraphHopper priority factor inside a zone,
+// where 0 makes its roads impassable and 1 leaves them unchanged.
+func zoneCorePriority(zone models.DisasterZone, level AvoidanceLevel) float64 {
+	avoidance := math.Min(math.Max(zone.Avoidance, 0), 1)
+	switch level {
+	case AvoidanceStrict:
+		return 0
+	case AvoidanceMinimal:
+		return 1 - avoidance/2
+	default:
+		return 1 - avoidance
+	}
+}
+
+// zoneRingAreas returns the concentric buffer rings around a circle zone as
+// nested discs, outermost first. GraphHopper multiplies the factors of every
+// disc a road lies in, so each disc carries the step from the priority of
+// the disc around it, and ring k of n ends up at
+// 1 - (1 - core) * (n + 1 - k) / (n + 1) counting k from the zone outwards.
+// It also returns the priority reached inside the innermost ring.
+func zoneRingAreas(zone models.DisasterZone, core float64) ([]ZoneArea, float64) {
+	rings := []ZoneArea{}
+	outer := 1.0
+	for k := bufferRings; k >= 1; k-- {
+		priority := 1 - (1-core)*float64(bufferRings+1-k)/float64(bufferRings+1)
+		polygon := BuildCirclePolygon(zone.Latitude, zone.Longitude, zone.Radius+zone.Buffer*float64(k)/float64(bufferRings))
+		rings = append(rings, ZoneArea{
+			AreaID:      zoneRingAreaID(zone.IncidentID, k),
+			IncidentIDs: []int{zone.IncidentID},
+			Ring:        k,
+			Priority:    priority / outer,
+			Geometry: map[string]interface{}{
+				"type":        "Polygon",
+				"coordinates": polygon,
+			},
+		})
+		outer = priority
+	}
+	return rings, outer
+}
+
+// zoneRingAreaID names a buffer ring area after its incident; ring 0 is the
+// zone itself.
+func zoneRingAreaID(incidentID, ring int) string {
+	if ring == 0 {
+		return DisasterZoneAreaID(incidentID)
+	}
+	return DisasterZoneAreaID(incidentID) + "_ring_" + strconv.Itoa(ring)
+}
@@ -8,32 +8,76 @@ package services
 
 import (
 	"disaster-response-map-api/internal/models"
+	"encoding/json"
 	"log"
 	"strconv"
 )
 
-func BuildDisasterZonesCustomModel(zones []models.DisasterZone) map[string]interface{} {
-	features := []map[string]interface{}{}
-	priorityRules := []map[string]interface{}{}
+// DisasterZoneAreaID returns the custom model area name used for an incident.
+// It is derived from the incident ID so it stays stable across requests.
+func DisasterZoneAreaID(incidentID int) string {
+	return "disaster_zone_" + strconv.Itoa(incidentID)
+}
+
+// ZoneArea is one area in the custom model. It normally covers a single
+// zone or one of its buffer rings, but overlapping zones may be dissolved
+// into one area.
+type ZoneArea struct {
+	AreaID      string
+	IncidentIDs []int
+	// Ring is 0 for the zone itself and counts buffer rings outwards from it.
+	Ring int
+	// Priority is the GraphHopper priority factor applied inside the area.
+	Priority float64
+	Geometry map[string]interface{}
+}
+
+// ZoneAreas returns the areas for each zone at the given avoidance level: the
+// zone itself, named after its incident, and for circle zones with a buffer,
+// the rings around it. Zones the level leaves unpenalised are skipped.
+func ZoneAreas(zones []models.DisasterZone, level AvoidanceLevel) []ZoneArea {
+	areas := []ZoneArea{}
 	for _, zone := range zones {
-		log.Println("Building zone for incident: ", zone.IncidentID)
-		polygon := BuildCirclePolygon(zone.Latitude, zone.Longitude, zone.Radius)
-		if len(polygon) == 0 {
+		core := zoneCorePriority(zone, level)
+		if core >= 1 {
+			continue
+		}
+		geometry := zoneAreaGeometry(zone, zone.Radius)
+		if geometry == nil {
 			continue
 		}
-		featureID := "disaster_zone_" + strconv.Itoa(zone.IncidentID)
+		priority := core
+		if level != AvoidanceMinimal && zone.Buffer > 0 && drawnGeometry(zone) == nil {
+			rings, innermost := zoneRingAreas(zone, core)
+			areas = append(areas, rings...)
+			// The zone lies inside every ring disc, so only the remaining
+			// step down to its own priority is applied here.
+			priority = core / innermost
+		}
+		areas = append(areas, ZoneArea{
+			AreaID:      DisasterZoneAreaID(zone.IncidentID),
+			IncidentIDs: []int{zone.IncidentID},
+			Priority:    priority,
+			Geometry:    geometry,
+		})
+	}
+	return areas
+}
+
+func BuildDisasterZonesCustomModel(areas []ZoneArea) map[string]interface{} {
+	features := []map[string]interface{}{}
+	priorityRules := []map[string]interface{}{}
+	for _, area := range areas {
+		log.Println("Building zone area for incidents: ", area.IncidentIDs)
 		feature := map[string]interface{}{
-			"id":   featureID,
-			"type": "Feature",
-			"geometry": map[string]interface{}{
-				"type":        "Polygon",
-				"coordinates": polygon,
-			},
+			"id":       area.AreaID,
+			"type":     "Feature",
+			"geometry": area.Geometry,
 		}
 		features = append(features, feature)
 		priorityRules = append(priorityRules, map[string]interface{}{
-			"if":          "in_" + featureID,
-			"multiply_by": 0,
+			"if":          "in_" + area.AreaID,
+			"multiply_by": area.Priority,
 		})
 	}
 
@@ -45,3 +89,65 @@ func BuildDisasterZonesCustomModel(zones []models.DisasterZone) map[string]inter
 		},
 	}
 }
+
+// drawnGeometry returns a zone's drawn Polygon/MultiPolygon outline, or nil
+// when it has none or the outline fails validation.
+func drawnGeometry(zone models.DisasterZone) map[string]interface{} {
+	if zone.Geometry == nil {
+		return nil
+	}
+	if _, err := ParseZoneGeometry(*zone.Geometry); err != nil {
+		log.Printf("Ignoring geometry for incident %d: %v", zone.IncidentID, err)
+		return nil
+	}
+	return map[string]interface{}{
+		"type":        zone.Geometry.Type,
+		"coordinates": zone.Geometry.Coordinates,
+	}
+}
+
+// zoneAreaGeometry returns the GeoJSON geometry of a zone: its drawn outline
+// passed through unchanged, or otherwise a circle of the given radius.
+func zoneAreaGeometry(zone models.DisasterZone, radius float64) map[string]interface{} {
+	if geometry := drawnGeometry(zone); geometry != nil {
+		return geometry
+	}
+	polygon := BuildCirclePolygon(zone.Latitude, zone.Longitude, radius)
+	if len(polygon) == 0 || len(polygon[0]) == 0 {
+		return nil
+	}
+	return map[string]interface{}{
+		"type":        "Polygon",
+		"coordinates": polygon,
+	}
+}
+
+// zoneAreasJSON encodes the full area of each zone, radius plus buffer, as
+// a JSON array of {incident_id, geometry} objects for use as a PostGIS query
+// argument; see hazardsCTE.
+func zoneAreasJSON(zones []models.DisasterZone) (string, error) {
+	areas := []map[string]interface{}{}
+	for _, zone := range zones {
+		if geometry := zoneAreaGeometry(zone, zone.Radius+zone.Buffer); geometry != nil {
+			areas = append(areas, map[string]interface{}{
+				"incident_id": zone.IncidentID,
+				"geometry":    geometry,
+			})
+		}
+	}
+	encoded, err := json.Marshal(areas)
+	if err != nil {
+		return "", err
+	}
+	return string(encoded), nil
+}
+
+// hazardsCTE returns a "hazard" common table expression with an incident_id
+// and geog row for each area in the zoneAreasJSON argument at param.
+func hazardsCTE(param string) string {
+	return `hazard AS (
+            SELECT (a->>'incident_id')::int AS incident_id,
+                   ST_GeomFromGeoJSON(a->>'geometry')::geography AS geog
+            FROM jsonb_array_elements(` + param + `::jsonb) AS a
+        )`
+}
@@ -9,68 +9,579 @@ package services
 import (
 	"database/sql"
 	"disaster-response-map-api/internal/models"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+var (
+	// ErrDisasterZoneNotFound is returned when no zone matches the requested ID.
+	ErrDisasterZoneNotFound = errors.New("disaster zone not found")
+	// ErrInvalidDisasterZone is returned when a zone payload fails validation.
+	ErrInvalidDisasterZone = errors.New("invalid disaster zone")
+	// ErrZoneNotEditable is returned when trying to change a zone that is backed by an incident report.
+	ErrZoneNotEditable = errors.New("disaster zone is managed by its incident report")
+	// ErrInvalidStatusTransition is returned when a zone cannot move to the requested status.
+	ErrInvalidStatusTransition = errors.New("invalid status transition")
+)
+
+// StatusActive is the incident status whose zones are active when no other
+// statuses are configured.
+const StatusActive = 3
+
+// ParseStatusIDs reads a comma-separated list of status IDs such as "3,4".
+// An empty value gives an empty list.
+func ParseStatusIDs(value string) ([]int, error) {
+	statuses := []int{}
+	if strings.TrimSpace(value) == "" {
+		return statuses, nil
+	}
+	for _, part := range strings.Split(value, ",") {
+		status, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || status <= 0 {
+			return nil, fmt.Errorf("invalid status ID %q", part)
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+const (
+	ZoneSourceIncident = "incident"
+	ZoneSourceManual   = "manual"
 )
 
 type DisasterZoneServiceInterface interface {
-	GetDisasterZones() ([]models.DisasterZone, error)
-	GetActiveDisasterZones() ([]models.DisasterZone, error)
+	GetDisasterZones(filter DisasterZoneFilter) ([]models.DisasterZone, error)
+	GetActiveDisasterZones(statuses []int) ([]models.DisasterZone, error)
+	ActiveStatuses() []int
+	GetIncidentStatuses() ([]models.IncidentStatus, error)
+	GetDisasterZone(incidentID int) (models.DisasterZone, error)
+	SetZoneGeometry(incidentID int, geometry models.ZoneGeometry) error
+	ClearZoneGeometry(incidentID int) error
+	CreateDisasterZone(zone models.DisasterZoneCreate) (models.DisasterZone, error)
+	ReplaceDisasterZone(zoneID int, zone models.DisasterZoneCreate) (models.DisasterZone, error)
+	PatchDisasterZone(zoneID int, patch models.DisasterZonePatch) (models.DisasterZone, error)
+	DeleteDisasterZone(zoneID int) error
+	GetDisasterZoneHistory(zoneID int) ([]models.DisasterZoneVersion, error)
+	DissolveDisasterZones(zones []models.DisasterZone, level AvoidanceLevel) ([]ZoneArea, error)
 }
 
 type DisasterZoneService struct {
 	DB *sql.DB
+	// DefaultActiveStatuses are the statuses whose zones are avoided when a
+	// caller does not choose its own.
+	DefaultActiveStatuses []int
+	// SimplifyTolerance (metres) and MaxAreas control DissolveDisasterZones.
+	SimplifyTolerance float64
+	MaxAreas          int
+	// OnChange, if set, is called after a zone is created, changed or deleted
+	// through this service.
+	OnChange func()
 }
 
 func NewDisasterZoneService(db *sql.DB) *DisasterZoneService {
-	return &DisasterZoneService{DB: db}
+	return &DisasterZoneService{
+		DB:                    db,
+		DefaultActiveStatuses: []int{StatusActive},
+		SimplifyTolerance:     defaultSimplifyTolerance,
+		MaxAreas:              defaultMaxZoneAreas,
+	}
+}
+
+const (
+	disasterZoneColumns = `z.incident_id, z.incident_name, z.type_id, z.severity_id, z.status_id, z.latitude, z.longitude, z.geometry, z.source`
+	// sizedZoneColumns adds the size the zone rules give a zone, computed by
+	// the database for live zones and recorded with each history version.
+	sizedZoneColumns          = disasterZoneColumns + `, z.radius_m, z.buffer_m, z.avoidance`
+	disasterZoneSelect        = `SELECT ` + sizedZoneColumns + ` FROM sized_disaster_zone_view z`
+	disasterZoneHistorySelect = `SELECT ` + sizedZoneColumns + ` FROM disaster_zone_history z`
+)
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
 }
 
-func (s *DisasterZoneService) GetDisasterZones() ([]models.DisasterZone, error) {
-	rows, err := s.DB.Query("SELECT t.type_name AS incident_name, i.latitude as latitude, i.longitude as longitude, i.severity_id as severity_id FROM incident i JOIN incident_type t ON i.type_id = t.type_id; ")
+// scanDisasterZone reads a row starting with disasterZoneColumns; extra
+// receives any columns selected after them.
+func scanDisasterZone(row rowScanner, extra ...interface{}) (models.DisasterZone, error) {
+	var dz models.DisasterZone
+	var geometry []byte
+	dest := append([]interface{}{&dz.IncidentID, &dz.IncidentName, &dz.TypeID, &dz.SeverityID, &dz.StatusID, &dz.Latitude, &dz.Longitude, &geometry, &dz.Source}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return models.DisasterZone{}, err
+	}
+	if geometry != nil {
+		dz.Geometry = &models.ZoneGeometry{}
+		if err := json.Unmarshal(geometry, dz.Geometry); err != nil {
+			return models.DisasterZone{}, fmt.Errorf("failed to decode geometry for incident %d: %w", dz.IncidentID, err)
+		}
+	}
+	return dz, nil
+}
+
+// scanSizedDisasterZone reads a row starting with sizedZoneColumns.
+func scanSizedDisasterZone(row rowScanner, extra ...interface{}) (models.DisasterZone, error) {
+	var radius, buffer, avoidance float64
+	zone, err := scanDisasterZone(row, append([]interface{}{&radius, &buffer, &avoidance}, extra...)...)
+	if err != nil {
+		return models.DisasterZone{}, err
+	}
+	zone.Radius, zone.Buffer, zone.Avoidance = radius, buffer, avoidance
+	return zone, nil
+}
+
+// queryDisasterZones runs a query of disasterZoneSelect or
+// disasterZoneHistorySelect.
+func (s *DisasterZoneService) queryDisasterZones(query string, args ...interface{}) ([]models.DisasterZone, error) {
+	rows, err := s.DB.Query(query, args...)
 	if err != nil {
 		log.Printf("Error querying disaster zones: %v", err)
 		return nil, err
 	}
 	defer rows.Close()
 	var zones []models.DisasterZone
-	increment := 1
 	for rows.Next() {
-		var dz models.DisasterZone
-		var severityID int
-		if err := rows.Scan(&dz.IncidentName, &dz.Latitude, &dz.Longitude, &severityID); err != nil {
+		dz, err := scanSizedDisasterZone(rows)
+		if err != nil {
 			log.Printf("Error scanning row: %v", err)
 			continue
 		}
-		dz.IncidentID = increment
-		increment++
-
-		dz.Radius = float64(severityID) * (8 + 10)
 		zones = append(zones, dz)
 	}
 	return zones, nil
 }
 
-func (s *DisasterZoneService) GetActiveDisasterZones() ([]models.DisasterZone, error) {
-	rows, err := s.DB.Query("SELECT t.type_name AS incident_name, i.latitude as latitude, i.longitude as longitude, i.severity_id as severity_id FROM incident i JOIN incident_type t ON i.type_id = t.type_id WHERE status_id = 3; ")
+// GetDisasterZones lists the zones matching the filter. Spatial conditions
+// apply to the ground the zone covers: its drawn outline, or the disc of its
+// radius plus buffer. They are evaluated on the indexed geog column of the
+// zone's history, whose open version always matches the live zone. With AsOf
+// set the zones are read from their recorded history instead of the live
+// tables, with the sizes they had at the time.
+func (s *DisasterZoneService) GetDisasterZones(filter DisasterZoneFilter) ([]models.DisasterZone, error) {
+	var where whereClause
+	query := disasterZoneSelect
+	if filter.AsOf != nil {
+		query = disasterZoneHistorySelect
+		asOf := where.arg(*filter.AsOf)
+		where.add(fmt.Sprintf("z.valid_from <= %[1]s AND (z.valid_to IS NULL OR z.valid_to > %[1]s)", asOf))
+		where.addSpatial(filter.SpatialFilter, "z.geog")
+	} else if filter.BBox != nil || filter.Near != nil {
+		extent := whereClause{args: where.args}
+		extent.add("h.valid_to IS NULL")
+		extent.addSpatial(filter.SpatialFilter, "h.geog")
+		where.args = extent.args
+		where.add("z.incident_id IN (SELECT h.incident_id FROM disaster_zone_history h" + extent.String() + ")")
+	}
+	if filter.TypeID != nil {
+		where.add("z.type_id = " + where.arg(*filter.TypeID))
+	}
+	if filter.MinSeverity != nil {
+		where.add("z.severity_id >= " + where.arg(*filter.MinSeverity))
+	}
+	if filter.StatusID != nil {
+		where.add("z.status_id = " + where.arg(*filter.StatusID))
+	}
+	query += where.String() + " ORDER BY z.incident_id"
+	return s.queryDisasterZones(query, where.args...)
+}
+
+// GetActiveDisasterZones returns the zones whose status is one of statuses,
+// or one of the default active statuses when statuses is empty.
+func (s *DisasterZoneService) GetActiveDisasterZones(statuses []int) ([]models.DisasterZone, error) {
+	if len(statuses) == 0 {
+		statuses = s.ActiveStatuses()
+	}
+	return s.queryDisasterZones(disasterZoneSelect+" WHERE z.status_id = ANY($1) ORDER BY z.incident_id", pq.Array(statuses))
+}
+
+// ActiveStatuses returns the default active statuses.
+func (s *DisasterZoneService) ActiveStatuses() []int {
+	if len(s.DefaultActiveStatuses) == 0 {
+		return []int{StatusActive}
+	}
+	return s.DefaultActiveStatuses
+}
+
+// GetIncidentStatuses lists the statuses in the incident_status table, plus
+// any other status configured as active, flagging those that are active by
+// default.
+func (s *DisasterZoneService) GetIncidentStatuses() ([]models.IncidentStatus, error) {
+	active := map[int]bool{}
+	for _, status := range s.ActiveStatuses() {
+		active[status] = true
+	}
+
+	rows, err := s.DB.Query(`SELECT status_id, status_name FROM incident_status ORDER BY status_id`)
 	if err != nil {
-		log.Printf("Error querying disaster zones: %v", err)
+		log.Printf("Error querying incident statuses: %v", err)
 		return nil, err
 	}
 	defer rows.Close()
-	var zones []models.DisasterZone
-	increment := 1
+	statuses := []models.IncidentStatus{}
 	for rows.Next() {
-		var dz models.DisasterZone
-		var severityID int
-		if err := rows.Scan(&dz.IncidentName, &dz.Latitude, &dz.Longitude, &severityID); err != nil {
-			log.Printf("Error scanning row: %v", err)
-			continue
+		var status models.IncidentStatus
+		if err := rows.Scan(&status.StatusID, &status.StatusName); err != nil {
+			return nil, err
 		}
-		dz.IncidentID = increment
-		increment++
+		status.Active = active[status.StatusID]
+		delete(active, status.StatusID)
+		statuses = append(statuses, status)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
 
-		dz.Radius = float64(severityID) * (8 + 10)
-		zones = append(zones, dz)
+	for id := range active {
+		statuses = append(statuses, models.IncidentStatus{StatusID: id, Active: true})
 	}
-	return zones, nil
+	sort.SliceStable(statuses, func(i, j int) bool { return statuses[i].StatusID < statuses[j].StatusID })
+	return statuses, nil
+}
+
+func (s *DisasterZoneService) GetDisasterZone(incidentID int) (models.DisasterZone, error) {
+	row := s.DB.QueryRow(disasterZoneSelect+" WHERE z.incident_id = $1", incidentID)
+	dz, err := scanSizedDisasterZone(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.DisasterZone{}, ErrDisasterZoneNotFound
+	}
+	if err != nil {
+		log.Printf("Error querying disaster zone %d: %v", incidentID, err)
+		return models.DisasterZone{}, err
+	}
+	return dz, nil
+}
+
+// SetZoneGeometry stores a Polygon or MultiPolygon outline for a zone,
+// replacing any outline it already had.
+func (s *DisasterZoneService) SetZoneGeometry(incidentID int, geometry models.ZoneGeometry) error {
+	if _, err := ParseZoneGeometry(geometry); err != nil {
+		return err
+	}
+	geometryJSON, err := json.Marshal(geometry)
+	if err != nil {
+		return err
+	}
+
+	result, err := s.DB.Exec(`UPDATE manual_zone SET geometry = $2, updated_at = now() WHERE zone_id = $1`, incidentID, geometryJSON)
+	if err != nil {
+		return fmt.Errorf("failed to store zone geometry: %w", err)
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected > 0 {
+		s.changed()
+		return nil
+	}
+
+	query := `
+        INSERT INTO zone_geometry (incident_id, geometry)
+        SELECT incident_id, $2 FROM incident WHERE incident_id = $1
+        ON CONFLICT (incident_id) DO UPDATE SET geometry = EXCLUDED.geometry, updated_at = now()
+    `
+	result, err = s.DB.Exec(query, incidentID, geometryJSON)
+	if err != nil {
+		return fmt.Errorf("failed to store zone geometry: %w", err)
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return ErrDisasterZoneNotFound
+	}
+	s.changed()
+	return nil
+}
+
+// ClearZoneGeometry removes a zone's outline so it is treated as a circle again.
+func (s *DisasterZoneService) ClearZoneGeometry(incidentID int) error {
+	result, err := s.DB.Exec(`UPDATE manual_zone SET geometry = NULL, updated_at = now() WHERE zone_id = $1 AND geometry IS NOT NULL`, incidentID)
+	if err != nil {
+		return fmt.Errorf("failed to delete zone geometry: %w", err)
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected > 0 {
+		s.changed()
+		return nil
+	}
+
+	result, err = s.DB.Exec(`DELETE FROM zone_geometry WHERE incident_id = $1`, incidentID)
+	if err != nil {
+		return fmt.Errorf("failed to delete zone geometry: %w", err)
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return ErrDisasterZoneNotFound
+	}
+	s.changed()
+	return nil
+}
+
+// CreateDisasterZone stores a hazard area drawn by a coordinator. New zones
+// take the first active status unless another status is given.
+func (s *DisasterZoneService) CreateDisasterZone(zone models.DisasterZoneCreate) (models.DisasterZone, error) {
+	if zone.StatusID == 0 {
+		zone.StatusID = s.ActiveStatuses()[0]
+	}
+	statuses, err := s.zoneStatusOrder()
+	if err != nil {
+		return models.DisasterZone{}, err
+	}
+	switch position := statusPosition(statuses, zone.StatusID); {
+	case position < 0:
+		return models.DisasterZone{}, fmt.Errorf("%w: unknown status_id %d", ErrInvalidDisasterZone, zone.StatusID)
+	case position == len(statuses)-1:
+		return models.DisasterZone{}, fmt.Errorf("%w: a new zone cannot start in the final status %d", ErrInvalidDisasterZone, zone.StatusID)
+	}
+	lat, lon, geometryJSON, err := s.prepareManualZone(zone)
+	if err != nil {
+		return models.DisasterZone{}, err
+	}
+
+	var zoneID int
+	query := `
+        INSERT INTO manual_zone (zone_name, type_id, severity_id, status_id, latitude, longitude, geometry)
+        VALUES ($1, $2, $3, $4, $5, $6, $7)
+        RETURNING zone_id
+    `
+	err = s.DB.QueryRow(query, zone.ZoneName, zone.TypeID, zone.SeverityID, zone.StatusID, lat, lon, geometryJSON).Scan(&zoneID)
+	if err != nil {
+		return models.DisasterZone{}, fmt.Errorf("failed to insert disaster zone: %w", err)
+	}
+	s.changed()
+	return s.GetDisasterZone(zoneID)
+}
+
+// ReplaceDisasterZone overwrites every field of a manual zone. A zone replaced
+// without a geometry goes back to being a circle.
+func (s *DisasterZoneService) ReplaceDisasterZone(zoneID int, zone models.DisasterZoneCreate) (models.DisasterZone, error) {
+	current, err := s.getManualZone(zoneID)
+	if err != nil {
+		return models.DisasterZone{}, err
+	}
+	if zone.StatusID == 0 {
+		zone.StatusID = current.StatusID
+	}
+	if err := s.checkStatusTransition(current.StatusID, zone.StatusID); err != nil {
+		return models.DisasterZone{}, err
+	}
+	lat, lon, geometryJSON, err := s.prepareManualZone(zone)
+	if err != nil {
+		return models.DisasterZone{}, err
+	}
+
+	query := `
+        UPDATE manual_zone
+        SET zone_name = $2, type_id = $3, severity_id = $4, status_id = $5,
+            latitude = $6, longitude = $7, geometry = $8, updated_at = now()
+        WHERE zone_id = $1
+    `
+	_, err = s.DB.Exec(query, zoneID, zone.ZoneName, zone.TypeID, zone.SeverityID, zone.StatusID, lat, lon, geometryJSON)
+	if err != nil {
+		return models.DisasterZone{}, fmt.Errorf("failed to update disaster zone: %w", err)
+	}
+	s.changed()
+	return s.GetDisasterZone(zoneID)
+}
+
+// PatchDisasterZone changes the given fields of a manual zone.
+func (s *DisasterZoneService) PatchDisasterZone(zoneID int, patch models.DisasterZonePatch) (models.DisasterZone, error) {
+	current, err := s.getManualZone(zoneID)
+	if err != nil {
+		return models.DisasterZone{}, err
+	}
+
+	merged := models.DisasterZoneCreate{
+		ZoneName:   current.IncidentName,
+		TypeID:     current.TypeID,
+		SeverityID: current.SeverityID,
+		StatusID:   current.StatusID,
+		Latitude:   &current.Latitude,
+		Longitude:  &current.Longitude,
+		Geometry:   current.Geometry,
+	}
+	if patch.ZoneName != nil {
+		merged.ZoneName = *patch.ZoneName
+	}
+	if patch.TypeID != nil {
+		merged.TypeID = *patch.TypeID
+	}
+	if patch.SeverityID != nil {
+		merged.SeverityID = *patch.SeverityID
+	}
+	if patch.StatusID != nil {
+		merged.StatusID = *patch.StatusID
+	}
+	if patch.Latitude != nil {
+		merged.Latitude = patch.Latitude
+	}
+	if patch.Longitude != nil {
+		merged.Longitude = patch.Longitude
+	}
+	if patch.Geometry != nil {
+		merged.Geometry = patch.Geometry
+		// Recentre on the new outline unless a point was given with it.
+		if patch.Latitude == nil && patch.Longitude == nil {
+			merged.Latitude, merged.Longitude = nil, nil
+		}
+	}
+	return s.ReplaceDisasterZone(zoneID, merged)
+}
+
+// DeleteDisasterZone removes a manual zone.
+func (s *DisasterZoneService) DeleteDisasterZone(zoneID int) error {
+	if _, err := s.getManualZone(zoneID); err != nil {
+		return err
+	}
+	if _, err := s.DB.Exec(`DELETE FROM manual_zone WHERE zone_id = $1`, zoneID); err != nil {
+		return fmt.Errorf("failed to delete disaster zone: %w", err)
+	}
+	s.changed()
+	return nil
+}
+
+func (s *DisasterZoneService) changed() {
+	if s.OnChange != nil {
+		s.OnChange()
+	}
+}
+
+// getManualZone loads a zone and checks that it can be edited through the API.
+func (s *DisasterZoneService) getManualZone(zoneID int) (models.DisasterZone, error) {
+	zone, err := s.GetDisasterZone(zoneID)
+	if err != nil {
+		return models.DisasterZone{}, err
+	}
+	if zone.Source != ZoneSourceManual {
+		return models.DisasterZone{}, ErrZoneNotEditable
+	}
+	return zone, nil
+}
+
+// prepareManualZone validates a manual zone and returns its centre point and
+// encoded geometry, deriving the centre from the geometry when none is given.
+func (s *DisasterZoneService) prepareManualZone(zone models.DisasterZoneCreate) (lat, lon float64, geometryJSON []byte, err error) {
+	if strings.TrimSpace(zone.ZoneName) == "" {
+		return 0, 0, nil, fmt.Errorf("%w: zone_name is required", ErrInvalidDisasterZone)
+	}
+	if zone.SeverityID <= 0 {
+		return 0, 0, nil, fmt.Errorf("%w: severity_id must be positive", ErrInvalidDisasterZone)
+	}
+	switch {
+	case zone.Latitude != nil && zone.Longitude != nil:
+		lat, lon = *zone.Latitude, *zone.Longitude
+		if lat < -90 || lat > 90 || lon < -180 || lon > 180 {
+			return 0, 0, nil, fmt.Errorf("%w: latitude/longitude out of range", ErrInvalidDisasterZone)
+		}
+	case zone.Latitude != nil || zone.Longitude != nil:
+		return 0, 0, nil, fmt.Errorf("%w: latitude and longitude must be given together", ErrInvalidDisasterZone)
+	case zone.Geometry == nil:
+		return 0, 0, nil, fmt.Errorf("%w: latitude/longitude or geometry is required", ErrInvalidDisasterZone)
+	}
+
+	if zone.Geometry != nil {
+		polygons, err := ParseZoneGeometry(*zone.Geometry)
+		if err != nil {
+			return 0, 0, nil, fmt.Errorf("%w: %v", ErrInvalidDisasterZone, err)
+		}
+		if zone.Latitude == nil {
+			lat, lon = PolygonsCentre(polygons)
+		}
+		if geometryJSON, err = json.Marshal(zone.Geometry); err != nil {
+			return 0, 0, nil, err
+		}
+	}
+
+	typeExists, err := incidentTypeExists(s.DB, zone.TypeID)
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	if !typeExists {
+		return 0, 0, nil, fmt.Errorf("%w: unknown type_id %d", ErrInvalidDisasterZone, zone.TypeID)
+	}
+	return lat, lon, geometryJSON, nil
+}
+
+func incidentTypeExists(db *sql.DB, typeID int) (bool, error) {
+	var exists bool
+	if err := db.QueryRow(`SELECT EXISTS (SELECT 1 FROM incident_type WHERE type_id = $1)`, typeID).Scan(&exists); err != nil {
+		return false, fmt.Errorf("failed to check incident type: %w", err)
+	}
+	return exists, nil
+}
+
+// zoneStatusOrder lists the statuses a manual zone may have: those of
+// GetIncidentStatuses, in status_id order. Zones only move forward through
+// them, and the last is final.
+func (s *DisasterZoneService) zoneStatusOrder() ([]int, error) {
+	statuses, err := s.GetIncidentStatuses()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load incident statuses: %w", err)
+	}
+	order := make([]int, len(statuses))
+	for i, status := range statuses {
+		order[i] = status.StatusID
+	}
+	return order, nil
+}
+
+// statusPosition returns the index of status in order, or -1 when it is not
+// listed.
+func statusPosition(order []int, status int) int {
+	for i, id := range order {
+		if id == status {
+			return i
+		}
+	}
+	return -1
+}
+
+func (s *DisasterZoneService) checkStatusTransition(from, to int) error {
+	if from == to {
+		return nil
+	}
+	statuses, err := s.zoneStatusOrder()
+	if err != nil {
+		return err
+	}
+	target := statusPosition(statuses, to)
+	if target < 0 {
+		return fmt.Errorf("%w: unknown status_id %d", ErrInvalidDisasterZone, to)
+	}
+	if target < statusPosition(statuses, from) {
+		return fmt.Errorf("%w: %d to %d", ErrInvalidStatusTransition, from, to)
+	}
+	return nil
+}
+
+// GetDisasterZoneHistory returns every recorded version of a zone, oldest
+// first, each with the size it had at the time.
+func (s *DisasterZoneService) GetDisasterZoneHistory(zoneID int) ([]models.DisasterZoneVersion, error) {
+	query := `SELECT ` + sizedZoneColumns + `, z.valid_from, z.valid_to FROM disaster_zone_history z WHERE z.incident_id = $1 ORDER BY z.valid_from, z.history_id`
+	rows, err := s.DB.Query(query, zoneID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query zone history: %w", err)
+	}
+	defer rows.Close()
+
+	var versions []models.DisasterZoneVersion
+	for rows.Next() {
+		var version models.DisasterZoneVersion
+		var validTo sql.NullTime
+		zone, err := scanSizedDisasterZone(rows, &version.ValidFrom, &validTo)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan zone history: %w", err)
+		}
+		version.DisasterZone = zone
+		if validTo.Valid {
+			version.ValidTo = &validTo.Time
+		}
+		versions = append(versions, version)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read zone history: %w", err)
+	}
+	if len(versions) == 0 {
+		return nil, ErrDisasterZoneNotFound
+	}
+	return versions, nil
 }
@@ -0,0 +1,311 @@
+package services
+
+import (
+	"disaster-response-map-api/internal/models"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ErrInvalidEvacuationPlan is returned when a plan request is malformed.
+var ErrInvalidEvacuationPlan = errors.New("invalid evacuation plan")
+
+// maxPlanOrigins bounds the origins of one plan, keeping the travel time
+// matrix within what GraphHopper accepts in a single request.
+const maxPlanOrigins = 200
+
+// planRouteWorkers is how many evacuation routes a plan fetches at once.
+const planRouteWorkers = 8
+
+// PlanOrigin is a group of people evacuating from the same place, such as an
+// address or a population cluster.
+type PlanOrigin struct {
+	// ID identifies the origin in the plan; it defaults to its position in
+	// the request, starting at 1.
+	ID     string     `json:"id" example:"block-12"`
+	Point  [2]float64 `json:"point" example:"[53.349805, -6.26031]"`
+	People int        `json:"people" example:"40"`
+}
+
+// EvacuationPlan assigns the people of many origins to safe zones.
+type EvacuationPlan struct {
+	Origins []OriginPlan `json:"origins"`
+	Zones   []ZoneLoad   `json:"safe_zones"`
+	// People is the number of evacuees, of whom Assigned were given a safe
+	// zone and Unassigned could not be placed.
+	People     int `json:"people" example:"240"`
+	Assigned   int `json:"assigned" example:"240"`
+	Unassigned int `json:"unassigned" example:"0"`
+	// TotalTime is the travel time of all assigned evacuees added together, in
+	// milliseconds.
+	TotalTime int64 `json:"total_time" example:"187200000"`
+	// Mode is how evacuees travel.
+	Mode TravelMode `json:"mode" example:"foot"`
+}
+
+// OriginPlan is where the people of one origin are sent.
+type OriginPlan struct {
+	PlanOrigin
+	// StartZones are the active disaster zones the origin lies in.
+	StartZones  []int            `json:"start_zones,omitempty" example:"42"`
+	Assignments []PlanAssignment `json:"assignments"`
+	// Unassigned is how many of the origin's people have no safe zone.
+	Unassigned int `json:"unassigned" example:"0"`
+}
+
+// PlanAssignment sends some of an origin's people to one safe zone.
+type PlanAssignment struct {
+	ZoneID int `json:"zone_id" example:"8"`
+	People int `json:"people" example:"40"`
+	// Distance in metres and Time in milliseconds from the travel time matrix.
+	Distance float64 `json:"distance" example:"1060.8"`
+	Time     int     `json:"time" example:"780000"`
+	// Route is the evacuation route, absent when it could not be fetched;
+	// Error then says why.
+	Route          *RoutePath `json:"route,omitempty"`
+	HazardDistance float64    `json:"hazard_distance" example:"0"`
+	Error          string     `json:"error,omitempty"`
+}
+
+// ZoneLoad is how many people a plan sends to a safe zone.
+type ZoneLoad struct {
+	SafeZone models.SafeZone `json:"safe_zone"`
+	Assigned int             `json:"assigned" example:"120"`
+	// RemainingCapacity is the room left once the plan is carried out, or nil
+	// when the zone has no limit.
+	RemainingCapacity *int `json:"remaining_capacity" example:"40"`
+}
+
+// validatePlanOrigins checks the origins of a plan and fills in missing IDs.
+func validatePlanOrigins(origins []PlanOrigin) error {
+	switch {
+	case len(origins) == 0:
+		return fmt.Errorf("%w: origins are required", ErrInvalidEvacuationPlan)
+	case len(origins) > maxPlanOrigins:
+		return fmt.Errorf("%w: at most %d origins are allowed", ErrInvalidEvacuationPlan, maxPlanOrigins)
+	}
+	seen := make(map[string]bool, len(origins))
+	for i := range origins {
+		origin := &origins[i]
+		origin.ID = strings.TrimSpace(origin.ID)
+		if origin.ID == "" {
+			origin.ID = strconv.Itoa(i + 1)
+		}
+		switch {
+		case seen[origin.ID]:
+			return fmt.Errorf("%w: origin %q is listed twice", ErrInvalidEvacuationPlan, origin.ID)
+		case origin.Point[0] < -90 || origin.Point[0] > 90 || origin.Point[1] < -180 || origin.Point[1] > 180:
+			return fmt.Errorf("%w: origin %q point out of range", ErrInvalidEvacuationPlan, origin.ID)
+		case origin.People <= 0:
+			return fmt.Errorf("%w: origin %q must have at least one person", ErrInvalidEvacuationPlan, origin.ID)
+		}
+		seen[origin.ID] = true
+	}
+	return nil
+}
+
+// PlanEvacuation assigns the people of every origin to the safe zones nearest
+// the origins' centre, so that no zone takes more than its remaining capacity
+// and the travel time of all evacuees added together is as small as it can
+// be. An origin may be split over several zones. People who cannot be placed,
+// because the zones are full or out of reach, are reported as unassigned.
+// Routes avoid active disaster zones other than the ones the origins lie in.
+// The plan does not reserve any capacity.
+func (s *EvacuationService) PlanEvacuation(origins []PlanOrigin, incidentTypeID int, opts EvacuationOptions) (EvacuationPlan, error) {
+	if err := validatePlanOrigins(origins); err != nil {
+		return EvacuationPlan{}, err
+	}
+	activeZones, err := s.DZ.GetActiveDisasterZones(nil)
+	if err != nil {
+		return EvacuationPlan{}, fmt.Errorf("failed to load active disaster zones: %v", err)
+	}
+
+	points := make([][2]float64, len(origins))
+	people := make([]int, len(origins))
+	var centre [2]float64
+	for i, origin := range origins {
+		points[i], people[i] = origin.Point, origin.People
+		centre[0] += origin.Point[0] / float64(len(origins))
+		centre[1] += origin.Point[1] / float64(len(origins))
+	}
+
+	limit := s.PlanCandidates
+	if limit <= 0 {
+		limit = defaultPlanCandidates
+	}
+	safeZones, err := s.getNearestSafeZones(centre, incidentTypeID, opts, activeZones, limit)
+	if err != nil {
+		return EvacuationPlan{}, err
+	}
+	zonePoints := make([][2]float64, len(safeZones))
+	capacity := make([]int, len(safeZones))
+	total := 0
+	for _, count := range people {
+		total += count
+	}
+	for j, safeZone := range safeZones {
+		zonePoints[j] = [2]float64{safeZone.ZoneLat, safeZone.ZoneLon}
+		capacity[j] = total
+		if safeZone.RemainingCapacity != nil {
+			capacity[j] = *safeZone.RemainingCapacity
+		}
+	}
+
+	// Origins in the same start zones avoid the same areas, so each such
+	// group gets its own travel times, matching the routes it is given.
+	groups, err := s.planOriginGroups(origins, activeZones)
+	if err != nil {
+		return EvacuationPlan{}, err
+	}
+	cost := make([][]int, len(origins))
+	distance := make([][]*float64, len(origins))
+	for _, group := range groups {
+		groupPoints := make([][2]float64, len(group.origins))
+		for k, i := range group.origins {
+			groupPoints[k] = points[i]
+		}
+		matrix, err := s.GH.GetTravelMatrix(groupPoints, zonePoints, opts.travelMode(), group.areas)
+		if err != nil {
+			return EvacuationPlan{}, fmt.Errorf("failed to compute travel times: %v", err)
+		}
+		for k, i := range group.origins {
+			cost[i] = make([]int, len(safeZones))
+			distance[i] = make([]*float64, len(safeZones))
+			for j := range safeZones {
+				cost[i][j] = -1
+				if j < len(matrix.Times[k]) && matrix.Times[k][j] != nil {
+					cost[i][j] = int(math.Ceil(*matrix.Times[k][j]))
+				}
+				if j < len(matrix.Distances[k]) {
+					distance[i][j] = matrix.Distances[k][j]
+				}
+			}
+		}
+	}
+	assigned := assignEvacuees(people, capacity, cost)
+
+	plan := EvacuationPlan{People: total, Origins: make([]OriginPlan, len(origins)), Mode: opts.travelMode()}
+	load := make([]int, len(safeZones))
+	originAreas := make([][]ZoneArea, len(origins))
+	startZones := make([][]int, len(origins))
+	for _, group := range groups {
+		for _, i := range group.origins {
+			originAreas[i], startZones[i] = group.areas, group.startZones
+		}
+	}
+	for i, origin := range origins {
+		originPlan := OriginPlan{PlanOrigin: origin, StartZones: startZones[i], Assignments: []PlanAssignment{}, Unassigned: origin.People}
+		for j, count := range assigned[i] {
+			if count == 0 {
+				continue
+			}
+			assignment := PlanAssignment{ZoneID: safeZones[j].ZoneID, People: count, Time: cost[i][j] * 1000}
+			if distance[i][j] != nil {
+				assignment.Distance = *distance[i][j]
+			}
+			originPlan.Assignments = append(originPlan.Assignments, assignment)
+			originPlan.Unassigned -= count
+			load[j] += count
+			plan.TotalTime += int64(count) * int64(assignment.Time)
+		}
+		plan.Assigned += origin.People - originPlan.Unassigned
+		plan.Origins[i] = originPlan
+	}
+	plan.Unassigned = plan.People - plan.Assigned
+
+	for j, safeZone := range safeZones {
+		if load[j] == 0 {
+			continue
+		}
+		zoneLoad := ZoneLoad{SafeZone: safeZone, Assigned: load[j]}
+		if safeZone.RemainingCapacity != nil {
+			remaining := *safeZone.RemainingCapacity - load[j]
+			zoneLoad.RemainingCapacity = &remaining
+		}
+		plan.Zones = append(plan.Zones, zoneLoad)
+	}
+	if plan.Zones == nil {
+		plan.Zones = []ZoneLoad{}
+	}
+
+	s.routePlan(&plan, safeZones, activeZones, originAreas)
+	return plan, nil
+}
+
+// planOriginGroup is the origins of a plan lying in the same active zones,
+// and the areas their routes avoid.
+type planOriginGroup struct {
+	origins    []int
+	startZones []int
+	areas      []ZoneArea
+}
+
+// planOriginGroups groups the origins of a plan, by index, on the active
+// zones they lie in.
+func (s *EvacuationService) planOriginGroups(origins []PlanOrigin, activeZones []models.DisasterZone) ([]planOriginGroup, error) {
+	var groups []planOriginGroup
+	byStart := make(map[string]int)
+	for i, origin := range origins {
+		var startZones []int
+		for _, zone := range activeZones {
+			if ZoneContainsPoint(zone, origin.Point[0], origin.Point[1]) {
+				startZones = append(startZones, zone.IncidentID)
+			}
+		}
+		key := fmt.Sprint(startZones)
+		g, ok := byStart[key]
+		if !ok {
+			areas, _, err := s.evacuationAreas(activeZones, origin.Point)
+			if err != nil {
+				return nil, err
+			}
+			g = len(groups)
+			byStart[key] = g
+			groups = append(groups, planOriginGroup{startZones: startZones, areas: areas})
+		}
+		groups[g].origins = append(groups[g].origins, i)
+	}
+	return groups, nil
+}
+
+// routePlan fetches the route of every assignment in the plan, avoiding the
+// areas of its origin's group. A route that cannot be fetched is recorded on
+// its assignment rather than failing the plan.
+func (s *EvacuationService) routePlan(plan *EvacuationPlan, safeZones []models.SafeZone, activeZones []models.DisasterZone, originAreas [][]ZoneArea) {
+	zonePoints := make(map[int][2]float64, len(safeZones))
+	for _, safeZone := range safeZones {
+		zonePoints[safeZone.ZoneID] = [2]float64{safeZone.ZoneLat, safeZone.ZoneLon}
+	}
+
+	var wg sync.WaitGroup
+	workers := make(chan struct{}, planRouteWorkers)
+	for i := range plan.Origins {
+		origin := &plan.Origins[i]
+		for k := range origin.Assignments {
+			assignment := &origin.Assignments[k]
+			wg.Add(1)
+			workers <- struct{}{}
+			go func(areas []ZoneArea) {
+				defer func() {
+					<-workers
+					wg.Done()
+				}()
+				route, err := s.GH.GetEvacuationRoute(origin.Point, zonePoints[assignment.ZoneID], plan.Mode, areas)
+				switch {
+				case err != nil:
+					assignment.Error = err.Error()
+				case len(route.Paths) == 0:
+					assignment.Error = "no route found"
+				default:
+					path := route.Paths[0]
+					assignment.Route = &path
+					assignment.HazardDistance, _ = pathHazardExposure(route.Paths, activeZones)
+				}
+			}(originAreas[i])
+		}
+	}
+	wg.Wait()
+}
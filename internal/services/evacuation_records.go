@@ -0,0 +1,260 @@
+package services
+
+import (
+	"database/sql"
+	"disaster-response-map-api/internal/models"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrEvacuationNotFound = errors.New("evacuation not found")
+	// ErrInvalidEvacuationStatus is returned for a status that does not exist.
+	ErrInvalidEvacuationStatus = errors.New("invalid evacuation status")
+)
+
+const (
+	EvacuationIssued     = "issued"
+	EvacuationInProgress = "in_progress"
+	EvacuationArrived    = "arrived"
+	EvacuationCancelled  = "cancelled"
+)
+
+// evacuationStatusTransitions lists the statuses a kept evacuation may move to
+// from each status. Arrived and cancelled evacuations are final.
+var evacuationStatusTransitions = map[string][]string{
+	EvacuationIssued:     {EvacuationInProgress, EvacuationArrived, EvacuationCancelled},
+	EvacuationInProgress: {EvacuationArrived, EvacuationCancelled},
+	EvacuationArrived:    {},
+	EvacuationCancelled:  {},
+}
+
+const evacuationColumns = `evacuation_id, incident_id, incident_type_id, origin_lat, origin_lon, dest_lat, dest_lon,
+        zone_id, safe_zone, mode, distance, travel_time, route, conditions, requested_by, status, created_at, updated_at`
+
+// scanEvacuation reads a row of evacuationColumns.
+func scanEvacuation(row rowScanner) (models.Evacuation, error) {
+	var evacuation models.Evacuation
+	var incidentID, zoneID sql.NullInt64
+	var safeZone, route, conditions []byte
+	var requestedBy sql.NullString
+	if err := row.Scan(&evacuation.EvacuationID, &incidentID, &evacuation.IncidentTypeID,
+		&evacuation.Origin[0], &evacuation.Origin[1], &evacuation.Destination[0], &evacuation.Destination[1],
+		&zoneID, &safeZone, &evacuation.Mode, &evacuation.Distance, &evacuation.Time, &route, &conditions, &requestedBy,
+		&evacuation.Status, &evacuation.CreatedAt, &evacuation.UpdatedAt); err != nil {
+		return models.Evacuation{}, err
+	}
+	if incidentID.Valid {
+		id := int(incidentID.Int64)
+		evacuation.IncidentID = &id
+	}
+	if zoneID.Valid {
+		id := int(zoneID.Int64)
+		evacuation.ZoneID = &id
+	}
+	if safeZone != nil {
+		evacuation.SafeZone = &models.SafeZone{}
+		if err := json.Unmarshal(safeZone, evacuation.SafeZone); err != nil {
+			return models.Evacuation{}, fmt.Errorf("invalid safe zone snapshot: %w", err)
+		}
+	}
+	if route != nil {
+		evacuation.Route = json.RawMessage(route)
+	}
+	if conditions != nil {
+		evacuation.Conditions = &models.EvacuationConditions{}
+		if err := json.Unmarshal(conditions, evacuation.Conditions); err != nil {
+			return models.Evacuation{}, fmt.Errorf("invalid evacuation conditions: %w", err)
+		}
+	}
+	if requestedBy.Valid {
+		evacuation.RequestedBy = &requestedBy.String
+	}
+	return evacuation, nil
+}
+
+// NewEvacuation describes an evacuation route from dangerPoint, returned by
+// GetEvacuationRoute, for keeping with SaveEvacuation. The destination is the
+// chosen safe zone, or safePoint when one was given. The evacuation belongs to
+// the first zone the danger point lies in, if any. The zones the route was
+// planned around are kept as its conditions.
+func NewEvacuation(dangerPoint [2]float64, safePoint *[2]float64, incidentTypeID int, route EvacuationRouteResponse) models.Evacuation {
+	evacuation := models.Evacuation{
+		IncidentTypeID: incidentTypeID,
+		Origin:         dangerPoint,
+		Mode:           string(route.Mode),
+		Status:         EvacuationIssued,
+		Conditions: &models.EvacuationConditions{
+			StartZones:     route.StartZones,
+			HazardDistance: route.HazardDistance,
+			HazardZones:    route.HazardZones,
+			ActiveZones:    route.ActiveZones,
+		},
+	}
+	if evacuation.Conditions.StartZones == nil {
+		evacuation.Conditions.StartZones = []int{}
+	}
+	if evacuation.Conditions.HazardZones == nil {
+		evacuation.Conditions.HazardZones = []HazardExposure{}
+	}
+	if evacuation.Conditions.ActiveZones == nil {
+		evacuation.Conditions.ActiveZones = []models.DisasterZone{}
+	}
+	if len(route.StartZones) > 0 {
+		incidentID := route.StartZones[0]
+		evacuation.IncidentID = &incidentID
+	}
+	if route.SafeZone != nil {
+		safeZone := *route.SafeZone
+		evacuation.SafeZone = &safeZone
+		evacuation.ZoneID = &safeZone.ZoneID
+		evacuation.Destination = [2]float64{safeZone.ZoneLat, safeZone.ZoneLon}
+	} else if safePoint != nil {
+		evacuation.Destination = *safePoint
+	}
+	if len(route.Paths) > 0 {
+		path := route.Paths[0]
+		evacuation.Distance, evacuation.Time = path.Distance, path.Time
+		if geometry, err := json.Marshal(path.Points); err == nil {
+			evacuation.Route = geometry
+		}
+	}
+	return evacuation
+}
+
+// SaveEvacuation keeps an issued evacuation and returns it with its ID.
+func (s *EvacuationService) SaveEvacuation(evacuation models.Evacuation) (models.Evacuation, error) {
+	var safeZone interface{}
+	if evacuation.SafeZone != nil {
+		encoded, err := json.Marshal(evacuation.SafeZone)
+		if err != nil {
+			return models.Evacuation{}, fmt.Errorf("failed to encode safe zone: %v", err)
+		}
+		safeZone = string(encoded)
+	}
+	var route interface{}
+	if evacuation.Route != nil {
+		route = string(evacuation.Route)
+	}
+	var conditions interface{}
+	if evacuation.Conditions != nil {
+		encoded, err := json.Marshal(evacuation.Conditions)
+		if err != nil {
+			return models.Evacuation{}, fmt.Errorf("failed to encode evacuation conditions: %v", err)
+		}
+		conditions = string(encoded)
+	}
+
+	row := s.DB.QueryRow(`
+        INSERT INTO evacuation (incident_id, incident_type_id, origin_lat, origin_lon, dest_lat, dest_lon,
+                                zone_id, safe_zone, mode, distance, travel_time, route, conditions, requested_by)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+        RETURNING `+evacuationColumns,
+		evacuation.IncidentID, evacuation.IncidentTypeID, evacuation.Origin[0], evacuation.Origin[1],
+		evacuation.Destination[0], evacuation.Destination[1], evacuation.ZoneID, safeZone,
+		evacuation.Mode, evacuation.Distance, evacuation.Time, route, conditions, evacuation.RequestedBy)
+	saved, err := scanEvacuation(row)
+	if err != nil {
+		return models.Evacuation{}, fmt.Errorf("failed to save evacuation: %v", err)
+	}
+	return saved, nil
+}
+
+// GetEvacuation returns a kept evacuation by ID.
+func (s *EvacuationService) GetEvacuation(evacuationID int) (models.Evacuation, error) {
+	row := s.DB.QueryRow(`SELECT `+evacuationColumns+` FROM evacuation WHERE evacuation_id = $1`, evacuationID)
+	evacuation, err := scanEvacuation(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Evacuation{}, ErrEvacuationNotFound
+	}
+	if err != nil {
+		return models.Evacuation{}, fmt.Errorf("failed to query evacuation: %w", err)
+	}
+	return evacuation, nil
+}
+
+// GetEvacuations lists kept evacuations, newest first.
+func (s *EvacuationService) GetEvacuations(filter EvacuationFilter) ([]models.Evacuation, error) {
+	var where whereClause
+	if filter.IncidentID != nil {
+		where.add("incident_id = " + where.arg(*filter.IncidentID))
+	}
+	if filter.ZoneID != nil {
+		where.add("zone_id = " + where.arg(*filter.ZoneID))
+	}
+	if filter.Status != nil {
+		if _, ok := evacuationStatusTransitions[*filter.Status]; !ok {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidEvacuationStatus, *filter.Status)
+		}
+		where.add("status = " + where.arg(*filter.Status))
+	}
+	query := `SELECT ` + evacuationColumns + ` FROM evacuation` + where.String() + ` ORDER BY created_at DESC, evacuation_id DESC`
+	rows, err := s.DB.Query(query, where.args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query evacuations: %v", err)
+	}
+	defer rows.Close()
+
+	evacuations := []models.Evacuation{}
+	for rows.Next() {
+		evacuation, err := scanEvacuation(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan evacuation: %v", err)
+		}
+		evacuations = append(evacuations, evacuation)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query evacuations: %v", err)
+	}
+	return evacuations, nil
+}
+
+// SetEvacuationStatus moves a kept evacuation to a new status. Evacuations
+// only move forward: issued, in_progress, then arrived, or cancelled at any
+// point before arriving.
+func (s *EvacuationService) SetEvacuationStatus(evacuationID int, status string) (models.Evacuation, error) {
+	if _, ok := evacuationStatusTransitions[status]; !ok {
+		return models.Evacuation{}, fmt.Errorf("%w: %q, expected issued, in_progress, arrived or cancelled", ErrInvalidEvacuationStatus, status)
+	}
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return models.Evacuation{}, fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	var current string
+	err = tx.QueryRow(`SELECT status FROM evacuation WHERE evacuation_id = $1 FOR UPDATE`, evacuationID).Scan(&current)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Evacuation{}, ErrEvacuationNotFound
+	}
+	if err != nil {
+		return models.Evacuation{}, fmt.Errorf("failed to query evacuation: %v", err)
+	}
+	if err := checkEvacuationTransition(current, status); err != nil {
+		return models.Evacuation{}, err
+	}
+
+	row := tx.QueryRow(`UPDATE evacuation SET status = $2, updated_at = now() WHERE evacuation_id = $1 RETURNING `+evacuationColumns, evacuationID, status)
+	evacuation, err := scanEvacuation(row)
+	if err != nil {
+		return models.Evacuation{}, fmt.Errorf("failed to update evacuation: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return models.Evacuation{}, fmt.Errorf("failed to update evacuation: %v", err)
+	}
+	return evacuation, nil
+}
+
+func checkEvacuationTransition(from, to string) error {
+	if from == to {
+		return nil
+	}
+	for _, allowed := range evacuationStatusTransitions[from] {
+		if allowed == to {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s to %s", ErrInvalidStatusTransition, from, to)
+}
@@ -8,50 +8,277 @@ package services
 
 import (
 	"database/sql"
+	"disaster-response-map-api/internal/models"
+	"errors"
 	"fmt"
+	"log"
+	"sort"
+	"sync"
 )
 
+// ErrNoSafeZoneAvailable is returned when no safe zone can take an evacuee.
+var ErrNoSafeZoneAvailable = errors.New("no suitable safe zone available")
+
+// EvacuationOptions narrows the safe zones an evacuation may be sent to and
+// sets how evacuees travel there.
+type EvacuationOptions struct {
+	// Needs are facilities the chosen safe zone must offer.
+	Needs []Need
+	// Mode is how evacuees travel, foot when empty.
+	Mode TravelMode
+}
+
+// travelMode returns the mode evacuees travel in.
+func (opts EvacuationOptions) travelMode() TravelMode {
+	if opts.Mode == "" {
+		return ModeFoot
+	}
+	return opts.Mode
+}
+
+// zoneNeeds returns the needs a safe zone must meet. Wheelchair users always
+// need a wheelchair accessible zone.
+func (opts EvacuationOptions) zoneNeeds() []Need {
+	if opts.travelMode() != ModeWheelchair {
+		return opts.Needs
+	}
+	for _, need := range opts.Needs {
+		if need == NeedWheelchair {
+			return opts.Needs
+		}
+	}
+	return append(append([]Need{}, opts.Needs...), NeedWheelchair)
+}
+
+// defaultEvacuationCandidates is how many of the nearest safe zones are
+// routed to when choosing an evacuation destination.
+const defaultEvacuationCandidates = 5
+
+// defaultPlanCandidates is how many safe zones nearest the centre of an
+// evacuation plan's origins are considered by the plan.
+const defaultPlanCandidates = 25
+
 type EvacuationService struct {
 	DB *sql.DB
 	GH GraphHopperServiceInterface
+	DZ DisasterZoneServiceInterface
+	// Candidates is how many of the nearest safe zones are compared by route time.
+	Candidates int
+	// PlanCandidates is how many safe zones an evacuation plan spreads people over.
+	PlanCandidates int
+	// Traffic, when set, adjusts the travel time of routes driven by car or
+	// bus for live traffic.
+	Traffic TrafficServiceInterface
 }
 
-func NewEvacuationService(db *sql.DB, gh GraphHopperServiceInterface) *EvacuationService {
+func NewEvacuationService(db *sql.DB, gh GraphHopperServiceInterface, dz DisasterZoneServiceInterface) *EvacuationService {
 	return &EvacuationService{
-		DB: db,
-		GH: gh,
+		DB:             db,
+		GH:             gh,
+		DZ:             dz,
+		Candidates:     defaultEvacuationCandidates,
+		PlanCandidates: defaultPlanCandidates,
 	}
 }
 
-func (s *EvacuationService) getNearestSafeZone(dangerPoint [2]float64, incidentTypeID int) (zoneLat, zoneLon float64, err error) {
+// getNearestSafeZones returns up to limit open safe zones with room left,
+// nearest first, that serve the incident type and do not lie inside any of
+// activeZones. The zones are checked live because the stored compromised flag
+// is refreshed periodically and may lag an incident change; their areas are
+// passed to PostGIS so the KNN ordering can use the index. Zones flagged as
+// compromised are skipped as well, in case the flag is newer than
+// activeZones. Zones must also meet every need in opts, including those
+// implied by the travel mode.
+func (s *EvacuationService) getNearestSafeZones(dangerPoint [2]float64, incidentTypeID int, opts EvacuationOptions, activeZones []models.DisasterZone, limit int) ([]models.SafeZone, error) {
+	needs, err := needsCondition(opts.zoneNeeds())
+	if err != nil {
+		return nil, err
+	}
+	areas, err := zoneAreasJSON(activeZones)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode active disaster zones: %v", err)
+	}
+
 	query := `
-        SELECT zone_lat, zone_lon
+        WITH ` + hazardsCTE("$4") + `
+        SELECT ` + safeZoneColumns + `
         FROM safe_zone
-        WHERE incident_type_id = $1
-        ORDER BY acos(
-            sin($2 * pi()/180) * sin(zone_lat * pi()/180) +
-            cos($2 * pi()/180) * cos(zone_lat * pi()/180) *
-            cos(($3 - zone_lon) * pi()/180)
-        ) * 6371000 ASC
-        LIMIT 1
+        WHERE ` + servesIncidentType("$1") + `
+          AND active
+          AND is_open
+          AND (capacity IS NULL OR occupancy < capacity)
+          AND compromised_by IS NULL
+          AND NOT EXISTS (SELECT 1 FROM hazard h WHERE ST_Intersects(h.geog, safe_zone.geog))` + needs + `
+        ORDER BY geog <-> ` + geographyPoint("$2", "$3") + `
+        LIMIT $5
     `
-	row := s.DB.QueryRow(query, incidentTypeID, dangerPoint[0], dangerPoint[1])
-	if err := row.Scan(&zoneLat, &zoneLon); err != nil {
-		return 0, 0, fmt.Errorf("failed to find nearest safe zone: %v", err)
+	rows, err := s.DB.Query(query, incidentTypeID, dangerPoint[0], dangerPoint[1], areas, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find nearest safe zones: %v", err)
+	}
+	defer rows.Close()
+
+	var safeZones []models.SafeZone
+	for rows.Next() {
+		safeZone, err := scanSafeZone(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan safe zone: %v", err)
+		}
+		safeZones = append(safeZones, safeZone)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to find nearest safe zones: %v", err)
+	}
+	if len(safeZones) == 0 {
+		return nil, ErrNoSafeZoneAvailable
+	}
+	return safeZones, nil
+}
+
+// splitStartZones separates the active zones one of the points lies in,
+// which evacuees must be allowed to leave, from the zones to avoid. The
+// incident IDs of the start zones are returned.
+func splitStartZones(activeZones []models.DisasterZone, points ...[2]float64) ([]models.DisasterZone, []int) {
+	var avoided []models.DisasterZone
+	var startZones []int
+	for _, zone := range activeZones {
+		contains := zoneContains(zone)
+		start := false
+		for _, point := range points {
+			if contains(point[0], point[1]) {
+				start = true
+				break
+			}
+		}
+		if start {
+			startZones = append(startZones, zone.IncidentID)
+			continue
+		}
+		avoided = append(avoided, zone)
+	}
+	return avoided, startZones
+}
+
+// evacuationAreas returns the areas an evacuation from the points avoids:
+// every active zone, made impassable whatever its severity, except the start
+// zones one of the points lies in, whose incident IDs are returned too.
+func (s *EvacuationService) evacuationAreas(activeZones []models.DisasterZone, points ...[2]float64) ([]ZoneArea, []int, error) {
+	avoided, startZones := splitStartZones(activeZones, points...)
+	areas, err := s.DZ.DissolveDisasterZones(avoided, AvoidanceStrict)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to prepare disaster zones: %v", err)
 	}
-	return zoneLat, zoneLon, nil
+	return areas, startZones, nil
 }
 
-func (s *EvacuationService) GetEvacuationRoute(dangerPoint [2]float64, incidentTypeID int, safePoint *[2]float64) (EvacuationRouteResponse, error) {
-	var destination [2]float64
-	if safePoint == nil {
-		lat, lon, err := s.getNearestSafeZone(dangerPoint, incidentTypeID)
+// GetEvacuationRoute routes from the danger point to safePoint, or when it is
+// nil to the safe zone that is fastest to reach. The straight-line nearest
+// zones are routed to first, since the closest one may be cut off by a river
+// or motorway; all of them are returned as ranked alternatives. opts.Needs
+// only applies to choosing a zone, while opts.Mode sets how the routes are
+// travelled. Routes avoid every active disaster zone except
+// the ones the danger point lies in, and report how far they still run inside
+// any of them. Routes driven by car or bus also carry a traffic-adjusted ETA
+// when a traffic service is set.
+func (s *EvacuationService) GetEvacuationRoute(dangerPoint [2]float64, incidentTypeID int, safePoint *[2]float64, opts EvacuationOptions) (EvacuationRouteResponse, error) {
+	activeZones, err := s.DZ.GetActiveDisasterZones(nil)
+	if err != nil {
+		return EvacuationRouteResponse{}, fmt.Errorf("failed to load active disaster zones: %v", err)
+	}
+	areas, startZones, err := s.evacuationAreas(activeZones, dangerPoint)
+	if err != nil {
+		return EvacuationRouteResponse{}, err
+	}
+
+	if safePoint != nil {
+		route, err := s.GH.GetEvacuationRoute(dangerPoint, *safePoint, opts.travelMode(), areas)
 		if err != nil {
 			return EvacuationRouteResponse{}, err
 		}
-		destination = [2]float64{lat, lon}
-	} else {
-		destination = *safePoint
+		route.Mode = opts.travelMode()
+		route.StartZones, route.ActiveZones = startZones, activeZones
+		route.HazardDistance, route.HazardZones = pathHazardExposure(route.Paths, activeZones)
+		s.addTraffic(&route)
+		return route, nil
+	}
+
+	limit := s.Candidates
+	if limit <= 0 {
+		limit = defaultEvacuationCandidates
+	}
+	safeZones, err := s.getNearestSafeZones(dangerPoint, incidentTypeID, opts, activeZones, limit)
+	if err != nil {
+		return EvacuationRouteResponse{}, err
+	}
+
+	routes := make([]EvacuationRouteResponse, len(safeZones))
+	candidates := make([]EvacuationCandidate, len(safeZones))
+	var wg sync.WaitGroup
+	for i, safeZone := range safeZones {
+		wg.Add(1)
+		go func(i int, safeZone models.SafeZone) {
+			defer wg.Done()
+			candidate := EvacuationCandidate{
+				SafeZone:             safeZone,
+				StraightLineDistance: HaversineDistance(dangerPoint[0], dangerPoint[1], safeZone.ZoneLat, safeZone.ZoneLon),
+			}
+			route, err := s.GH.GetEvacuationRoute(dangerPoint, [2]float64{safeZone.ZoneLat, safeZone.ZoneLon}, opts.travelMode(), areas)
+			switch {
+			case err != nil:
+				candidate.Error = err.Error()
+			case len(route.Paths) == 0:
+				candidate.Error = "no route found"
+			default:
+				candidate.Reachable = true
+				candidate.Distance = route.Paths[0].Distance
+				candidate.Time = route.Paths[0].Time
+				route.HazardDistance, route.HazardZones = pathHazardExposure(route.Paths, activeZones)
+				candidate.HazardDistance = route.HazardDistance
+			}
+			routes[i], candidates[i] = route, candidate
+		}(i, safeZone)
+	}
+	wg.Wait()
+
+	best := -1
+	for i, candidate := range candidates {
+		if candidate.Reachable && (best < 0 || candidate.Time < candidates[best].Time) {
+			best = i
+		}
+	}
+	if best < 0 {
+		return EvacuationRouteResponse{}, fmt.Errorf("%w: none of the %d nearest safe zones can be reached: %s", ErrNoSafeZoneAvailable, len(candidates), candidates[0].Error)
+	}
+
+	route := routes[best]
+	safeZone := candidates[best].SafeZone
+	route.SafeZone = &safeZone
+	route.Mode = opts.travelMode()
+	route.StartZones, route.ActiveZones = startZones, activeZones
+	// Reachable zones fastest first, then the rest nearest first.
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].Reachable != candidates[j].Reachable {
+			return candidates[i].Reachable
+		}
+		return candidates[i].Reachable && candidates[i].Time < candidates[j].Time
+	})
+	route.Alternatives = candidates
+	s.addTraffic(&route)
+	return route, nil
+}
+
+// addTraffic adjusts the route's travel time for live traffic when it is
+// driven and a traffic service is set. Only the chosen route is sampled; the
+// alternatives keep their free-flow times. Traffic is best effort, so a
+// failed lookup is logged and the route returned without it.
+func (s *EvacuationService) addTraffic(route *EvacuationRouteResponse) {
+	if s.Traffic == nil || !route.Mode.drivesInTraffic() || len(route.Paths) == 0 {
+		return
+	}
+	eta, err := EstimateTrafficETA(s.Traffic, route.Paths[0])
+	if err != nil {
+		log.Printf("Error estimating traffic along evacuation route: %v", err)
+		return
 	}
-	return s.GH.GetEvacuationRoute(dangerPoint, destination)
+	route.Traffic = &eta
 }
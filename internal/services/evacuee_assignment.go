@@ -0,0 +1,105 @@
+package services
+
+import "math"
+
+// flowEdge is an edge of the assignment flow network; rev is the index of
+// the reverse edge in graph[to].
+type flowEdge struct {
+	to, capacity, cost, rev int
+}
+
+// assignEvacuees spreads people[i] evacuees from each origin over the
+// destinations so that destination j takes at most capacity[j], as many
+// people as possible are placed, and the sum of people times cost is as small
+// as it can be. cost[i][j] is negative when j cannot be reached from i. The
+// result holds how many people from each origin go to each destination.
+//
+// It solves the transportation problem as a minimum-cost flow, augmenting
+// along the cheapest remaining path until no evacuee can be placed.
+func assignEvacuees(people, capacity []int, cost [][]int) [][]int {
+	n, m := len(people), len(capacity)
+	source, sink := n+m, n+m+1
+	graph := make([][]flowEdge, n+m+2)
+	addEdge := func(from, to, capacity, cost int) int {
+		graph[from] = append(graph[from], flowEdge{to: to, capacity: capacity, cost: cost, rev: len(graph[to])})
+		graph[to] = append(graph[to], flowEdge{to: from, cost: -cost, rev: len(graph[from]) - 1})
+		return len(graph[from]) - 1
+	}
+
+	for i, count := range people {
+		if count > 0 {
+			addEdge(source, i, count, 0)
+		}
+	}
+	for j, room := range capacity {
+		if room > 0 {
+			addEdge(n+j, sink, room, 0)
+		}
+	}
+	edges := make([][]int, n)
+	for i := range people {
+		edges[i] = make([]int, m)
+		for j := range capacity {
+			edges[i][j] = -1
+			if cost[i][j] >= 0 && people[i] > 0 {
+				edges[i][j] = addEdge(i, n+j, people[i], cost[i][j])
+			}
+		}
+	}
+
+	dist := make([]int, len(graph))
+	prevNode := make([]int, len(graph))
+	prevEdge := make([]int, len(graph))
+	inQueue := make([]bool, len(graph))
+	for {
+		// Cheapest path from source to sink over the residual network. Reverse
+		// edges carry negative costs, so Bellman-Ford (as a queue) is used.
+		for v := range dist {
+			dist[v] = math.MaxInt
+		}
+		dist[source] = 0
+		queue := []int{source}
+		inQueue[source] = true
+		for len(queue) > 0 {
+			u := queue[0]
+			queue = queue[1:]
+			inQueue[u] = false
+			for k, e := range graph[u] {
+				if e.capacity > 0 && dist[u]+e.cost < dist[e.to] {
+					dist[e.to] = dist[u] + e.cost
+					prevNode[e.to], prevEdge[e.to] = u, k
+					if !inQueue[e.to] {
+						queue = append(queue, e.to)
+						inQueue[e.to] = true
+					}
+				}
+			}
+		}
+		if dist[sink] == math.MaxInt {
+			break
+		}
+
+		push := math.MaxInt
+		for v := sink; v != source; v = prevNode[v] {
+			if c := graph[prevNode[v]][prevEdge[v]].capacity; c < push {
+				push = c
+			}
+		}
+		for v := sink; v != source; v = prevNode[v] {
+			e := &graph[prevNode[v]][prevEdge[v]]
+			e.capacity -= push
+			graph[v][e.rev].capacity += push
+		}
+	}
+
+	assigned := make([][]int, n)
+	for i := range people {
+		assigned[i] = make([]int, m)
+		for j, k := range edges[i] {
+			if k >= 0 {
+				assigned[i][j] = people[i] - graph[i][k].capacity
+			}
+		}
+	}
+	return assigned
+}
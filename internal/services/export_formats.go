@@ -0,0 +1,259 @@
+package services
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+type gpxDocument struct {
+	XMLName   xml.Name   `xml:"gpx"`
+	Version   string     `xml:"version,attr"`
+	Creator   string     `xml:"creator,attr"`
+	Xmlns     string     `xml:"xmlns,attr"`
+	Name      string     `xml:"metadata>name"`
+	Waypoints []gpxPoint `xml:"wpt"`
+	Tracks    []gpxTrack `xml:"trk"`
+}
+
+type gpxPoint struct {
+	Lat  float64 `xml:"lat,attr"`
+	Lon  float64 `xml:"lon,attr"`
+	Name string  `xml:"name,omitempty"`
+	Desc string  `xml:"desc,omitempty"`
+}
+
+type gpxTrack struct {
+	Name     string       `xml:"name,omitempty"`
+	Desc     string       `xml:"desc,omitempty"`
+	Segments []gpxSegment `xml:"trkseg"`
+}
+
+type gpxSegment struct {
+	Points []gpxPoint `xml:"trkpt"`
+}
+
+type kmlDocument struct {
+	XMLName    xml.Name       `xml:"kml"`
+	Xmlns      string         `xml:"xmlns,attr"`
+	Name       string         `xml:"Document>name"`
+	Placemarks []kmlPlacemark `xml:"Document>Placemark"`
+}
+
+type kmlPlacemark struct {
+	Name          string            `xml:"name,omitempty"`
+	Description   string            `xml:"description,omitempty"`
+	Point         *kmlCoordinates   `xml:"Point,omitempty"`
+	LineString    *kmlCoordinates   `xml:"LineString,omitempty"`
+	Polygon       *kmlPolygon       `xml:"Polygon,omitempty"`
+	MultiGeometry *kmlMultiGeometry `xml:"MultiGeometry,omitempty"`
+}
+
+type kmlCoordinates struct {
+	Coordinates string `xml:"coordinates"`
+}
+
+type kmlPolygon struct {
+	Outer kmlCoordinates   `xml:"outerBoundaryIs>LinearRing"`
+	Inner []kmlCoordinates `xml:"innerBoundaryIs>LinearRing"`
+}
+
+type kmlMultiGeometry struct {
+	Polygons []kmlPolygon `xml:"Polygon"`
+}
+
+// FeatureCollectionToGPX encodes features as GPX 1.1. Points become waypoints,
+// lines become tracks with a waypoint per turn instruction, and polygons become
+// closed tracks with one segment per ring, since GPX has no area type.
+func FeatureCollectionToGPX(collection FeatureCollection, name string) ([]byte, error) {
+	doc := gpxDocument{
+		Version: "1.1",
+		Creator: "gpsd-map-mgmt",
+		Xmlns:   "http://www.topografix.com/GPX/1/1",
+		Name:    name,
+	}
+	for _, feature := range collection.Features {
+		title, desc := featureName(feature), featureDescription(feature)
+		switch feature.Geometry.Type {
+		case "Point":
+			var position []float64
+			if err := decodeCoordinates(feature.Geometry.Coordinates, &position); err != nil {
+				return nil, err
+			}
+			doc.Waypoints = append(doc.Waypoints, gpxPoint{Lat: position[1], Lon: position[0], Name: title, Desc: desc})
+		case "LineString":
+			var line [][]float64
+			if err := decodeCoordinates(feature.Geometry.Coordinates, &line); err != nil {
+				return nil, err
+			}
+			doc.Tracks = append(doc.Tracks, gpxTrack{Name: title, Desc: desc, Segments: []gpxSegment{gpxRing(line)}})
+			for _, instruction := range featureInstructions(feature) {
+				if len(instruction.Interval) == 0 || instruction.Interval[0] >= len(line) {
+					continue
+				}
+				position := line[instruction.Interval[0]]
+				doc.Waypoints = append(doc.Waypoints, gpxPoint{Lat: position[1], Lon: position[0], Name: instruction.Text, Desc: instruction.StreetName})
+			}
+		case "Polygon", "MultiPolygon":
+			polygons, err := featurePolygons(feature)
+			if err != nil {
+				return nil, err
+			}
+			track := gpxTrack{Name: title, Desc: desc}
+			for _, polygon := range polygons {
+				for _, ring := range polygon {
+					track.Segments = append(track.Segments, gpxRing(ring))
+				}
+			}
+			doc.Tracks = append(doc.Tracks, track)
+		}
+	}
+	return marshalXML(doc)
+}
+
+// FeatureCollectionToKML encodes features as KML placemarks. Lines also get a
+// point placemark for each turn instruction.
+func FeatureCollectionToKML(collection FeatureCollection, name string) ([]byte, error) {
+	doc := kmlDocument{Xmlns: "http://www.opengis.net/kml/2.2", Name: name}
+	for _, feature := range collection.Features {
+		placemark := kmlPlacemark{Name: featureName(feature), Description: featureDescription(feature)}
+		switch feature.Geometry.Type {
+		case "Point":
+			var position []float64
+			if err := decodeCoordinates(feature.Geometry.Coordinates, &position); err != nil {
+				return nil, err
+			}
+			placemark.Point = &kmlCoordinates{Coordinates: kmlPositions([][]float64{position})}
+		case "LineString":
+			var line [][]float64
+			if err := decodeCoordinates(feature.Geometry.Coordinates, &line); err != nil {
+				return nil, err
+			}
+			placemark.LineString = &kmlCoordinates{Coordinates: kmlPositions(line)}
+			for _, instruction := range featureInstructions(feature) {
+				if len(instruction.Interval) == 0 || instruction.Interval[0] >= len(line) {
+					continue
+				}
+				doc.Placemarks = append(doc.Placemarks, kmlPlacemark{
+					Name:        instruction.Text,
+					Description: instruction.StreetName,
+					Point:       &kmlCoordinates{Coordinates: kmlPositions([][]float64{line[instruction.Interval[0]]})},
+				})
+			}
+		case "Polygon", "MultiPolygon":
+			polygons, err := featurePolygons(feature)
+			if err != nil {
+				return nil, err
+			}
+			var kmlPolygons []kmlPolygon
+			for _, polygon := range polygons {
+				kmlPolygons = append(kmlPolygons, toKMLPolygon(polygon))
+			}
+			if len(kmlPolygons) == 1 {
+				placemark.Polygon = &kmlPolygons[0]
+			} else {
+				placemark.MultiGeometry = &kmlMultiGeometry{Polygons: kmlPolygons}
+			}
+		default:
+			continue
+		}
+		doc.Placemarks = append(doc.Placemarks, placemark)
+	}
+	return marshalXML(doc)
+}
+
+func marshalXML(doc interface{}) ([]byte, error) {
+	body, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), body...), nil
+}
+
+// decodeCoordinates converts GeoJSON coordinates, which may still be raw JSON
+// or generic decoded values, into the given typed slice.
+func decodeCoordinates(coordinates interface{}, target interface{}) error {
+	raw, ok := coordinates.(json.RawMessage)
+	if !ok {
+		var err error
+		if raw, err = json.Marshal(coordinates); err != nil {
+			return err
+		}
+	}
+	if err := json.Unmarshal(raw, target); err != nil {
+		return fmt.Errorf("invalid coordinates: %w", err)
+	}
+	return nil
+}
+
+func featurePolygons(feature Feature) ([][][][]float64, error) {
+	if feature.Geometry.Type == "Polygon" {
+		var polygon [][][]float64
+		err := decodeCoordinates(feature.Geometry.Coordinates, &polygon)
+		return [][][][]float64{polygon}, err
+	}
+	var polygons [][][][]float64
+	err := decodeCoordinates(feature.Geometry.Coordinates, &polygons)
+	return polygons, err
+}
+
+func featureInstructions(feature Feature) []Instruction {
+	instructions, _ := feature.Properties["instructions"].([]Instruction)
+	return instructions
+}
+
+func featureName(feature Feature) string {
+	for _, key := range []string{"incident_name", "zone_name", "name"} {
+		if name, ok := feature.Properties[key].(string); ok && name != "" {
+			return name
+		}
+	}
+	if feature.Geometry.Type == "LineString" {
+		return "Route"
+	}
+	return fmt.Sprint(feature.ID)
+}
+
+// featureDescription lists a feature's simple properties as "key: value" lines.
+func featureDescription(feature Feature) string {
+	var lines []string
+	for key, value := range feature.Properties {
+		switch value.(type) {
+		case string, int, float64, bool:
+			lines = append(lines, fmt.Sprintf("%s: %v", key, value))
+		}
+	}
+	sort.Strings(lines)
+	return strings.Join(lines, "\n")
+}
+
+func gpxRing(positions [][]float64) gpxSegment {
+	segment := gpxSegment{}
+	for _, position := range positions {
+		segment.Points = append(segment.Points, gpxPoint{Lat: position[1], Lon: position[0]})
+	}
+	return segment
+}
+
+func toKMLPolygon(polygon [][][]float64) kmlPolygon {
+	var result kmlPolygon
+	for i, ring := range polygon {
+		if i == 0 {
+			result.Outer = kmlCoordinates{Coordinates: kmlPositions(ring)}
+			continue
+		}
+		result.Inner = append(result.Inner, kmlCoordinates{Coordinates: kmlPositions(ring)})
+	}
+	return result
+}
+
+// kmlPositions formats positions as KML "lon,lat" tuples.
+func kmlPositions(positions [][]float64) string {
+	tuples := make([]string, 0, len(positions))
+	for _, position := range positions {
+		tuples = append(tuples, fmt.Sprintf("%g,%g", position[0], position[1]))
+	}
+	return strings.Join(tuples, " ")
+}
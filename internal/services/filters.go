@@ -0,0 +1,96 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// BoundingBox is a lon/lat rectangle, in the same order as a GeoJSON bbox.
+type BoundingBox struct {
+	MinLon, MinLat, MaxLon, MaxLat float64
+}
+
+// SpatialFilter restricts a listing to a bounding box and/or to points within
+// a distance of a location. Zero values mean no restriction.
+type SpatialFilter struct {
+	BBox *BoundingBox
+	// Near is a [lat, lon] point; Within is the radius around it in metres.
+	Near   *[2]float64
+	Within float64
+}
+
+type DisasterZoneFilter struct {
+	SpatialFilter
+	TypeID      *int
+	MinSeverity *int
+	StatusID    *int
+	// AsOf selects the zones as they were at that moment instead of now.
+	AsOf *time.Time
+}
+
+type SafeZoneFilter struct {
+	SpatialFilter
+	IncidentTypeID *int
+	// IncludeInactive also lists deactivated safe zones.
+	IncludeInactive bool
+	// Compromised, when set, keeps only zones that are (or are not) inside an
+	// active disaster zone.
+	Compromised *bool
+}
+
+// EvacuationFilter restricts a listing of kept evacuations. Nil fields mean no
+// restriction.
+type EvacuationFilter struct {
+	IncidentID *int
+	ZoneID     *int
+	Status     *string
+}
+
+// whereClause collects SQL conditions and their positional arguments.
+type whereClause struct {
+	conditions []string
+	args       []interface{}
+}
+
+// arg registers a query argument and returns its placeholder.
+func (w *whereClause) arg(value interface{}) string {
+	w.args = append(w.args, value)
+	return fmt.Sprintf("$%d", len(w.args))
+}
+
+func (w *whereClause) add(condition string) {
+	w.conditions = append(w.conditions, condition)
+}
+
+func (w *whereClause) String() string {
+	if len(w.conditions) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(w.conditions, " AND ")
+}
+
+// addSpatial adds the bounding box and distance conditions for the given
+// geography column. PostGIS can only answer them from a GiST index when the
+// column is a stored, indexed one rather than an expression.
+func (w *whereClause) addSpatial(filter SpatialFilter, geogColumn string) {
+	if filter.BBox != nil {
+		w.add(fmt.Sprintf("ST_Intersects(%s, ST_MakeEnvelope(%s, %s, %s, %s, 4326)::geography)",
+			geogColumn, w.arg(filter.BBox.MinLon), w.arg(filter.BBox.MinLat), w.arg(filter.BBox.MaxLon), w.arg(filter.BBox.MaxLat)))
+	}
+	if filter.Near != nil {
+		w.add(fmt.Sprintf("ST_DWithin(%s, %s, %s)",
+			geogColumn, w.point(filter.Near[0], filter.Near[1]), w.arg(filter.Within)))
+	}
+}
+
+// point registers a lat/lon pair and returns it as a geography expression.
+func (w *whereClause) point(lat, lon float64) string {
+	return geographyPoint(w.arg(lat), w.arg(lon))
+}
+
+// geographyPoint builds a WGS84 geography point from latitude and longitude
+// SQL expressions.
+func geographyPoint(lat, lon string) string {
+	return fmt.Sprintf("ST_SetSRID(ST_MakePoint(%s, %s), 4326)::geography", lon, lat)
+}
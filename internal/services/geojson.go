@@ -0,0 +1,195 @@
+package services
+
+import (
+	"disaster-response-map-api/internal/models"
+	"fmt"
+)
+
+// Feature is a GeoJSON Feature.
+type Feature struct {
+	Type       string                 `json:"type" example:"Feature"`
+	ID         interface{}            `json:"id,omitempty"`
+	Geometry   GeoJSON                `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+// FeatureCollection is a GeoJSON FeatureCollection.
+type FeatureCollection struct {
+	Type     string    `json:"type" example:"FeatureCollection"`
+	Features []Feature `json:"features"`
+}
+
+func newFeatureCollection(features []Feature) FeatureCollection {
+	if features == nil {
+		features = []Feature{}
+	}
+	return FeatureCollection{Type: "FeatureCollection", Features: features}
+}
+
+// DisasterZoneGeometry returns the outline of a zone as shown to clients: the
+// drawn geometry when there is one, otherwise a circle of the zone radius.
+func DisasterZoneGeometry(zone models.DisasterZone) GeoJSON {
+	if zone.Geometry != nil {
+		if _, err := ParseZoneGeometry(*zone.Geometry); err == nil {
+			return GeoJSON{Type: zone.Geometry.Type, Coordinates: zone.Geometry.Coordinates}
+		}
+	}
+	return GeoJSON{Type: "Polygon", Coordinates: BuildCirclePolygon(zone.Latitude, zone.Longitude, zone.Radius)}
+}
+
+// disasterZoneBufferGeometry returns the buffer ring around a circle zone,
+// between its radius and radius plus buffer, which routing also avoids. Drawn
+// zones and zones without a buffer have none.
+func disasterZoneBufferGeometry(zone models.DisasterZone) (GeoJSON, bool) {
+	if zone.Buffer <= 0 || drawnGeometry(zone) != nil {
+		return GeoJSON{}, false
+	}
+	outer := BuildCirclePolygon(zone.Latitude, zone.Longitude, zone.Radius+zone.Buffer)
+	inner := BuildCirclePolygon(zone.Latitude, zone.Longitude, zone.Radius)
+	if len(outer) == 0 || len(inner) == 0 {
+		return GeoJSON{}, false
+	}
+	// The hole winds the opposite way to the outer ring.
+	hole := make([][]float64, len(inner[0]))
+	for i, position := range inner[0] {
+		hole[len(hole)-1-i] = position
+	}
+	return GeoJSON{Type: "Polygon", Coordinates: [][][]float64{outer[0], hole}}, true
+}
+
+// DisasterZonesToFeatureCollection converts zones to polygon features carrying
+// the zone attributes as properties. Circle zones with a buffer get a second
+// feature for the buffer ring, so that the features cover all that routing
+// avoids; the part property tells the two apart.
+func DisasterZonesToFeatureCollection(zones []models.DisasterZone) FeatureCollection {
+	features := make([]Feature, 0, len(zones))
+	for _, zone := range zones {
+		features = append(features, Feature{
+			Type:     "Feature",
+			ID:       zone.IncidentID,
+			Geometry: DisasterZoneGeometry(zone),
+			Properties: map[string]interface{}{
+				"incident_id":   zone.IncidentID,
+				"incident_name": zone.IncidentName,
+				"type_id":       zone.TypeID,
+				"severity_id":   zone.SeverityID,
+				"status_id":     zone.StatusID,
+				"latitude":      zone.Latitude,
+				"longitude":     zone.Longitude,
+				"radius":        zone.Radius,
+				"buffer":        zone.Buffer,
+				"avoidance":     zone.Avoidance,
+				"source":        zone.Source,
+				"part":          "zone",
+			},
+		})
+		if geometry, ok := disasterZoneBufferGeometry(zone); ok {
+			features = append(features, Feature{
+				Type:     "Feature",
+				ID:       fmt.Sprintf("%d-buffer", zone.IncidentID),
+				Geometry: geometry,
+				Properties: map[string]interface{}{
+					"incident_id":   zone.IncidentID,
+					"incident_name": zone.IncidentName + " buffer",
+					"radius":        zone.Radius,
+					"buffer":        zone.Buffer,
+					"part":          "buffer",
+				},
+			})
+		}
+	}
+	return newFeatureCollection(features)
+}
+
+// SafeZonesToFeatureCollection converts safe zones to point features.
+func SafeZonesToFeatureCollection(zones []models.SafeZone) FeatureCollection {
+	features := make([]Feature, 0, len(zones))
+	for _, zone := range zones {
+		features = append(features, Feature{
+			Type:     "Feature",
+			ID:       zone.ZoneID,
+			Geometry: GeoJSON{Type: "Point", Coordinates: []float64{zone.ZoneLon, zone.ZoneLat}},
+			Properties: map[string]interface{}{
+				"zone_id":            zone.ZoneID,
+				"zone_name":          zone.ZoneName,
+				"incident_type_id":   zone.IncidentTypeID,
+				"incident_type_ids":  zone.IncidentTypeIDs,
+				"capacity":           zone.Capacity,
+				"occupancy":          zone.Occupancy,
+				"is_open":            zone.IsOpen,
+				"remaining_capacity": zone.RemainingCapacity,
+				"facilities":         zone.Facilities,
+			},
+		})
+	}
+	return newFeatureCollection(features)
+}
+
+// RouteToFeatureCollection converts each route path to a LineString feature
+// with its distance, time and turn instructions as properties. Paths must have
+// been requested with points_encoded=false.
+func RouteToFeatureCollection(paths []RoutePath) FeatureCollection {
+	features := make([]Feature, 0, len(paths))
+	for i, path := range paths {
+		instructions := path.Instructions
+		if instructions == nil {
+			instructions = []Instruction{}
+		}
+		features = append(features, Feature{
+			Type:     "Feature",
+			ID:       i,
+			Geometry: path.Points,
+			Properties: map[string]interface{}{
+				"distance":     path.Distance,
+				"time":         path.Time,
+				"ascend":       path.Ascend,
+				"descend":      path.Descend,
+				"bbox":         path.BBox,
+				"instructions": instructions,
+			},
+		})
+	}
+	return newFeatureCollection(features)
+}
+
+// EvacuationPlanToFeatureCollection converts a plan to one LineString feature
+// per assignment route, carrying the origin, safe zone and head count, and one
+// point feature per safe zone with the load the plan puts on it.
+func EvacuationPlanToFeatureCollection(plan EvacuationPlan) FeatureCollection {
+	features := []Feature{}
+	for _, origin := range plan.Origins {
+		for _, assignment := range origin.Assignments {
+			if assignment.Route == nil {
+				continue
+			}
+			features = append(features, Feature{
+				Type:     "Feature",
+				ID:       fmt.Sprintf("%s-%d", origin.ID, assignment.ZoneID),
+				Geometry: assignment.Route.Points,
+				Properties: map[string]interface{}{
+					"origin_id":       origin.ID,
+					"zone_id":         assignment.ZoneID,
+					"people":          assignment.People,
+					"distance":        assignment.Distance,
+					"time":            assignment.Time,
+					"hazard_distance": assignment.HazardDistance,
+				},
+			})
+		}
+	}
+	for _, load := range plan.Zones {
+		zone := load.SafeZone
+		features = append(features, Feature{
+			Type:     "Feature",
+			ID:       zone.ZoneID,
+			Geometry: GeoJSON{Type: "Point", Coordinates: []float64{zone.ZoneLon, zone.ZoneLat}},
+			Properties: map[string]interface{}{
+				"zone_id":            zone.ZoneID,
+				"zone_name":          zone.ZoneName,
+				"assigned":           load.Assigned,
+				"remaining_capacity": load.RemainingCapacity,
+			},
+		})
+	}
+	return newFeatureCollection(features)
+}
@@ -8,19 +8,21 @@ package services
 
 import (
 	"bytes"
-	"disaster-response-map-api/internal/models"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
 )
 
 type GraphHopperServiceInterface interface {
-	GetEvacuationRoute(dangerPoint, safePoint [2]float64) (EvacuationRouteResponse, error)
-	GetSafeRoute(origin, destination string, zones []models.DisasterZone) (RouteResponse, error)
+	GetEvacuationRoute(dangerPoint, safePoint [2]float64, mode TravelMode, areas []ZoneArea) (EvacuationRouteResponse, error)
+	GetSafeRoute(origin, destination string, areas []ZoneArea) (RouteResponse, error)
 	GetRoute(origin, destination string) (RouteResponse, error)
+	GetTravelMatrix(from, to [][2]float64, mode TravelMode, areas []ZoneArea) (TravelMatrix, error)
+	GetIsochrone(point [2]float64, timeLimit, buckets int, mode TravelMode) ([]Feature, error)
 }
 
 type GraphHopperService struct {
@@ -111,7 +113,10 @@ func (s *GraphHopperService) GetRoute(origin, destination string) (RouteResponse
 	return routeResp, nil
 }
 
-func (s *GraphHopperService) GetEvacuationRoute(dangerPoint, safePoint [2]float64) (EvacuationRouteResponse, error) {
+// GetEvacuationRoute routes in the travel mode from the danger point to the
+// safe point, avoiding the given zone areas.
+func (s *GraphHopperService) GetEvacuationRoute(dangerPoint, safePoint [2]float64, mode TravelMode, areas []ZoneArea) (EvacuationRouteResponse, error) {
+	profile := mode.profile()
 	points := []interface{}{
 		[]float64{dangerPoint[1], dangerPoint[0]}, // [lon, lat]
 		[]float64{safePoint[1], safePoint[0]},     // [lon, lat]
@@ -119,14 +124,20 @@ func (s *GraphHopperService) GetEvacuationRoute(dangerPoint, safePoint [2]float6
 
 	requestPayload := map[string]interface{}{
 		"points":           points,
-		"snap_preventions": []string{"motorway", "ferry", "tunnel"},
+		"snap_preventions": profile.SnapPreventions,
 		"details":          []string{"road_class", "surface"},
-		"profile":          "foot",
+		"profile":          profile.Profile,
 		"locale":           "en",
 		"instructions":     true,
 		"calc_points":      true,
 		"points_encoded":   false,
 	}
+	// Contraction hierarchies cannot honour a custom model, so they are only
+	// given up when the mode or the zones need one.
+	if model := travelCustomModel(mode, areas); model != nil {
+		requestPayload["custom_model"] = model
+		requestPayload["ch.disable"] = true
+	}
 
 	jsonBytes, err := json.Marshal(requestPayload)
 	if err != nil {
@@ -158,8 +169,8 @@ func (s *GraphHopperService) GetEvacuationRoute(dangerPoint, safePoint [2]float6
 	return routeResp, nil
 }
 
-func (s *GraphHopperService) GetSafeRoute(origin, destination string, zones []models.DisasterZone) (RouteResponse, error) {
-	customModel := BuildDisasterZonesCustomModel(zones)
+func (s *GraphHopperService) GetSafeRoute(origin, destination string, areas []ZoneArea) (RouteResponse, error) {
+	customModel := BuildDisasterZonesCustomModel(areas)
 	points, err := buildPoints(origin, destination)
 	if err != nil {
 		return RouteResponse{}, err
@@ -203,5 +214,113 @@ func (s *GraphHopperService) GetSafeRoute(origin, destination string, zones []mo
 		return RouteResponse{}, err
 	}
 
+	for _, area := range areas {
+		if area.Ring != 0 {
+			continue
+		}
+		for _, incidentID := range area.IncidentIDs {
+			routeResp.AvoidedZones = append(routeResp.AvoidedZones, AvoidedZone{
+				IncidentID: incidentID,
+				AreaID:     area.AreaID,
+			})
+		}
+	}
 	return routeResp, nil
 }
+
+// endpoint returns the URL of another GraphHopper API beside the routing one,
+// such as .../api/1/matrix for name "matrix" when BaseURL is .../api/1/route.
+func (s *GraphHopperService) endpoint(name string) string {
+	base := strings.TrimSuffix(strings.TrimSuffix(s.BaseURL, "/"), "/route")
+	return fmt.Sprintf("%s/%s?key=%s", base, name, s.APIKey)
+}
+
+// GetTravelMatrix returns the travel times and distances in the travel mode
+// from every point in from to every point in to, avoiding the given zone
+// areas. Pairs with no connection are nil rather than failing the whole
+// matrix.
+func (s *GraphHopperService) GetTravelMatrix(from, to [][2]float64, mode TravelMode, areas []ZoneArea) (TravelMatrix, error) {
+	profile := mode.profile()
+	lonLat := func(points [][2]float64) [][]float64 {
+		out := make([][]float64, len(points))
+		for i, p := range points {
+			out[i] = []float64{p[1], p[0]}
+		}
+		return out
+	}
+	requestPayload := map[string]interface{}{
+		"from_points": lonLat(from),
+		"to_points":   lonLat(to),
+		"out_arrays":  []string{"times", "distances"},
+		"profile":     profile.Profile,
+		"fail_fast":   false,
+	}
+	if model := travelCustomModel(mode, areas); model != nil {
+		requestPayload["custom_model"] = model
+		requestPayload["ch.disable"] = true
+	}
+
+	jsonBytes, err := json.Marshal(requestPayload)
+	if err != nil {
+		return TravelMatrix{}, err
+	}
+
+	resp, err := http.Post(s.endpoint("matrix"), "application/json", bytes.NewReader(jsonBytes))
+	if err != nil {
+		return TravelMatrix{}, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return TravelMatrix{}, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return TravelMatrix{}, fmt.Errorf("GraphHopper API error: %s - %s", resp.Status, string(body))
+	}
+
+	var matrix TravelMatrix
+	if err := json.Unmarshal(body, &matrix); err != nil {
+		return TravelMatrix{}, err
+	}
+	if len(matrix.Times) != len(from) || len(matrix.Distances) != len(from) {
+		return TravelMatrix{}, fmt.Errorf("GraphHopper API error: matrix has %d rows, expected %d", len(matrix.Times), len(from))
+	}
+	return matrix, nil
+}
+
+// GetIsochrone calls the GraphHopper Isochrone API for the areas reachable
+// from point within timeLimit seconds. The limit is split into buckets of
+// equal length, and one polygon is returned per bucket, each covering
+// everything reachable by the end of it. The Isochrone API is a GET request
+// that takes a profile but no custom model, so the mode's plain profile is
+// used and zones cannot be avoided.
+func (s *GraphHopperService) GetIsochrone(point [2]float64, timeLimit, buckets int, mode TravelMode) ([]Feature, error) {
+	query := url.Values{}
+	query.Set("point", fmt.Sprintf("%f,%f", point[0], point[1]))
+	query.Set("time_limit", strconv.Itoa(timeLimit))
+	query.Set("buckets", strconv.Itoa(buckets))
+	query.Set("profile", mode.profile().Profile)
+
+	resp, err := http.Get(s.endpoint("isochrone") + "&" + query.Encode())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GraphHopper API error: %s - %s", resp.Status, string(body))
+	}
+
+	var isochrone struct {
+		Polygons []Feature `json:"polygons"`
+	}
+	if err := json.Unmarshal(body, &isochrone); err != nil {
+		return nil, err
+	}
+	return isochrone.Polygons, nil
+}
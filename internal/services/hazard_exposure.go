@@ -0,0 +1,72 @@
+package services
+
+import (
+	"disaster-response-map-api/internal/models"
+	"math"
+)
+
+// hazardSampleStep is the longest stretch of route, in metres, that is tested
+// against the zones as a single piece.
+const hazardSampleStep = 10.0
+
+// HazardExposure is how far a route runs inside one disaster zone. It is
+// kept with saved evacuations, so it lives in models.
+type HazardExposure = models.HazardExposure
+
+// pathHazardExposure measures the first path of a route with
+// routeHazardExposure. Paths must have been requested with
+// points_encoded=false.
+func pathHazardExposure(paths []RoutePath, zones []models.DisasterZone) (float64, []HazardExposure) {
+	if len(paths) == 0 {
+		return 0, nil
+	}
+	var line [][]float64
+	if err := decodeCoordinates(paths[0].Points.Coordinates, &line); err != nil {
+		return 0, nil
+	}
+	return routeHazardExposure(line, zones)
+}
+
+// routeHazardExposure measures how far a line of [lon, lat] positions runs
+// inside the zones. Each segment is split into pieces of at most
+// hazardSampleStep metres, and a piece counts as inside a zone when its
+// midpoint is. The total counts stretches inside overlapping zones once.
+func routeHazardExposure(line [][]float64, zones []models.DisasterZone) (float64, []HazardExposure) {
+	contains := make([]func(lat, lon float64) bool, len(zones))
+	for i, zone := range zones {
+		contains[i] = zoneContains(zone)
+	}
+
+	total := 0.0
+	byZone := make([]float64, len(zones))
+	for i := 1; i < len(line); i++ {
+		from, to := line[i-1], line[i]
+		if len(from) < 2 || len(to) < 2 {
+			continue
+		}
+		length := HaversineDistance(from[1], from[0], to[1], to[0])
+		pieces := math.Max(1, math.Ceil(length/hazardSampleStep))
+		for k := 0.0; k < pieces; k++ {
+			t := (k + 0.5) / pieces
+			lon, lat := from[0]+(to[0]-from[0])*t, from[1]+(to[1]-from[1])*t
+			inside := false
+			for z, contain := range contains {
+				if contain(lat, lon) {
+					byZone[z] += length / pieces
+					inside = true
+				}
+			}
+			if inside {
+				total += length / pieces
+			}
+		}
+	}
+
+	var exposures []HazardExposure
+	for z, distance := range byZone {
+		if distance > 0 {
+			exposures = append(exposures, HazardExposure{IncidentID: zones[z].IncidentID, Distance: distance})
+		}
+	}
+	return total, exposures
+}
@@ -0,0 +1,144 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"disaster-response-map-api/internal/models"
+)
+
+// ErrInvalidIsochrone is returned when an isochrone request is malformed.
+var ErrInvalidIsochrone = errors.New("invalid isochrone")
+
+const (
+	// maxIsochroneMinutes bounds the time limit of an isochrone.
+	maxIsochroneMinutes = 60
+	// defaultIsochroneBands and maxIsochroneBands are how many time bands an
+	// isochrone is split into by default and at most.
+	defaultIsochroneBands = 3
+	maxIsochroneBands     = 6
+)
+
+// clipIsochroneQuery cuts the hazard areas out of each isochrone polygon in
+// the $1 array and keeps the parts around the start point, so that land only
+// reachable through a hazard is dropped too. A band whose start point falls
+// outside every remaining part keeps all of them.
+var clipIsochroneQuery = `
+    WITH ` + hazardsCTE("$2") + `, blocked AS (
+        SELECT ST_Union(geog::geometry) AS geom FROM hazard
+    ), band AS (
+        SELECT ord, ST_Difference(ST_GeomFromGeoJSON(b), (SELECT geom FROM blocked)) AS geom
+        FROM jsonb_array_elements($1::jsonb) WITH ORDINALITY AS t(b, ord)
+    )
+    SELECT ord, ST_AsGeoJSON(COALESCE(
+        (SELECT ST_Union(p.geom) FROM ST_Dump(band.geom) AS p
+         WHERE ST_Intersects(p.geom, ST_SetSRID(ST_MakePoint($4, $3), 4326))),
+        band.geom))
+    FROM band
+    ORDER BY ord
+`
+
+// GetIsochrone returns the areas reachable from point in the mode within
+// minutes, split into bands of equal length; bands of zero default to three.
+// Each band is a polygon feature covering everything reachable by its upper
+// limit, so the polygons overlap, largest last. GraphHopper cannot take a
+// custom model for isochrones, so the polygons are computed on the mode's
+// plain profile, given as the profile property, and every active disaster
+// zone except those the point lies in is then cut out of them.
+func (s *EvacuationService) GetIsochrone(point [2]float64, minutes, bands int, mode TravelMode) (FeatureCollection, error) {
+	if bands == 0 {
+		bands = defaultIsochroneBands
+	}
+	switch {
+	case point[0] < -90 || point[0] > 90 || point[1] < -180 || point[1] > 180:
+		return FeatureCollection{}, fmt.Errorf("%w: point out of range", ErrInvalidIsochrone)
+	case minutes < 1 || minutes > maxIsochroneMinutes:
+		return FeatureCollection{}, fmt.Errorf("%w: minutes must be between 1 and %d", ErrInvalidIsochrone, maxIsochroneMinutes)
+	case bands < 1 || bands > maxIsochroneBands:
+		return FeatureCollection{}, fmt.Errorf("%w: bands must be between 1 and %d", ErrInvalidIsochrone, maxIsochroneBands)
+	}
+
+	activeZones, err := s.DZ.GetActiveDisasterZones(nil)
+	if err != nil {
+		return FeatureCollection{}, fmt.Errorf("failed to load active disaster zones: %v", err)
+	}
+	avoided, startZones := splitStartZones(activeZones, point)
+
+	polygons, err := s.GH.GetIsochrone(point, minutes*60, bands, mode)
+	if err != nil {
+		return FeatureCollection{}, fmt.Errorf("failed to compute isochrone: %v", err)
+	}
+	if err := s.clipIsochrone(polygons, point, avoided); err != nil {
+		return FeatureCollection{}, err
+	}
+
+	for i := range polygons {
+		polygon := &polygons[i]
+		bucket := i
+		if value, ok := polygon.Properties["bucket"].(float64); ok {
+			bucket = int(value)
+		}
+		limit := float64(minutes) * float64(bucket+1) / float64(bands)
+		polygon.Type = "Feature"
+		polygon.ID = bucket
+		polygon.Properties = map[string]interface{}{
+			"bucket":  bucket,
+			"minutes": limit,
+			"name":    fmt.Sprintf("Within %g min", limit),
+			"mode":    mode,
+			"profile": mode.profile().Profile,
+		}
+		if len(startZones) > 0 {
+			polygon.Properties["start_zones"] = startZones
+		}
+	}
+	sort.SliceStable(polygons, func(i, j int) bool {
+		return polygons[i].Properties["bucket"].(int) < polygons[j].Properties["bucket"].(int)
+	})
+	return newFeatureCollection(polygons), nil
+}
+
+// clipIsochrone cuts the areas of the avoided zones out of the polygons in
+// place.
+func (s *EvacuationService) clipIsochrone(polygons []Feature, point [2]float64, avoided []models.DisasterZone) error {
+	if len(polygons) == 0 || len(avoided) == 0 {
+		return nil
+	}
+	areas, err := zoneAreasJSON(avoided)
+	if err != nil {
+		return fmt.Errorf("failed to encode disaster zones: %v", err)
+	}
+	geometries := make([]GeoJSON, len(polygons))
+	for i, polygon := range polygons {
+		geometries[i] = polygon.Geometry
+	}
+	encoded, err := json.Marshal(geometries)
+	if err != nil {
+		return fmt.Errorf("failed to encode isochrone: %v", err)
+	}
+
+	rows, err := s.DB.Query(clipIsochroneQuery, string(encoded), areas, point[0], point[1])
+	if err != nil {
+		return fmt.Errorf("failed to clip isochrone: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var ord int
+		var geometry []byte
+		if err := rows.Scan(&ord, &geometry); err != nil {
+			return fmt.Errorf("failed to scan isochrone: %v", err)
+		}
+		if ord < 1 || ord > len(polygons) {
+			continue
+		}
+		if err := json.Unmarshal(geometry, &polygons[ord-1].Geometry); err != nil {
+			return fmt.Errorf("failed to decode isochrone: %v", err)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to clip isochrone: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Need is a facility an evacuee requires of the safe zone they are sent to.
+type Need string
+
+const (
+	NeedMedical    Need = "medical"
+	NeedWheelchair Need = "wheelchair"
+	NeedPets       Need = "pets"
+	NeedWater      Need = "water"
+	NeedPower      Need = "power"
+	NeedBed        Need = "bed"
+)
+
+var ErrInvalidNeed = errors.New("invalid need")
+
+// needConditions maps each need to the safe_zone condition that satisfies it.
+var needConditions = map[Need]string{
+	NeedMedical:    "medical_staff",
+	NeedWheelchair: "wheelchair_access",
+	NeedPets:       "pets_allowed",
+	NeedWater:      "water",
+	NeedPower:      "power",
+	NeedBed:        "beds > 0",
+}
+
+// ParseNeeds reads a list of needs, dropping duplicates.
+func ParseNeeds(values []string) ([]Need, error) {
+	needs := []Need{}
+	seen := map[Need]bool{}
+	for _, value := range values {
+		need := Need(strings.ToLower(strings.TrimSpace(value)))
+		if _, ok := needConditions[need]; !ok {
+			return nil, fmt.Errorf("%w: %q, expected medical, wheelchair, pets, water, power or bed", ErrInvalidNeed, value)
+		}
+		if !seen[need] {
+			seen[need] = true
+			needs = append(needs, need)
+		}
+	}
+	return needs, nil
+}
+
+// needsCondition returns SQL conditions, each prefixed with AND, that keep
+// only safe zones meeting every need.
+func needsCondition(needs []Need) (string, error) {
+	var condition strings.Builder
+	for _, need := range needs {
+		column, ok := needConditions[need]
+		if !ok {
+			return "", fmt.Errorf("%w: %q", ErrInvalidNeed, need)
+		}
+		condition.WriteString(" AND " + column)
+	}
+	return condition.String(), nil
+}
@@ -6,7 +6,16 @@
 // @BasePath /
 package services
 
-import "math"
+import (
+	"disaster-response-map-api/internal/models"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// ErrInvalidGeometry is returned when a zone geometry is not a valid GeoJSON Polygon or MultiPolygon.
+var ErrInvalidGeometry = errors.New("invalid zone geometry")
 
 func BuildCirclePolygon(centerLat, centerLon, radius float64) [][][]float64 {
 	const (
@@ -37,3 +46,136 @@ func BuildCirclePolygon(centerLat, centerLon, radius float64) [][][]float64 {
 	}
 	return [][][]float64{ring}
 }
+
+// ParseZoneGeometry validates a GeoJSON Polygon or MultiPolygon and returns it
+// as a list of polygons, each made of an outer ring followed by any holes.
+func ParseZoneGeometry(geometry models.ZoneGeometry) ([][][][]float64, error) {
+	var polygons [][][][]float64
+	switch geometry.Type {
+	case "Polygon":
+		var polygon [][][]float64
+		if err := json.Unmarshal(geometry.Coordinates, &polygon); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidGeometry, err)
+		}
+		polygons = [][][][]float64{polygon}
+	case "MultiPolygon":
+		if err := json.Unmarshal(geometry.Coordinates, &polygons); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidGeometry, err)
+		}
+	default:
+		return nil, fmt.Errorf("%w: unsupported type %q, expected Polygon or MultiPolygon", ErrInvalidGeometry, geometry.Type)
+	}
+
+	if len(polygons) == 0 {
+		return nil, fmt.Errorf("%w: no polygons", ErrInvalidGeometry)
+	}
+	for _, polygon := range polygons {
+		if len(polygon) == 0 {
+			return nil, fmt.Errorf("%w: polygon has no rings", ErrInvalidGeometry)
+		}
+		for _, ring := range polygon {
+			if err := validateRing(ring); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return polygons, nil
+}
+
+func validateRing(ring [][]float64) error {
+	if len(ring) < 4 {
+		return fmt.Errorf("%w: ring needs at least 4 positions", ErrInvalidGeometry)
+	}
+	for _, position := range ring {
+		if len(position) < 2 {
+			return fmt.Errorf("%w: position must be [longitude, latitude]", ErrInvalidGeometry)
+		}
+		if position[0] < -180 || position[0] > 180 || position[1] < -90 || position[1] > 90 {
+			return fmt.Errorf("%w: position %v is out of range", ErrInvalidGeometry, position)
+		}
+	}
+	first, last := ring[0], ring[len(ring)-1]
+	if first[0] != last[0] || first[1] != last[1] {
+		return fmt.Errorf("%w: ring is not closed", ErrInvalidGeometry)
+	}
+	return nil
+}
+
+// HaversineDistance returns the great-circle distance in metres between two points.
+func HaversineDistance(lat1, lon1, lat2, lon2 float64) float64 {
+	const earthRadius = 6371000
+	dLat := (lat2 - lat1) * math.Pi / 180.0
+	dLon := (lon2 - lon1) * math.Pi / 180.0
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1*math.Pi/180.0)*math.Cos(lat2*math.Pi/180.0)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadius * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+}
+
+// ZoneContainsPoint reports whether a point lies inside a zone's drawn outline
+// or, for zones without one, within its radius plus buffer.
+func ZoneContainsPoint(zone models.DisasterZone, lat, lon float64) bool {
+	return zoneContains(zone)(lat, lon)
+}
+
+// zoneContains returns a ZoneContainsPoint test for one zone with its outline
+// parsed once, for testing many points.
+func zoneContains(zone models.DisasterZone) func(lat, lon float64) bool {
+	if zone.Geometry != nil {
+		if polygons, err := ParseZoneGeometry(*zone.Geometry); err == nil {
+			return func(lat, lon float64) bool {
+				for _, polygon := range polygons {
+					if polygonContains(polygon, lon, lat) {
+						return true
+					}
+				}
+				return false
+			}
+		}
+	}
+	reach := zone.Radius + zone.Buffer
+	return func(lat, lon float64) bool {
+		return HaversineDistance(zone.Latitude, zone.Longitude, lat, lon) <= reach
+	}
+}
+
+// polygonContains tests a point against a polygon's outer ring and holes.
+func polygonContains(polygon [][][]float64, lon, lat float64) bool {
+	if len(polygon) == 0 || !ringContains(polygon[0], lon, lat) {
+		return false
+	}
+	for _, hole := range polygon[1:] {
+		if ringContains(hole, lon, lat) {
+			return false
+		}
+	}
+	return true
+}
+
+// ringContains is a ray-casting point-in-ring test in lon/lat space.
+func ringContains(ring [][]float64, lon, lat float64) bool {
+	inside := false
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		xi, yi := ring[i][0], ring[i][1]
+		xj, yj := ring[j][0], ring[j][1]
+		if (yi > lat) != (yj > lat) && lon < (xj-xi)*(lat-yi)/(yj-yi)+xi {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// PolygonsCentre returns the centre of the bounding box of the outer rings as (lat, lon).
+func PolygonsCentre(polygons [][][][]float64) (lat, lon float64) {
+	minLon, minLat := math.Inf(1), math.Inf(1)
+	maxLon, maxLat := math.Inf(-1), math.Inf(-1)
+	for _, polygon := range polygons {
+		if len(polygon) == 0 {
+			continue
+		}
+		for _, position := range polygon[0] {
+			minLon, maxLon = math.Min(minLon, position[0]), math.Max(maxLon, position[0])
+			minLat, maxLat = math.Min(minLat, position[1]), math.Max(maxLat, position[1])
+		}
+	}
+	return (minLat + maxLat) / 2, (minLon + maxLon) / 2
+}
@@ -6,6 +6,11 @@
 // @BasePath /
 package services
 
+import (
+	"disaster-response-map-api/internal/models"
+	"time"
+)
+
 type GeoJSON struct {
 	Type        string      `json:"type"`
 	Coordinates interface{} `json:"coordinates"` // can be []float64 or [][]float64 depending on the geometry
@@ -37,14 +42,77 @@ type RoutePath struct {
 	SnappedWaypoints GeoJSON                `json:"snapped_waypoints"`
 }
 
+// AvoidedZone links a disaster zone to the custom model area it was routed around.
+type AvoidedZone struct {
+	IncidentID int    `json:"incident_id" example:"42"`
+	AreaID     string `json:"area_id" example:"disaster_zone_42"`
+}
+
 type RouteResponse struct {
-	Hints map[string]interface{} `json:"hints" example:"{\"visited_nodes.sum\": 100, \"visited_nodes.average\": 100}"`
-	Info  map[string]interface{} `json:"info" example:"{\"took\": 3, \"copyrights\": [\"GraphHopper\", \"OpenStreetMap contributors\"]}"`
-	Paths []RoutePath            `json:"paths"`
+	Hints        map[string]interface{} `json:"hints" example:"{\"visited_nodes.sum\": 100, \"visited_nodes.average\": 100}"`
+	Info         map[string]interface{} `json:"info" example:"{\"took\": 3, \"copyrights\": [\"GraphHopper\", \"OpenStreetMap contributors\"]}"`
+	Paths        []RoutePath            `json:"paths"`
+	AvoidedZones []AvoidedZone          `json:"avoided_zones,omitempty"`
+	// ZonesAsOf is when the avoided zones were read; pass it as /zones?as_of=
+	// to see the zone set the route was computed against.
+	ZonesAsOf *time.Time `json:"zones_as_of,omitempty" example:"2025-04-11T10:00:00Z"`
+	// Avoidance is the avoidance level the route was computed with.
+	Avoidance AvoidanceLevel `json:"avoidance,omitempty" example:"balanced"`
+	// ActiveStatuses are the zone statuses the route avoided.
+	ActiveStatuses []int `json:"active_statuses,omitempty" example:"3,4"`
+	// Traffic is the first path's travel time adjusted for live traffic,
+	// absent when no traffic data could be had.
+	Traffic *TrafficETA `json:"traffic,omitempty"`
 }
 
 type EvacuationRouteResponse struct {
 	Hints map[string]interface{} `json:"hints"`
 	Info  map[string]interface{} `json:"info"`
 	Paths []RoutePath            `json:"paths"`
+	// SafeZone is the destination chosen when no safe point was given,
+	// including its remaining capacity.
+	SafeZone *models.SafeZone `json:"safe_zone,omitempty"`
+	// Alternatives are the safe zones compared when choosing SafeZone,
+	// reachable ones fastest first; SafeZone is the first of them.
+	Alternatives []EvacuationCandidate `json:"alternatives,omitempty"`
+	// Mode is how the route is travelled.
+	Mode TravelMode `json:"mode" example:"foot"`
+	// EvacuationID identifies the route under /evacuations once it is saved.
+	EvacuationID *int `json:"evacuation_id,omitempty" example:"12"`
+	// StartZones are the active disaster zones the danger point lies in. The
+	// route may cross them to get out; every other active zone is avoided.
+	StartZones []int `json:"start_zones,omitempty" example:"42"`
+	// HazardDistance is how far in metres the route runs inside active
+	// disaster zones, start zones included; HazardZones breaks it down by zone.
+	HazardDistance float64          `json:"hazard_distance" example:"120.5"`
+	HazardZones    []HazardExposure `json:"hazard_zones,omitempty"`
+	// ActiveZones are the active disaster zones the route was planned
+	// around. They are kept with saved evacuations but not returned.
+	ActiveZones []models.DisasterZone `json:"-"`
+	// Traffic is the route's travel time adjusted for live traffic, given for
+	// routes driven by car or bus when traffic data could be had.
+	Traffic *TrafficETA `json:"traffic,omitempty"`
+}
+
+// TravelMatrix holds the travel times in seconds and distances in metres of a
+// GraphHopper matrix request, indexed [from][to]; unconnected pairs are nil.
+type TravelMatrix struct {
+	Times     [][]*float64 `json:"times"`
+	Distances [][]*float64 `json:"distances"`
+}
+
+// EvacuationCandidate is a safe zone considered as an evacuation destination.
+type EvacuationCandidate struct {
+	SafeZone models.SafeZone `json:"safe_zone"`
+	// Reachable is false when no route to the zone was found; Error says why.
+	Reachable bool   `json:"reachable" example:"true"`
+	Error     string `json:"error,omitempty"`
+	// Distance in metres and Time in milliseconds along the route.
+	Distance float64 `json:"distance" example:"1060.8"`
+	Time     int     `json:"time" example:"780435"`
+	// StraightLineDistance is the distance from the danger point in metres.
+	StraightLineDistance float64 `json:"straight_line_distance" example:"640.2"`
+	// HazardDistance is how far in metres the route runs inside active
+	// disaster zones.
+	HazardDistance float64 `json:"hazard_distance" example:"120.5"`
 }
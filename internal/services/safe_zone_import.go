@@ -0,0 +1,402 @@
+package services
+
+import (
+	"database/sql"
+	"disaster-response-map-api/internal/models"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidImport is returned when an import file cannot be read at all, as
+// opposed to individual entries being rejected.
+var ErrInvalidImport = errors.New("invalid import file")
+
+// Actions reported for each imported entry.
+const (
+	ImportCreated  = "created"
+	ImportUpdated  = "updated"
+	ImportRejected = "rejected"
+)
+
+// SafeZoneImportRow is one entry read from an imported shelter list.
+type SafeZoneImportRow struct {
+	// Row is the entry's position in the file, from 1, not counting the CSV
+	// header.
+	Row      int
+	SafeZone models.SafeZoneCreate
+	// Err is set when the entry could not be read.
+	Err error
+}
+
+// SafeZoneImportResult reports what an import did, or would do, with one entry.
+type SafeZoneImportResult struct {
+	Row         int     `json:"row" example:"1"`
+	ExternalRef *string `json:"external_ref,omitempty" example:"DCC-0042"`
+	Action      string  `json:"action" example:"created"`
+	// ZoneID is the created or updated zone. It is left out for zones a dry
+	// run or failed import would have created.
+	ZoneID int    `json:"zone_id,omitempty" example:"8"`
+	Error  string `json:"error,omitempty"`
+}
+
+// SafeZoneImportReport summarises an import. Nothing is committed for a dry
+// run or when any entry is rejected.
+type SafeZoneImportReport struct {
+	DryRun    bool                   `json:"dry_run"`
+	Committed bool                   `json:"committed"`
+	Created   int                    `json:"created"`
+	Updated   int                    `json:"updated"`
+	Rejected  int                    `json:"rejected"`
+	Rows      []SafeZoneImportResult `json:"rows"`
+}
+
+// safeZoneImportFields are the columns of a CSV import and the properties of
+// a GeoJSON import feature. Facilities are flattened so that a spreadsheet
+// can give each one its own column.
+type safeZoneImportFields struct {
+	ExternalRef      *string `json:"external_ref"`
+	ZoneName         string  `json:"zone_name"`
+	IncidentTypeID   int     `json:"incident_type_id"`
+	IncidentTypeIDs  []int   `json:"incident_type_ids"`
+	Capacity         *int    `json:"capacity"`
+	IsOpen           *bool   `json:"is_open"`
+	MedicalStaff     bool    `json:"medical_staff"`
+	WheelchairAccess bool    `json:"wheelchair_access"`
+	PetsAllowed      bool    `json:"pets_allowed"`
+	Water            bool    `json:"water"`
+	Power            bool    `json:"power"`
+	Beds             int     `json:"beds"`
+}
+
+func (f safeZoneImportFields) safeZone(lat, lon float64) models.SafeZoneCreate {
+	if f.ExternalRef != nil {
+		ref := strings.TrimSpace(*f.ExternalRef)
+		f.ExternalRef = &ref
+	}
+	return models.SafeZoneCreate{
+		ZoneName:        f.ZoneName,
+		ZoneLat:         lat,
+		ZoneLon:         lon,
+		IncidentTypeID:  f.IncidentTypeID,
+		IncidentTypeIDs: f.IncidentTypeIDs,
+		Capacity:        f.Capacity,
+		IsOpen:          f.IsOpen,
+		Facilities: &models.SafeZoneFacilities{
+			MedicalStaff:     f.MedicalStaff,
+			WheelchairAccess: f.WheelchairAccess,
+			PetsAllowed:      f.PetsAllowed,
+			Water:            f.Water,
+			Power:            f.Power,
+			Beds:             f.Beds,
+		},
+		ExternalRef: f.ExternalRef,
+	}
+}
+
+// ParseSafeZoneCSV reads a CSV shelter list. The header names the columns:
+// zone_name, zone_lat and zone_lon are required, and the other
+// safeZoneImportFields are optional. Unrecognised columns are ignored.
+// incident_type_ids is separated by semicolons or commas.
+func ParseSafeZoneCSV(r io.Reader) ([]SafeZoneImportRow, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("%w: the file is empty", ErrInvalidImport)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImport, err)
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		// Spreadsheet exports often start with a byte order mark.
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		columns[name] = i
+	}
+	for _, required := range []string{"zone_name", "zone_lat", "zone_lon"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("%w: missing %s column", ErrInvalidImport, required)
+		}
+	}
+
+	rows := []SafeZoneImportRow{}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		row := SafeZoneImportRow{Row: len(rows) + 1}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				return nil, fmt.Errorf("%w: %v", ErrInvalidImport, err)
+			}
+			row.Err = fmt.Errorf("%w: %v", ErrInvalidSafeZone, err)
+		} else if len(record) != len(header) {
+			row.Err = fmt.Errorf("%w: expected %d fields, got %d", ErrInvalidSafeZone, len(header), len(record))
+		} else {
+			row.SafeZone, row.Err = safeZoneFromCSV(record, columns)
+		}
+		rows = append(rows, row)
+	}
+}
+
+func safeZoneFromCSV(record []string, columns map[string]int) (models.SafeZoneCreate, error) {
+	value := func(name string) string {
+		if i, ok := columns[name]; ok {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+	invalid := func(name string) error {
+		return fmt.Errorf("%w: invalid %s %q", ErrInvalidSafeZone, name, value(name))
+	}
+
+	var fields safeZoneImportFields
+	fields.ZoneName = value("zone_name")
+	lat, err := strconv.ParseFloat(value("zone_lat"), 64)
+	if err != nil {
+		return models.SafeZoneCreate{}, invalid("zone_lat")
+	}
+	lon, err := strconv.ParseFloat(value("zone_lon"), 64)
+	if err != nil {
+		return models.SafeZoneCreate{}, invalid("zone_lon")
+	}
+	if ref := value("external_ref"); ref != "" {
+		fields.ExternalRef = &ref
+	}
+	if raw := value("incident_type_id"); raw != "" {
+		if fields.IncidentTypeID, err = strconv.Atoi(raw); err != nil {
+			return models.SafeZoneCreate{}, invalid("incident_type_id")
+		}
+	}
+	for _, part := range strings.FieldsFunc(value("incident_type_ids"), func(r rune) bool { return r == ';' || r == ',' }) {
+		typeID, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil {
+			return models.SafeZoneCreate{}, invalid("incident_type_ids")
+		}
+		fields.IncidentTypeIDs = append(fields.IncidentTypeIDs, typeID)
+	}
+	if raw := value("capacity"); raw != "" {
+		capacity, err := strconv.Atoi(raw)
+		if err != nil {
+			return models.SafeZoneCreate{}, invalid("capacity")
+		}
+		fields.Capacity = &capacity
+	}
+	if raw := value("beds"); raw != "" {
+		if fields.Beds, err = strconv.Atoi(raw); err != nil {
+			return models.SafeZoneCreate{}, invalid("beds")
+		}
+	}
+	if raw := value("is_open"); raw != "" {
+		isOpen, ok := parseImportBool(raw)
+		if !ok {
+			return models.SafeZoneCreate{}, invalid("is_open")
+		}
+		fields.IsOpen = &isOpen
+	}
+	for _, facility := range []struct {
+		name   string
+		target *bool
+	}{
+		{"medical_staff", &fields.MedicalStaff},
+		{"wheelchair_access", &fields.WheelchairAccess},
+		{"pets_allowed", &fields.PetsAllowed},
+		{"water", &fields.Water},
+		{"power", &fields.Power},
+	} {
+		if raw := value(facility.name); raw != "" {
+			var ok bool
+			if *facility.target, ok = parseImportBool(raw); !ok {
+				return models.SafeZoneCreate{}, invalid(facility.name)
+			}
+		}
+	}
+	return fields.safeZone(lat, lon), nil
+}
+
+// parseImportBool reads a spreadsheet yes/no cell.
+func parseImportBool(raw string) (bool, bool) {
+	switch strings.ToLower(raw) {
+	case "true", "t", "yes", "y", "1":
+		return true, true
+	case "false", "f", "no", "n", "0":
+		return false, true
+	}
+	return false, false
+}
+
+// ParseSafeZoneGeoJSON reads a GeoJSON FeatureCollection of Point features
+// whose properties are the safeZoneImportFields. Unrecognised properties are
+// ignored.
+func ParseSafeZoneGeoJSON(r io.Reader) ([]SafeZoneImportRow, error) {
+	var collection struct {
+		Type     string `json:"type"`
+		Features []struct {
+			Geometry *struct {
+				Type        string    `json:"type"`
+				Coordinates []float64 `json:"coordinates"`
+			} `json:"geometry"`
+			Properties json.RawMessage `json:"properties"`
+		} `json:"features"`
+	}
+	if err := json.NewDecoder(r).Decode(&collection); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImport, err)
+	}
+	if collection.Type != "FeatureCollection" {
+		return nil, fmt.Errorf("%w: expected a FeatureCollection", ErrInvalidImport)
+	}
+
+	rows := make([]SafeZoneImportRow, 0, len(collection.Features))
+	for i, feature := range collection.Features {
+		row := SafeZoneImportRow{Row: i + 1}
+		var fields safeZoneImportFields
+		switch {
+		case feature.Geometry == nil || feature.Geometry.Type != "Point" || len(feature.Geometry.Coordinates) < 2:
+			row.Err = fmt.Errorf("%w: geometry must be a Point", ErrInvalidSafeZone)
+		case len(feature.Properties) == 0:
+			row.Err = fmt.Errorf("%w: missing properties", ErrInvalidSafeZone)
+		default:
+			if err := json.Unmarshal(feature.Properties, &fields); err != nil {
+				row.Err = fmt.Errorf("%w: %v", ErrInvalidSafeZone, err)
+			} else {
+				row.SafeZone = fields.safeZone(feature.Geometry.Coordinates[1], feature.Geometry.Coordinates[0])
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// ImportSafeZones creates the entries of an imported shelter list, updating
+// instead any zone whose external_ref matches. Every entry is validated and
+// applied in a single transaction, which is only committed when none is
+// rejected and dryRun is false.
+func (s *SafeZoneService) ImportSafeZones(rows []SafeZoneImportRow, dryRun bool) (SafeZoneImportReport, error) {
+	report := SafeZoneImportReport{DryRun: dryRun, Rows: []SafeZoneImportResult{}}
+
+	knownTypes, err := s.incidentTypeIDs()
+	if err != nil {
+		return report, err
+	}
+	typeExists := func(typeID int) (bool, error) { return knownTypes[typeID], nil }
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return report, fmt.Errorf("failed to start import: %w", err)
+	}
+	defer tx.Rollback()
+
+	refRows := map[string]int{}
+	for _, row := range rows {
+		result := SafeZoneImportResult{Row: row.Row, ExternalRef: row.SafeZone.ExternalRef}
+		reject := func(err error) {
+			result.Action = ImportRejected
+			result.Error = err.Error()
+		}
+
+		typeIDs, err := checkSafeZone(row.SafeZone, typeExists)
+		switch {
+		case row.Err != nil:
+			reject(row.Err)
+		case err != nil:
+			reject(err)
+		case row.SafeZone.ExternalRef != nil && refRows[*row.SafeZone.ExternalRef] != 0:
+			reject(fmt.Errorf("%w: external_ref also used by row %d", ErrInvalidSafeZone, refRows[*row.SafeZone.ExternalRef]))
+		default:
+			if row.SafeZone.ExternalRef != nil {
+				refRows[*row.SafeZone.ExternalRef] = row.Row
+			}
+			if err := importSafeZone(tx, row.SafeZone, typeIDs, &result); err != nil {
+				return report, err
+			}
+		}
+
+		switch result.Action {
+		case ImportCreated:
+			report.Created++
+		case ImportUpdated:
+			report.Updated++
+		default:
+			report.Rejected++
+		}
+		report.Rows = append(report.Rows, result)
+	}
+
+	if dryRun || report.Rejected > 0 {
+		for i := range report.Rows {
+			if report.Rows[i].Action == ImportCreated {
+				report.Rows[i].ZoneID = 0
+			}
+		}
+		return report, nil
+	}
+	if err := tx.Commit(); err != nil {
+		return report, fmt.Errorf("failed to commit import: %w", err)
+	}
+	report.Committed = true
+	if err := s.RefreshCompromisedSafeZones(); err != nil {
+		log.Printf("Error checking imported safe zones against disaster zones: %v", err)
+	}
+	return report, nil
+}
+
+// importSafeZone writes one validated entry, recording the outcome in result.
+func importSafeZone(tx *sql.Tx, safeZone models.SafeZoneCreate, typeIDs []int, result *SafeZoneImportResult) error {
+	zoneID := 0
+	if safeZone.ExternalRef != nil {
+		err := tx.QueryRow(`SELECT zone_id FROM safe_zone WHERE external_ref = $1 FOR UPDATE`, *safeZone.ExternalRef).Scan(&zoneID)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("failed to look up safe zone %q: %w", *safeZone.ExternalRef, err)
+		}
+	}
+	if zoneID == 0 {
+		newID, err := insertSafeZone(tx, safeZone, typeIDs)
+		if err != nil {
+			return err
+		}
+		result.Action, result.ZoneID = ImportCreated, newID
+		return nil
+	}
+
+	updated, err := updateSafeZone(tx, zoneID, safeZone, typeIDs)
+	if err != nil {
+		return err
+	}
+	result.ZoneID = zoneID
+	if !updated {
+		result.Action = ImportRejected
+		result.Error = fmt.Errorf("%w: capacity is below the people checked in", ErrSafeZoneOccupied).Error()
+		return nil
+	}
+	result.Action = ImportUpdated
+	return nil
+}
+
+// incidentTypeIDs returns the set of known incident types.
+func (s *SafeZoneService) incidentTypeIDs() (map[int]bool, error) {
+	rows, err := s.DB.Query(`SELECT type_id FROM incident_type`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query incident types: %w", err)
+	}
+	defer rows.Close()
+
+	typeIDs := map[int]bool{}
+	for rows.Next() {
+		var typeID int
+		if err := rows.Scan(&typeID); err != nil {
+			return nil, fmt.Errorf("failed to scan incident type: %w", err)
+		}
+		typeIDs[typeID] = true
+	}
+	return typeIDs, rows.Err()
+}
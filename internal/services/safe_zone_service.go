@@ -1,41 +1,296 @@
 package services
 
 import (
+	"context"
 	"database/sql"
 	"disaster-response-map-api/internal/models"
+	"errors"
 	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+var (
+	ErrSafeZoneNotFound = errors.New("safe zone not found")
+	ErrInvalidSafeZone  = errors.New("invalid safe zone")
+	ErrSafeZoneClosed   = errors.New("safe zone is closed")
+	ErrSafeZoneFull     = errors.New("safe zone is full")
+	// ErrSafeZoneInactive is returned when checking people in to a deactivated zone.
+	ErrSafeZoneInactive = errors.New("safe zone is deactivated")
+	// ErrSafeZoneOccupied is returned when a change would leave more people
+	// in a zone than it may hold, or delete a zone people are checked in to.
+	ErrSafeZoneOccupied = errors.New("safe zone is occupied")
+	ErrInvalidOccupancy = errors.New("invalid occupancy change")
+	// ErrNotEnoughOccupants is returned when checking out more people than are checked in.
+	ErrNotEnoughOccupants = errors.New("not enough people checked in")
+	// ErrDuplicateExternalRef is returned when another safe zone already has
+	// the external_ref.
+	ErrDuplicateExternalRef = errors.New("external_ref is used by another safe zone")
 )
 
 type SafeZoneServiceInterface interface {
 	CreateSafeZone(safeZone models.SafeZoneCreate) (int, error)
-	GetSafeZones() ([]models.SafeZone, error)
+	GetSafeZones(filter SafeZoneFilter) ([]models.SafeZone, error)
+	GetSafeZone(zoneID int) (models.SafeZone, error)
+	ReplaceSafeZone(zoneID int, safeZone models.SafeZoneCreate) (models.SafeZone, error)
+	PatchSafeZone(zoneID int, patch models.SafeZonePatch) (models.SafeZone, error)
+	DeleteSafeZone(zoneID int) error
+	SetSafeZoneActive(zoneID int, active bool) (models.SafeZone, error)
+	CheckIn(zoneID, count int) (models.SafeZone, error)
+	CheckOut(zoneID, count int) (models.SafeZone, error)
+	ImportSafeZones(rows []SafeZoneImportRow, dryRun bool) (SafeZoneImportReport, error)
 }
 
 type SafeZoneService struct {
 	DB *sql.DB
+	// DZ supplies the active disaster zones that can compromise a safe zone.
+	DZ DisasterZoneServiceInterface
 }
 
-func NewSafeZoneService(db *sql.DB) *SafeZoneService {
-	return &SafeZoneService{DB: db}
+func NewSafeZoneService(db *sql.DB, dz DisasterZoneServiceInterface) *SafeZoneService {
+	return &SafeZoneService{DB: db, DZ: dz}
 }
 
-func (s *SafeZoneService) CreateSafeZone(safeZone models.SafeZoneCreate) (int, error) {
+// safeZoneColumns selects a safe zone from an unaliased safe_zone table,
+// including every incident type it serves.
+const safeZoneColumns = `zone_id, zone_name, zone_lat, zone_lon, incident_type_id, capacity, occupancy, is_open, active, compromised_by, compromised_at,
+        medical_staff, wheelchair_access, pets_allowed, water, power, beds, external_ref,
+        ARRAY(SELECT t.incident_type_id FROM safe_zone_incident_type t WHERE t.zone_id = safe_zone.zone_id ORDER BY t.incident_type_id) AS incident_type_ids`
+
+// servesIncidentType returns a condition matching safe zones that serve the
+// incident type at param.
+func servesIncidentType(param string) string {
+	return `EXISTS (SELECT 1 FROM safe_zone_incident_type t WHERE t.zone_id = safe_zone.zone_id AND t.incident_type_id = ` + param + `)`
+}
+
+// scanSafeZone reads a row of safeZoneColumns.
+func scanSafeZone(row rowScanner) (models.SafeZone, error) {
+	var sz models.SafeZone
+	var capacity, compromisedBy sql.NullInt64
+	var compromisedAt sql.NullTime
+	var externalRef sql.NullString
+	var typeIDs pq.Int64Array
+	if err := row.Scan(&sz.ZoneID, &sz.ZoneName, &sz.ZoneLat, &sz.ZoneLon, &sz.IncidentTypeID, &capacity, &sz.Occupancy, &sz.IsOpen, &sz.Active, &compromisedBy, &compromisedAt,
+		&sz.Facilities.MedicalStaff, &sz.Facilities.WheelchairAccess, &sz.Facilities.PetsAllowed, &sz.Facilities.Water, &sz.Facilities.Power, &sz.Facilities.Beds, &externalRef,
+		&typeIDs); err != nil {
+		return models.SafeZone{}, err
+	}
+	sz.IncidentTypeIDs = make([]int, len(typeIDs))
+	for i, typeID := range typeIDs {
+		sz.IncidentTypeIDs[i] = int(typeID)
+	}
+	if externalRef.Valid {
+		sz.ExternalRef = &externalRef.String
+	}
+	if compromisedBy.Valid {
+		incidentID := int(compromisedBy.Int64)
+		sz.CompromisedBy = &incidentID
+	}
+	if compromisedAt.Valid {
+		sz.CompromisedAt = &compromisedAt.Time
+	}
+	if capacity.Valid {
+		limit := int(capacity.Int64)
+		remaining := limit - sz.Occupancy
+		if remaining < 0 {
+			remaining = 0
+		}
+		sz.Capacity = &limit
+		sz.RemainingCapacity = &remaining
+	}
+	return sz, nil
+}
+
+// safeZoneIncidentTypes returns the distinct incident types a create or
+// replace payload assigns, primary type first.
+func safeZoneIncidentTypes(safeZone models.SafeZoneCreate) []int {
+	typeIDs := []int{}
+	seen := map[int]bool{}
+	for _, typeID := range append([]int{safeZone.IncidentTypeID}, safeZone.IncidentTypeIDs...) {
+		if typeID != 0 && !seen[typeID] {
+			seen[typeID] = true
+			typeIDs = append(typeIDs, typeID)
+		}
+	}
+	return typeIDs
+}
+
+// validateSafeZone checks a create or replace payload, including that its
+// incident types exist, and returns those types primary first.
+func (s *SafeZoneService) validateSafeZone(safeZone models.SafeZoneCreate) ([]int, error) {
+	return checkSafeZone(safeZone, func(typeID int) (bool, error) {
+		return incidentTypeExists(s.DB, typeID)
+	})
+}
+
+// checkSafeZone is validateSafeZone with the incident type lookup supplied
+// by the caller.
+func checkSafeZone(safeZone models.SafeZoneCreate, typeExists func(typeID int) (bool, error)) ([]int, error) {
+	typeIDs := safeZoneIncidentTypes(safeZone)
+	switch {
+	case strings.TrimSpace(safeZone.ZoneName) == "":
+		return nil, fmt.Errorf("%w: zone_name is required", ErrInvalidSafeZone)
+	case safeZone.ZoneLat < -90 || safeZone.ZoneLat > 90 || safeZone.ZoneLon < -180 || safeZone.ZoneLon > 180:
+		return nil, fmt.Errorf("%w: zone_lat/zone_lon out of range", ErrInvalidSafeZone)
+	case safeZone.Capacity != nil && *safeZone.Capacity < 0:
+		return nil, fmt.Errorf("%w: capacity must not be negative", ErrInvalidSafeZone)
+	case safeZone.Facilities != nil && safeZone.Facilities.Beds < 0:
+		return nil, fmt.Errorf("%w: beds must not be negative", ErrInvalidSafeZone)
+	case safeZone.ExternalRef != nil && strings.TrimSpace(*safeZone.ExternalRef) == "":
+		return nil, fmt.Errorf("%w: external_ref must not be blank", ErrInvalidSafeZone)
+	case len(typeIDs) == 0:
+		return nil, fmt.Errorf("%w: incident_type_id or incident_type_ids is required", ErrInvalidSafeZone)
+	}
+	for _, typeID := range typeIDs {
+		exists, err := typeExists(typeID)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			return nil, fmt.Errorf("%w: unknown incident type %d", ErrInvalidSafeZone, typeID)
+		}
+	}
+	return typeIDs, nil
+}
+
+// setSafeZoneIncidentTypes makes typeIDs the incident types a safe zone serves.
+func setSafeZoneIncidentTypes(tx *sql.Tx, zoneID int, typeIDs []int) error {
+	if _, err := tx.Exec(`DELETE FROM safe_zone_incident_type WHERE zone_id = $1 AND incident_type_id <> ALL($2)`, zoneID, pq.Array(typeIDs)); err != nil {
+		return fmt.Errorf("failed to update safe zone incident types: %w", err)
+	}
+	query := `
+        INSERT INTO safe_zone_incident_type (zone_id, incident_type_id)
+        SELECT $1, unnest($2::int[])
+        ON CONFLICT DO NOTHING
+    `
+	if _, err := tx.Exec(query, zoneID, pq.Array(typeIDs)); err != nil {
+		return fmt.Errorf("failed to update safe zone incident types: %w", err)
+	}
+	return nil
+}
+
+// safeZoneValues returns the stored values of a validated payload in column
+// order: zone_name, zone_lat, zone_lon, incident_type_id, capacity, is_open,
+// the facilities, then external_ref.
+func safeZoneValues(safeZone models.SafeZoneCreate, typeIDs []int) []interface{} {
+	isOpen := true
+	if safeZone.IsOpen != nil {
+		isOpen = *safeZone.IsOpen
+	}
+	var facilities models.SafeZoneFacilities
+	if safeZone.Facilities != nil {
+		facilities = *safeZone.Facilities
+	}
+	return []interface{}{
+		safeZone.ZoneName, safeZone.ZoneLat, safeZone.ZoneLon, typeIDs[0], safeZone.Capacity, isOpen,
+		facilities.MedicalStaff, facilities.WheelchairAccess, facilities.PetsAllowed, facilities.Water, facilities.Power, facilities.Beds,
+		safeZone.ExternalRef,
+	}
+}
+
+// insertSafeZone adds a validated safe zone and its incident types.
+func insertSafeZone(tx *sql.Tx, safeZone models.SafeZoneCreate, typeIDs []int) (int, error) {
 	var newID int
 	query := `
-        INSERT INTO safe_zone (zone_name, zone_lat, zone_lon, incident_type_id)
-        VALUES ($1, $2, $3, $4)
+        INSERT INTO safe_zone (zone_name, zone_lat, zone_lon, incident_type_id, capacity, is_open,
+                               medical_staff, wheelchair_access, pets_allowed, water, power, beds, external_ref)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
         RETURNING zone_id
     `
-	err := s.DB.QueryRow(query, safeZone.ZoneName, safeZone.ZoneLat, safeZone.ZoneLon, safeZone.IncidentTypeID).Scan(&newID)
+	if err := tx.QueryRow(query, safeZoneValues(safeZone, typeIDs)...).Scan(&newID); err != nil {
+		if externalRefTaken(err) {
+			return 0, fmt.Errorf("%w: %q", ErrDuplicateExternalRef, *safeZone.ExternalRef)
+		}
+		return 0, fmt.Errorf("failed to insert new safe zone: %w", err)
+	}
+	if err := setSafeZoneIncidentTypes(tx, newID, typeIDs); err != nil {
+		return 0, err
+	}
+	return newID, nil
+}
+
+// updateSafeZone overwrites a validated safe zone and its incident types,
+// keeping its external_ref when none is given. It returns false, changing
+// nothing, when the zone does not exist or holds more people than the new
+// capacity.
+func updateSafeZone(tx *sql.Tx, zoneID int, safeZone models.SafeZoneCreate, typeIDs []int) (bool, error) {
+	query := `
+        UPDATE safe_zone
+        SET zone_name = $2, zone_lat = $3, zone_lon = $4, incident_type_id = $5, capacity = $6, is_open = $7,
+            medical_staff = $8, wheelchair_access = $9, pets_allowed = $10, water = $11, power = $12, beds = $13,
+            external_ref = COALESCE($14, external_ref)
+        WHERE zone_id = $1 AND ($6::int IS NULL OR occupancy <= $6::int)
+        RETURNING zone_id
+    `
+	args := append([]interface{}{zoneID}, safeZoneValues(safeZone, typeIDs)...)
+	err := tx.QueryRow(query, args...).Scan(&zoneID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if externalRefTaken(err) {
+		return false, fmt.Errorf("%w: %q", ErrDuplicateExternalRef, *safeZone.ExternalRef)
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to update safe zone: %w", err)
+	}
+	return true, setSafeZoneIncidentTypes(tx, zoneID, typeIDs)
+}
+
+// externalRefTaken reports whether err is a violation of the unique index on
+// safe_zone.external_ref.
+func externalRefTaken(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == "safe_zone_external_ref_idx"
+}
+
+func (s *SafeZoneService) CreateSafeZone(safeZone models.SafeZoneCreate) (int, error) {
+	typeIDs, err := s.validateSafeZone(safeZone)
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert new safe zone: %w", err)
+	}
+	defer tx.Rollback()
+
+	newID, err := insertSafeZone(tx, safeZone, typeIDs)
 	if err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
 		return 0, fmt.Errorf("failed to insert new safe zone: %w", err)
 	}
+	if err := s.refreshCompromised(&newID); err != nil {
+		log.Printf("Error checking safe zone %d against disaster zones: %v", newID, err)
+	}
 	return newID, nil
 }
 
-func (s *SafeZoneService) GetSafeZones() ([]models.SafeZone, error) {
-	query := `SELECT zone_id, zone_name, zone_lat, zone_lon, incident_type_id FROM safe_zone`
-	rows, err := s.DB.Query(query)
+func (s *SafeZoneService) GetSafeZones(filter SafeZoneFilter) ([]models.SafeZone, error) {
+	var where whereClause
+	if !filter.IncludeInactive {
+		where.add("active")
+	}
+	if filter.Compromised != nil {
+		// The stored flag may lag incident changes made by another service
+		// by up to CompromisedRefreshInterval.
+		if *filter.Compromised {
+			where.add("compromised_by IS NOT NULL")
+		} else {
+			where.add("compromised_by IS NULL")
+		}
+	}
+	where.addSpatial(filter.SpatialFilter, "geog")
+	if filter.IncidentTypeID != nil {
+		where.add(servesIncidentType(where.arg(*filter.IncidentTypeID)))
+	}
+	query := `SELECT ` + safeZoneColumns + ` FROM safe_zone` + where.String() + ` ORDER BY zone_id`
+	rows, err := s.DB.Query(query, where.args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query safe zones: %v", err)
 	}
@@ -43,11 +298,262 @@ func (s *SafeZoneService) GetSafeZones() ([]models.SafeZone, error) {
 
 	var safeZones []models.SafeZone
 	for rows.Next() {
-		var sz models.SafeZone
-		if err := rows.Scan(&sz.ZoneID, &sz.ZoneName, &sz.ZoneLat, &sz.ZoneLon, &sz.IncidentTypeID); err != nil {
+		sz, err := scanSafeZone(rows)
+		if err != nil {
 			return nil, fmt.Errorf("failed to scan safe zone: %v", err)
 		}
 		safeZones = append(safeZones, sz)
 	}
 	return safeZones, nil
 }
+
+func (s *SafeZoneService) GetSafeZone(zoneID int) (models.SafeZone, error) {
+	row := s.DB.QueryRow(`SELECT `+safeZoneColumns+` FROM safe_zone WHERE zone_id = $1`, zoneID)
+	sz, err := scanSafeZone(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.SafeZone{}, ErrSafeZoneNotFound
+	}
+	if err != nil {
+		return models.SafeZone{}, fmt.Errorf("failed to query safe zone: %w", err)
+	}
+	return sz, nil
+}
+
+// ReplaceSafeZone overwrites a safe zone's details, keeping its occupancy.
+// The capacity may not drop below the people already checked in.
+func (s *SafeZoneService) ReplaceSafeZone(zoneID int, safeZone models.SafeZoneCreate) (models.SafeZone, error) {
+	typeIDs, err := s.validateSafeZone(safeZone)
+	if err != nil {
+		return models.SafeZone{}, err
+	}
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return models.SafeZone{}, fmt.Errorf("failed to update safe zone: %w", err)
+	}
+	defer tx.Rollback()
+
+	updated, err := updateSafeZone(tx, zoneID, safeZone, typeIDs)
+	if err != nil {
+		return models.SafeZone{}, err
+	}
+	if updated {
+		if err := tx.Commit(); err != nil {
+			return models.SafeZone{}, fmt.Errorf("failed to update safe zone: %w", err)
+		}
+		if err := s.refreshCompromised(&zoneID); err != nil {
+			log.Printf("Error checking safe zone %d against disaster zones: %v", zoneID, err)
+		}
+		return s.GetSafeZone(zoneID)
+	}
+	tx.Rollback()
+	current, err := s.GetSafeZone(zoneID)
+	if err != nil {
+		return models.SafeZone{}, err
+	}
+	return models.SafeZone{}, fmt.Errorf("%w: %d people are checked in", ErrSafeZoneOccupied, current.Occupancy)
+}
+
+// PatchSafeZone changes only the given fields of a safe zone.
+func (s *SafeZoneService) PatchSafeZone(zoneID int, patch models.SafeZonePatch) (models.SafeZone, error) {
+	current, err := s.GetSafeZone(zoneID)
+	if err != nil {
+		return models.SafeZone{}, err
+	}
+	updated := models.SafeZoneCreate{
+		ZoneName:        current.ZoneName,
+		ZoneLat:         current.ZoneLat,
+		ZoneLon:         current.ZoneLon,
+		IncidentTypeID:  current.IncidentTypeID,
+		IncidentTypeIDs: current.IncidentTypeIDs,
+		Capacity:        current.Capacity,
+		IsOpen:          &current.IsOpen,
+		Facilities:      &current.Facilities,
+		ExternalRef:     current.ExternalRef,
+	}
+	if patch.ZoneName != nil {
+		updated.ZoneName = *patch.ZoneName
+	}
+	if patch.ZoneLat != nil {
+		updated.ZoneLat = *patch.ZoneLat
+	}
+	if patch.ZoneLon != nil {
+		updated.ZoneLon = *patch.ZoneLon
+	}
+	if patch.IncidentTypeIDs != nil {
+		// The new list replaces the old one; its first entry becomes the
+		// primary type unless incident_type_id is patched too.
+		updated.IncidentTypeID = 0
+		updated.IncidentTypeIDs = patch.IncidentTypeIDs
+	}
+	if patch.IncidentTypeID != nil {
+		if patch.IncidentTypeIDs == nil {
+			// Swap the primary type, keeping the zone's other types.
+			updated.IncidentTypeIDs = nil
+			for _, typeID := range current.IncidentTypeIDs {
+				if typeID != current.IncidentTypeID {
+					updated.IncidentTypeIDs = append(updated.IncidentTypeIDs, typeID)
+				}
+			}
+		}
+		updated.IncidentTypeID = *patch.IncidentTypeID
+	}
+	if patch.Capacity != nil {
+		updated.Capacity = patch.Capacity
+	}
+	if patch.IsOpen != nil {
+		updated.IsOpen = patch.IsOpen
+	}
+	if patch.Facilities != nil {
+		updated.Facilities = patch.Facilities
+	}
+	return s.ReplaceSafeZone(zoneID, updated)
+}
+
+// DeleteSafeZone removes a safe zone. Zones people are checked in to must be
+// emptied or deactivated instead.
+func (s *SafeZoneService) DeleteSafeZone(zoneID int) error {
+	result, err := s.DB.Exec(`DELETE FROM safe_zone WHERE zone_id = $1 AND occupancy = 0`, zoneID)
+	if err != nil {
+		return fmt.Errorf("failed to delete safe zone: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to delete safe zone: %w", err)
+	}
+	if affected > 0 {
+		return nil
+	}
+	current, err := s.GetSafeZone(zoneID)
+	if err != nil {
+		return err
+	}
+	return fmt.Errorf("%w: %d people are checked in", ErrSafeZoneOccupied, current.Occupancy)
+}
+
+// SetSafeZoneActive deactivates or reactivates a safe zone. Deactivated zones
+// keep their data but are hidden from listings and evacuation.
+func (s *SafeZoneService) SetSafeZoneActive(zoneID int, active bool) (models.SafeZone, error) {
+	row := s.DB.QueryRow(`UPDATE safe_zone SET active = $2 WHERE zone_id = $1 RETURNING `+safeZoneColumns, zoneID, active)
+	sz, err := scanSafeZone(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.SafeZone{}, ErrSafeZoneNotFound
+	}
+	if err != nil {
+		return models.SafeZone{}, fmt.Errorf("failed to update safe zone: %w", err)
+	}
+	return sz, nil
+}
+
+// CompromisedRefreshInterval is how often every safe zone is re-checked, to
+// catch incidents changed by another service without going through /zones.
+const CompromisedRefreshInterval = time.Minute
+
+// RefreshCompromisedSafeZones flags every safe zone that lies inside an
+// active disaster zone and clears the flag on those that no longer do.
+func (s *SafeZoneService) RefreshCompromisedSafeZones() error {
+	return s.refreshCompromised(nil)
+}
+
+// RefreshCompromisedEvery runs RefreshCompromisedSafeZones at every interval
+// until ctx is done.
+func (s *SafeZoneService) RefreshCompromisedEvery(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.RefreshCompromisedSafeZones(); err != nil {
+				log.Printf("Error flagging compromised safe zones: %v", err)
+			}
+		}
+	}
+}
+
+// refreshCompromised updates the compromised flags of one safe zone, or of
+// all of them when zoneID is nil. A zone inside several active zones is
+// attributed to the lowest incident ID; compromised_at is kept while the
+// offending incident stays the same.
+func (s *SafeZoneService) refreshCompromised(zoneID *int) error {
+	activeZones, err := s.DZ.GetActiveDisasterZones(nil)
+	if err != nil {
+		return fmt.Errorf("failed to load active disaster zones: %w", err)
+	}
+	areas, err := zoneAreasJSON(activeZones)
+	if err != nil {
+		return fmt.Errorf("failed to encode active disaster zones: %w", err)
+	}
+
+	query := `
+        WITH ` + hazardsCTE("$1") + `, hit AS (
+            SELECT s.zone_id,
+                   (SELECT min(h.incident_id) FROM hazard h WHERE ST_Intersects(h.geog, s.geog)) AS incident_id
+            FROM safe_zone s
+            WHERE $2::int IS NULL OR s.zone_id = $2::int
+        )
+        UPDATE safe_zone s
+        SET compromised_by = hit.incident_id,
+            compromised_at = CASE WHEN hit.incident_id IS NULL THEN NULL ELSE now() END
+        FROM hit
+        WHERE s.zone_id = hit.zone_id AND s.compromised_by IS DISTINCT FROM hit.incident_id
+    `
+	if _, err := s.DB.Exec(query, areas, zoneID); err != nil {
+		return fmt.Errorf("failed to update compromised safe zones: %w", err)
+	}
+	return nil
+}
+
+// CheckIn records people arriving at an open safe zone, refusing any that
+// would take it over capacity.
+func (s *SafeZoneService) CheckIn(zoneID, count int) (models.SafeZone, error) {
+	if count <= 0 {
+		return models.SafeZone{}, fmt.Errorf("%w: count must be positive", ErrInvalidOccupancy)
+	}
+	return s.changeOccupancy(zoneID, count)
+}
+
+// CheckOut records people leaving a safe zone.
+func (s *SafeZoneService) CheckOut(zoneID, count int) (models.SafeZone, error) {
+	if count <= 0 {
+		return models.SafeZone{}, fmt.Errorf("%w: count must be positive", ErrInvalidOccupancy)
+	}
+	return s.changeOccupancy(zoneID, -count)
+}
+
+// changeOccupancy applies the change in a single conditional update so that
+// concurrent check-ins cannot overfill a zone.
+func (s *SafeZoneService) changeOccupancy(zoneID, delta int) (models.SafeZone, error) {
+	query := `
+        UPDATE safe_zone SET occupancy = occupancy + $2
+        WHERE zone_id = $1
+          AND occupancy + $2 >= 0
+          AND ($2 < 0 OR (active AND is_open AND (capacity IS NULL OR occupancy + $2 <= capacity)))
+        RETURNING ` + safeZoneColumns
+	sz, err := scanSafeZone(s.DB.QueryRow(query, zoneID, delta))
+	if err == nil {
+		return sz, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return models.SafeZone{}, fmt.Errorf("failed to update safe zone occupancy: %w", err)
+	}
+
+	// Nothing was updated; work out why.
+	current, err := s.GetSafeZone(zoneID)
+	if err != nil {
+		return models.SafeZone{}, err
+	}
+	switch {
+	case delta < 0:
+		return models.SafeZone{}, fmt.Errorf("%w: only %d people are checked in", ErrNotEnoughOccupants, current.Occupancy)
+	case !current.Active:
+		return models.SafeZone{}, ErrSafeZoneInactive
+	case !current.IsOpen:
+		return models.SafeZone{}, ErrSafeZoneClosed
+	case current.RemainingCapacity != nil:
+		return models.SafeZone{}, fmt.Errorf("%w: %d places remaining", ErrSafeZoneFull, *current.RemainingCapacity)
+	default:
+		return models.SafeZone{}, ErrSafeZoneFull
+	}
+}
@@ -0,0 +1,209 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+)
+
+const (
+	// trafficSamples is the most points along a route whose flow is looked up.
+	trafficSamples = 8
+	// trafficSectionLength is the shortest stretch of route, in metres, given
+	// a sample of its own.
+	trafficSectionLength = 250.0
+	// congestedRatio is the slow-down from free flow, as travel time, at which
+	// a stretch counts as congested.
+	congestedRatio = 1.25
+	// maxTrafficRatio caps the slow-down of a stretch, so that standing
+	// traffic does not make the ETA unbounded.
+	maxTrafficRatio = 10.0
+)
+
+// TrafficETA is a route's travel time adjusted with live traffic flow.
+type TrafficETA struct {
+	// FreeFlowTime is the routing engine's time in milliseconds and Time the
+	// estimate adjusted for traffic; Ratio is Time over FreeFlowTime.
+	FreeFlowTime int     `json:"free_flow_time" example:"780435"`
+	Time         int     `json:"time" example:"1170652"`
+	Ratio        float64 `json:"ratio" example:"1.5"`
+	// Samples is how many points along the route were looked up and Missing
+	// how many had no flow data; their stretches keep the free-flow time.
+	Samples int `json:"samples" example:"8"`
+	Missing int `json:"missing" example:"0"`
+	// RoadClosed is true when a stretch of the route is reported closed. The
+	// ETA cannot account for it.
+	RoadClosed        bool               `json:"road_closed" example:"false"`
+	CongestedSegments []CongestedSegment `json:"congested_segments"`
+}
+
+// CongestedSegment is a stretch of route where traffic is well below free flow.
+type CongestedSegment struct {
+	// Coordinates are the [lon, lat] positions of the stretch.
+	Coordinates   [][]float64 `json:"coordinates"`
+	Distance      float64     `json:"distance" example:"410.5"`
+	CurrentSpeed  float64     `json:"current_speed" example:"18"`
+	FreeFlowSpeed float64     `json:"free_flow_speed" example:"50"`
+	// Ratio is how many times longer the stretch takes than at free flow.
+	Ratio       float64 `json:"ratio" example:"2.78"`
+	RoadClosure bool    `json:"road_closure" example:"false"`
+}
+
+// lineSection is a stretch of a line of [lon, lat] positions.
+type lineSection struct {
+	coordinates [][]float64
+	length      float64
+	midpoint    []float64
+}
+
+// splitLine cuts a line of [lon, lat] positions into n stretches of equal
+// length.
+func splitLine(line [][]float64, n int) []lineSection {
+	cumulative := make([]float64, len(line))
+	for i := 1; i < len(line); i++ {
+		cumulative[i] = cumulative[i-1] + HaversineDistance(line[i-1][1], line[i-1][0], line[i][1], line[i][0])
+	}
+	total := cumulative[len(line)-1]
+
+	// pointAt returns the position at distance d along the line.
+	pointAt := func(d float64) []float64 {
+		for i := 1; i < len(line); i++ {
+			if cumulative[i] >= d {
+				span := cumulative[i] - cumulative[i-1]
+				if span == 0 {
+					return []float64{line[i][0], line[i][1]}
+				}
+				t := (d - cumulative[i-1]) / span
+				return []float64{line[i-1][0] + (line[i][0]-line[i-1][0])*t, line[i-1][1] + (line[i][1]-line[i-1][1])*t}
+			}
+		}
+		last := line[len(line)-1]
+		return []float64{last[0], last[1]}
+	}
+
+	sections := make([]lineSection, n)
+	for k := range sections {
+		start, end := total*float64(k)/float64(n), total*float64(k+1)/float64(n)
+		coordinates := [][]float64{pointAt(start)}
+		for i, d := range cumulative {
+			if d > start && d < end {
+				coordinates = append(coordinates, line[i])
+			}
+		}
+		coordinates = append(coordinates, pointAt(end))
+		sections[k] = lineSection{coordinates: coordinates, length: end - start, midpoint: pointAt((start + end) / 2)}
+	}
+	return sections
+}
+
+// trafficFlow holds the parts of a TomTom flow segment response that the ETA
+// uses; the rest of the response is ignored.
+type trafficFlow struct {
+	FlowSegmentData struct {
+		CurrentSpeed  float64 `json:"currentSpeed"`
+		FreeFlowSpeed float64 `json:"freeFlowSpeed"`
+		RoadClosure   bool    `json:"roadClosure"`
+	} `json:"flowSegmentData"`
+}
+
+// EstimateTrafficETA adjusts a route's travel time for live traffic. The
+// route is cut into up to trafficSamples stretches of equal length, each
+// stretch takes its share of the free-flow time by distance, and that share
+// is scaled by the free-flow to current speed ratio TomTom reports at the
+// stretch's midpoint. Flow is reported for the road nearest each midpoint,
+// which is the route's road except where roads run close together. Paths must
+// have been requested with points_encoded=false; an error is returned when no
+// stretch has flow data.
+func EstimateTrafficETA(traffic TrafficServiceInterface, path RoutePath) (TrafficETA, error) {
+	var line [][]float64
+	if err := decodeCoordinates(path.Points.Coordinates, &line); err != nil {
+		return TrafficETA{}, err
+	}
+	if len(line) < 2 {
+		return TrafficETA{}, fmt.Errorf("route has no geometry to sample traffic along")
+	}
+
+	n := int(math.Ceil(path.Distance / trafficSectionLength))
+	if n > trafficSamples {
+		n = trafficSamples
+	}
+	if n < 1 {
+		n = 1
+	}
+	sections := splitLine(line, n)
+
+	flows := make([]trafficFlow, len(sections))
+	errs := make([]error, len(sections))
+	var wg sync.WaitGroup
+	for i, section := range sections {
+		wg.Add(1)
+		go func(i int, midpoint []float64) {
+			defer wg.Done()
+			lat := strconv.FormatFloat(midpoint[1], 'f', 6, 64)
+			lon := strconv.FormatFloat(midpoint[0], 'f', 6, 64)
+			data, err := traffic.GetTrafficData(lat, lon)
+			if err == nil {
+				err = json.Unmarshal(data, &flows[i])
+			}
+			errs[i] = err
+		}(i, section.midpoint)
+	}
+	wg.Wait()
+
+	length := 0.0
+	for _, section := range sections {
+		length += section.length
+	}
+	eta := TrafficETA{FreeFlowTime: path.Time, Samples: len(sections), CongestedSegments: []CongestedSegment{}}
+	adjusted := 0.0
+	var firstErr error
+	for i, section := range sections {
+		share := float64(path.Time)
+		if length > 0 {
+			share *= section.length / length
+		}
+		flow := flows[i].FlowSegmentData
+		if errs[i] != nil || flow.FreeFlowSpeed <= 0 {
+			if firstErr == nil {
+				firstErr = errs[i]
+			}
+			eta.Missing++
+			adjusted += share
+			continue
+		}
+
+		ratio := maxTrafficRatio
+		if flow.CurrentSpeed > 0 {
+			ratio = math.Min(math.Max(flow.FreeFlowSpeed/flow.CurrentSpeed, 1), maxTrafficRatio)
+		}
+		adjusted += share * ratio
+		if flow.RoadClosure {
+			eta.RoadClosed = true
+		}
+		if ratio >= congestedRatio || flow.RoadClosure {
+			eta.CongestedSegments = append(eta.CongestedSegments, CongestedSegment{
+				Coordinates:   section.coordinates,
+				Distance:      section.length,
+				CurrentSpeed:  flow.CurrentSpeed,
+				FreeFlowSpeed: flow.FreeFlowSpeed,
+				Ratio:         ratio,
+				RoadClosure:   flow.RoadClosure,
+			})
+		}
+	}
+	if eta.Missing == eta.Samples {
+		if firstErr == nil {
+			firstErr = fmt.Errorf("no flow data")
+		}
+		return TrafficETA{}, fmt.Errorf("no traffic data along the route: %v", firstErr)
+	}
+
+	eta.Time = int(math.Round(adjusted))
+	eta.Ratio = 1
+	if path.Time > 0 {
+		eta.Ratio = adjusted / float64(path.Time)
+	}
+	return eta, nil
+}
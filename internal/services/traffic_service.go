@@ -24,16 +24,24 @@ type TrafficService struct {
 
 type TrafficResponse struct {
 	FlowSegmentData struct {
-		Coordinates        [][]float64 `json:"coordinates"`
-		CurrentSpeed       float64     `json:"currentSpeed"`
-		FreeFlowSpeed      float64     `json:"freeFlowSpeed"`
-		CurrentTravelTime  int         `json:"currentTravelTime"`
-		FreeFlowTravelTime int         `json:"freeFlowTravelTime"`
-		Confidence         int         `json:"confidence"`
-		RoadClosure        bool        `json:"roadClosure"`
+		Coordinates struct {
+			Coordinate []TrafficCoordinate `json:"coordinate"`
+		} `json:"coordinates"`
+		CurrentSpeed       float64 `json:"currentSpeed"`
+		FreeFlowSpeed      float64 `json:"freeFlowSpeed"`
+		CurrentTravelTime  int     `json:"currentTravelTime"`
+		FreeFlowTravelTime int     `json:"freeFlowTravelTime"`
+		Confidence         float64 `json:"confidence"`
+		RoadClosure        bool    `json:"roadClosure"`
 	} `json:"flowSegmentData"`
 }
 
+// TrafficCoordinate is one point of a TomTom flow segment.
+type TrafficCoordinate struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
 func NewTrafficService(url string, apiKey string) *TrafficService {
 	return &TrafficService{
 		APIKey:  apiKey,
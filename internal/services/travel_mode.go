@@ -0,0 +1,116 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// TravelMode is how an evacuee travels to a safe zone.
+type TravelMode string
+
+const (
+	ModeFoot       TravelMode = "foot"
+	ModeCar        TravelMode = "car"
+	ModeBike       TravelMode = "bike"
+	ModeWheelchair TravelMode = "wheelchair"
+	ModeBus        TravelMode = "bus"
+)
+
+var ErrInvalidTravelMode = errors.New("invalid travel mode")
+
+// travelProfile is the GraphHopper profile and custom model rules a travel
+// mode routes with.
+type travelProfile struct {
+	Profile         string
+	SnapPreventions []string
+	// Priority and Speed are custom model rules applied before the disaster
+	// zone rules.
+	Priority []map[string]interface{}
+	Speed    []map[string]interface{}
+}
+
+// travelProfiles maps each travel mode to how it is routed. Wheelchairs and
+// buses have no GraphHopper profile of their own, so they refine the foot and
+// car profiles with a custom model.
+var travelProfiles = map[TravelMode]travelProfile{
+	ModeFoot: {
+		Profile:         "foot",
+		SnapPreventions: []string{"motorway", "ferry", "tunnel"},
+	},
+	ModeCar: {
+		Profile:         "car",
+		SnapPreventions: []string{"ferry"},
+	},
+	ModeBike: {
+		Profile:         "bike",
+		SnapPreventions: []string{"motorway", "ferry", "tunnel"},
+	},
+	ModeWheelchair: {
+		Profile:         "foot",
+		SnapPreventions: []string{"motorway", "ferry", "tunnel"},
+		Priority: []map[string]interface{}{
+			{"if": "road_class == STEPS", "multiply_by": 0},
+			{"if": "average_slope > 6 || average_slope < -6", "multiply_by": 0},
+			{"if": "average_slope > 3 || average_slope < -3", "multiply_by": 0.5},
+			{"if": "surface == GRAVEL || surface == SAND || surface == DIRT || surface == GRASS || surface == COBBLESTONE", "multiply_by": 0.2},
+			{"if": "road_class == TRACK || road_class == PATH", "multiply_by": 0.3},
+		},
+		Speed: []map[string]interface{}{
+			{"if": "true", "limit_to": 4},
+		},
+	},
+	ModeBus: {
+		Profile:         "car",
+		SnapPreventions: []string{"ferry"},
+		Priority: []map[string]interface{}{
+			{"if": "max_height < 3.8 || max_width < 2.6 || max_weight < 18", "multiply_by": 0},
+			{"if": "road_class == TRACK || road_class == SERVICE", "multiply_by": 0.1},
+			{"if": "road_class == RESIDENTIAL", "multiply_by": 0.6},
+		},
+		Speed: []map[string]interface{}{
+			{"if": "true", "limit_to": 80},
+		},
+	},
+}
+
+// ParseTravelMode reads a travel mode, defaulting to foot.
+func ParseTravelMode(value string) (TravelMode, error) {
+	mode := TravelMode(strings.ToLower(strings.TrimSpace(value)))
+	if mode == "" {
+		return ModeFoot, nil
+	}
+	if _, ok := travelProfiles[mode]; !ok {
+		return "", fmt.Errorf("%w: %q, expected foot, car, bike, wheelchair or bus", ErrInvalidTravelMode, value)
+	}
+	return mode, nil
+}
+
+// profile returns how the mode is routed; unknown and empty modes route on foot.
+func (mode TravelMode) profile() travelProfile {
+	if profile, ok := travelProfiles[mode]; ok {
+		return profile
+	}
+	return travelProfiles[ModeFoot]
+}
+
+// drivesInTraffic reports whether the mode travels on roads with motor
+// traffic, so that its travel time depends on congestion.
+func (mode TravelMode) drivesInTraffic() bool {
+	return mode == ModeCar || mode == ModeBus
+}
+
+// travelCustomModel returns the custom model for routing in the mode around
+// the given zone areas, or nil when the plain profile will do.
+func travelCustomModel(mode TravelMode, areas []ZoneArea) map[string]interface{} {
+	profile := mode.profile()
+	if len(areas) == 0 && len(profile.Priority) == 0 && len(profile.Speed) == 0 {
+		return nil
+	}
+	model := BuildDisasterZonesCustomModel(areas)
+	model["priority"] = append(append([]map[string]interface{}{}, profile.Priority...), model["priority"].([]map[string]interface{})...)
+	if len(profile.Speed) > 0 {
+		model["speed"] = profile.Speed
+	}
+	return model
+}
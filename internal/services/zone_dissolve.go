@@ -0,0 +1,171 @@
+package services
+
+import (
+	"disaster-response-map-api/internal/models"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+)
+
+const (
+	defaultSimplifyTolerance = 5.0
+	defaultMaxZoneAreas      = 50
+	// metresPerDegree converts the simplify tolerance to the degrees used by
+	// planar geometry in EPSG:4326.
+	metresPerDegree = 111320.0
+)
+
+// dissolveQuery unions zone areas of the same ring that overlap or touch and
+// share the same priority, simplifies the merged outlines and, when a ring
+// has more than $3 areas left, groups neighbouring ones together so the
+// custom model stays small. Priorities are absolute, the factor reached
+// inside the area, and a grouped area takes the strictest of its members.
+const dissolveQuery = `
+    WITH zone AS (
+        SELECT (a->>'incident_id')::int AS incident_id,
+               (a->>'ring')::int AS ring,
+               (a->>'priority')::float8 AS priority,
+               ST_GeomFromGeoJSON(a->>'geometry') AS geom
+        FROM jsonb_array_elements($1::jsonb) AS a
+    ), clustered AS (
+        SELECT incident_id, ring, priority, geom,
+               ST_ClusterDBSCAN(geom, 0, 1) OVER (PARTITION BY ring, priority) AS cluster
+        FROM zone
+    ), merged AS (
+        SELECT ring, priority, array_agg(incident_id) AS incident_ids, ST_Union(geom) AS geom
+        FROM clustered
+        GROUP BY ring, priority, cluster
+    ), capped AS (
+        SELECT ring, priority, incident_ids, geom,
+               CASE WHEN count(*) OVER (PARTITION BY ring) > $3
+                    THEN ST_ClusterKMeans(ST_PointOnSurface(geom), $3) OVER (PARTITION BY ring)
+                    ELSE row_number() OVER (PARTITION BY ring)
+               END AS area
+        FROM merged
+    )
+    SELECT ring,
+           jsonb_agg(to_jsonb(incident_ids)),
+           min(priority),
+           ST_AsGeoJSON(ST_SimplifyPreserveTopology(ST_Union(geom), $2))
+    FROM capped
+    GROUP BY ring, area
+`
+
+type dissolveInput struct {
+	IncidentID int                    `json:"incident_id"`
+	Ring       int                    `json:"ring"`
+	Priority   float64                `json:"priority"`
+	Geometry   map[string]interface{} `json:"geometry"`
+}
+
+// DissolveDisasterZones turns zones into custom model areas at the given
+// avoidance level, merging overlapping areas of equal ring and priority. Each
+// merged area is named after its lowest incident ID and lists every incident
+// it covers. MaxAreas is shared between the zones and their buffer rings.
+func (s *DisasterZoneService) DissolveDisasterZones(zones []models.DisasterZone, level AvoidanceLevel) ([]ZoneArea, error) {
+	areas := ZoneAreas(zones, level)
+	if len(areas) < 2 {
+		return areas, nil
+	}
+
+	// Ring factors are steps from the disc around them, which cannot be
+	// compared across zones, so areas are merged on the product of the
+	// factors of every disc they lie in.
+	absolute := map[int]float64{}
+	input := make([]dissolveInput, 0, len(areas))
+	for _, area := range areas {
+		id := area.IncidentIDs[0]
+		if _, ok := absolute[id]; !ok {
+			absolute[id] = 1
+		}
+		absolute[id] *= area.Priority
+		input = append(input, dissolveInput{IncidentID: id, Ring: area.Ring, Priority: absolute[id], Geometry: area.Geometry})
+	}
+	encoded, err := json.Marshal(input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode zone areas: %w", err)
+	}
+
+	maxPerRing := s.MaxAreas / (bufferRings + 1)
+	if maxPerRing < 1 {
+		maxPerRing = 1
+	}
+	rows, err := s.DB.Query(dissolveQuery, string(encoded), s.SimplifyTolerance/metresPerDegree, maxPerRing)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dissolve zone areas: %w", err)
+	}
+	defer rows.Close()
+
+	dissolved := []ZoneArea{}
+	for rows.Next() {
+		var incidentGroups, geometry []byte
+		var area ZoneArea
+		if err := rows.Scan(&area.Ring, &incidentGroups, &area.Priority, &geometry); err != nil {
+			return nil, fmt.Errorf("failed to scan zone area: %w", err)
+		}
+		var groups [][]int
+		if err := json.Unmarshal(incidentGroups, &groups); err != nil {
+			return nil, fmt.Errorf("failed to decode zone area incidents: %w", err)
+		}
+		for _, group := range groups {
+			area.IncidentIDs = append(area.IncidentIDs, group...)
+		}
+		if len(area.IncidentIDs) == 0 {
+			continue
+		}
+		sort.Ints(area.IncidentIDs)
+		area.AreaID = zoneRingAreaID(area.IncidentIDs[0], area.Ring)
+		if err := json.Unmarshal(geometry, &area.Geometry); err != nil {
+			return nil, fmt.Errorf("failed to decode zone area geometry: %w", err)
+		}
+		dissolved = append(dissolved, area)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read zone areas: %w", err)
+	}
+	stepPriorities(dissolved)
+	// Outer rings first, matching ZoneAreas.
+	sort.SliceStable(dissolved, func(i, j int) bool {
+		if dissolved[i].Ring != dissolved[j].Ring {
+			return dissolved[i].Ring > dissolved[j].Ring
+		}
+		return dissolved[i].IncidentIDs[0] < dissolved[j].IncidentIDs[0]
+	})
+	return dissolved, nil
+}
+
+// stepPriorities turns the absolute priorities of dissolved areas back into
+// the step from the area around them. A ring's enclosing areas are those of
+// the next ring out holding any of its incidents; when there are several, or
+// an incident has no ring around it, the step is taken from the most lenient
+// so that no road ends up with a higher priority than its strictest zone.
+func stepPriorities(areas []ZoneArea) {
+	enclosing := map[int]map[int]float64{}
+	for _, area := range areas {
+		if enclosing[area.Ring] == nil {
+			enclosing[area.Ring] = map[int]float64{}
+		}
+		for _, id := range area.IncidentIDs {
+			enclosing[area.Ring][id] = area.Priority
+		}
+	}
+	steps := make([]float64, len(areas))
+	for i, area := range areas {
+		outer := 0.0
+		for _, id := range area.IncidentIDs {
+			priority, ok := enclosing[area.Ring+1][id]
+			if !ok {
+				priority = 1
+			}
+			outer = math.Max(outer, priority)
+		}
+		steps[i] = area.Priority
+		if outer > 0 {
+			steps[i] = math.Min(area.Priority/outer, 1)
+		}
+	}
+	for i := range areas {
+		areas[i].Priority = steps[i]
+	}
+}
@@ -0,0 +1,109 @@
+package services
+
+import (
+	"database/sql"
+	"disaster-response-map-api/internal/models"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrZoneRuleNotFound = errors.New("zone rule not found")
+	ErrInvalidZoneRule  = errors.New("invalid zone rule")
+)
+
+type ZoneRuleServiceInterface interface {
+	GetZoneRules() ([]models.ZoneRule, error)
+	UpsertZoneRule(rule models.ZoneRule) (models.ZoneRule, error)
+	DeleteZoneRule(ruleID int) error
+}
+
+type ZoneRuleService struct {
+	DB *sql.DB
+	// OnChange, if set, is called after a rule is stored or deleted, since
+	// that resizes the zones it applies to.
+	OnChange func()
+}
+
+func NewZoneRuleService(db *sql.DB) *ZoneRuleService {
+	return &ZoneRuleService{DB: db}
+}
+
+func (s *ZoneRuleService) GetZoneRules() ([]models.ZoneRule, error) {
+	query := `SELECT rule_id, type_id, severity_id, radius_m, buffer_m, avoidance FROM zone_rule ORDER BY type_id NULLS FIRST, severity_id`
+	rows, err := s.DB.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query zone rules: %w", err)
+	}
+	defer rows.Close()
+
+	var rules []models.ZoneRule
+	for rows.Next() {
+		var rule models.ZoneRule
+		var typeID sql.NullInt64
+		if err := rows.Scan(&rule.RuleID, &typeID, &rule.SeverityID, &rule.Radius, &rule.Buffer, &rule.Avoidance); err != nil {
+			return nil, fmt.Errorf("failed to scan zone rule: %w", err)
+		}
+		if typeID.Valid {
+			id := int(typeID.Int64)
+			rule.TypeID = &id
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// UpsertZoneRule creates the rule for the rule's type and severity, or
+// replaces the existing one.
+func (s *ZoneRuleService) UpsertZoneRule(rule models.ZoneRule) (models.ZoneRule, error) {
+	if err := ValidateZoneRule(rule); err != nil {
+		return models.ZoneRule{}, err
+	}
+	query := `
+        INSERT INTO zone_rule (type_id, severity_id, radius_m, buffer_m, avoidance)
+        VALUES ($1, $2, $3, $4, $5)
+        ON CONFLICT ((COALESCE(type_id, 0)), severity_id)
+        DO UPDATE SET radius_m = EXCLUDED.radius_m, buffer_m = EXCLUDED.buffer_m, avoidance = EXCLUDED.avoidance
+        RETURNING rule_id
+    `
+	err := s.DB.QueryRow(query, rule.TypeID, rule.SeverityID, rule.Radius, rule.Buffer, rule.Avoidance).Scan(&rule.RuleID)
+	if err != nil {
+		return models.ZoneRule{}, fmt.Errorf("failed to store zone rule: %w", err)
+	}
+	s.changed()
+	return rule, nil
+}
+
+func (s *ZoneRuleService) DeleteZoneRule(ruleID int) error {
+	result, err := s.DB.Exec(`DELETE FROM zone_rule WHERE rule_id = $1`, ruleID)
+	if err != nil {
+		return fmt.Errorf("failed to delete zone rule: %w", err)
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return ErrZoneRuleNotFound
+	}
+	s.changed()
+	return nil
+}
+
+func (s *ZoneRuleService) changed() {
+	if s.OnChange != nil {
+		s.OnChange()
+	}
+}
+
+func ValidateZoneRule(rule models.ZoneRule) error {
+	switch {
+	case rule.SeverityID <= 0:
+		return fmt.Errorf("%w: severity_id must be positive", ErrInvalidZoneRule)
+	case rule.TypeID != nil && *rule.TypeID <= 0:
+		return fmt.Errorf("%w: type_id must be positive", ErrInvalidZoneRule)
+	case rule.Radius <= 0:
+		return fmt.Errorf("%w: radius must be positive", ErrInvalidZoneRule)
+	case rule.Buffer < 0:
+		return fmt.Errorf("%w: buffer must not be negative", ErrInvalidZoneRule)
+	case rule.Avoidance < 0 || rule.Avoidance > 1:
+		return fmt.Errorf("%w: avoidance must be between 0 and 1", ErrInvalidZoneRule)
+	}
+	return nil
+}
@@ -0,0 +1,7 @@
+-- Drawn Polygon/MultiPolygon outlines for incidents. Incidents without a row
+-- here are treated as circles around their latitude/longitude.
+CREATE TABLE IF NOT EXISTS zone_geometry (
+    incident_id INTEGER PRIMARY KEY REFERENCES incident (incident_id) ON DELETE CASCADE,
+    geometry    JSONB       NOT NULL,
+    updated_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
@@ -0,0 +1,15 @@
+-- Per incident type and severity zone sizing. A NULL type_id applies to every
+-- incident type without a rule of its own; incidents with no matching rule at
+-- all fall back to 18 m per severity level and an avoidance of
+-- 1 - 0.5^severity, so low-severity zones slow routes rather than block them.
+CREATE TABLE IF NOT EXISTS zone_rule (
+    rule_id     SERIAL PRIMARY KEY,
+    type_id     INTEGER REFERENCES incident_type (type_id) ON DELETE CASCADE,
+    severity_id INTEGER          NOT NULL,
+    radius_m    DOUBLE PRECISION NOT NULL CHECK (radius_m > 0),
+    buffer_m    DOUBLE PRECISION NOT NULL DEFAULT 0 CHECK (buffer_m >= 0),
+    avoidance   DOUBLE PRECISION NOT NULL DEFAULT 1 CHECK (avoidance BETWEEN 0 AND 1)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS zone_rule_type_severity_idx
+    ON zone_rule ((COALESCE(type_id, 0)), severity_id);
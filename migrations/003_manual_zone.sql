@@ -0,0 +1,41 @@
+-- Hazard areas drawn by coordinators that are not backed by an incident report.
+-- IDs are drawn from the incident sequence so that incident and manual zones
+-- share one ID space under /zones/{id}.
+CREATE TABLE IF NOT EXISTS manual_zone (
+    zone_id     INTEGER PRIMARY KEY DEFAULT nextval(pg_get_serial_sequence('incident', 'incident_id')::regclass),
+    zone_name   TEXT             NOT NULL,
+    type_id     INTEGER          NOT NULL REFERENCES incident_type (type_id),
+    severity_id INTEGER          NOT NULL,
+    status_id   INTEGER          NOT NULL,
+    latitude    DOUBLE PRECISION NOT NULL,
+    longitude   DOUBLE PRECISION NOT NULL,
+    geometry    JSONB,
+    created_at  TIMESTAMPTZ      NOT NULL DEFAULT now(),
+    updated_at  TIMESTAMPTZ      NOT NULL DEFAULT now()
+);
+
+-- Every disaster zone, whether it comes from an incident report or was drawn by hand.
+CREATE OR REPLACE VIEW disaster_zone_view AS
+SELECT i.incident_id,
+       t.type_name AS incident_name,
+       i.type_id,
+       i.severity_id,
+       i.status_id,
+       i.latitude,
+       i.longitude,
+       g.geometry,
+       'incident'::TEXT AS source
+FROM incident i
+JOIN incident_type t ON i.type_id = t.type_id
+LEFT JOIN zone_geometry g ON g.incident_id = i.incident_id
+UNION ALL
+SELECT m.zone_id,
+       m.zone_name,
+       m.type_id,
+       m.severity_id,
+       m.status_id,
+       m.latitude,
+       m.longitude,
+       m.geometry,
+       'manual'::TEXT
+FROM manual_zone m;
@@ -0,0 +1,96 @@
+-- Versioned copies of every disaster zone. Each change to an incident, its
+-- drawn outline or a manual zone closes the zone's open version (valid_to)
+-- and opens a new one, so the zone set at any moment can be reconstructed.
+-- Radius, buffer and avoidance are recorded from 013_zone_history_size.sql on.
+CREATE TABLE IF NOT EXISTS disaster_zone_history (
+    history_id    BIGSERIAL PRIMARY KEY,
+    incident_id   INTEGER          NOT NULL,
+    incident_name TEXT             NOT NULL,
+    type_id       INTEGER          NOT NULL,
+    severity_id   INTEGER          NOT NULL,
+    status_id     INTEGER          NOT NULL,
+    latitude      DOUBLE PRECISION NOT NULL,
+    longitude     DOUBLE PRECISION NOT NULL,
+    geometry      JSONB,
+    source        TEXT             NOT NULL,
+    valid_from    TIMESTAMPTZ      NOT NULL,
+    valid_to      TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS disaster_zone_history_zone_idx
+    ON disaster_zone_history (incident_id, valid_from);
+CREATE INDEX IF NOT EXISTS disaster_zone_history_validity_idx
+    ON disaster_zone_history (valid_from, valid_to);
+
+-- Brings the history of one zone in line with disaster_zone_view. Nothing is
+-- recorded if the zone's visible attributes did not change.
+CREATE OR REPLACE FUNCTION record_disaster_zone_version(zone_id INTEGER) RETURNS VOID AS $$
+BEGIN
+    IF EXISTS (
+        SELECT 1
+        FROM disaster_zone_history h
+        JOIN disaster_zone_view z ON z.incident_id = h.incident_id
+        WHERE h.incident_id = zone_id
+          AND h.valid_to IS NULL
+          AND (h.incident_name, h.type_id, h.severity_id, h.status_id, h.latitude, h.longitude, h.geometry, h.source)
+              IS NOT DISTINCT FROM
+              (z.incident_name, z.type_id, z.severity_id, z.status_id, z.latitude, z.longitude, z.geometry, z.source)
+    ) THEN
+        RETURN;
+    END IF;
+
+    UPDATE disaster_zone_history
+    SET valid_to = now()
+    WHERE incident_id = zone_id AND valid_to IS NULL;
+
+    INSERT INTO disaster_zone_history
+        (incident_id, incident_name, type_id, severity_id, status_id, latitude, longitude, geometry, source, valid_from)
+    SELECT incident_id, incident_name, type_id, severity_id, status_id, latitude, longitude, geometry, source, now()
+    FROM disaster_zone_view
+    WHERE incident_id = zone_id;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Row trigger; TG_ARGV[0] names the column holding the zone ID.
+CREATE OR REPLACE FUNCTION disaster_zone_history_trigger() RETURNS TRIGGER AS $$
+DECLARE
+    old_id INTEGER;
+    new_id INTEGER;
+BEGIN
+    IF TG_OP IN ('UPDATE', 'DELETE') THEN
+        old_id := (to_jsonb(OLD) ->> TG_ARGV[0])::INTEGER;
+        PERFORM record_disaster_zone_version(old_id);
+    END IF;
+    IF TG_OP IN ('INSERT', 'UPDATE') THEN
+        new_id := (to_jsonb(NEW) ->> TG_ARGV[0])::INTEGER;
+        IF new_id IS DISTINCT FROM old_id THEN
+            PERFORM record_disaster_zone_version(new_id);
+        END IF;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS incident_zone_history ON incident;
+CREATE TRIGGER incident_zone_history
+    AFTER INSERT OR UPDATE OR DELETE ON incident
+    FOR EACH ROW EXECUTE FUNCTION disaster_zone_history_trigger('incident_id');
+
+DROP TRIGGER IF EXISTS zone_geometry_history ON zone_geometry;
+CREATE TRIGGER zone_geometry_history
+    AFTER INSERT OR UPDATE OR DELETE ON zone_geometry
+    FOR EACH ROW EXECUTE FUNCTION disaster_zone_history_trigger('incident_id');
+
+DROP TRIGGER IF EXISTS manual_zone_history ON manual_zone;
+CREATE TRIGGER manual_zone_history
+    AFTER INSERT OR UPDATE OR DELETE ON manual_zone
+    FOR EACH ROW EXECUTE FUNCTION disaster_zone_history_trigger('zone_id');
+
+-- Open a first version for every zone that exists today.
+INSERT INTO disaster_zone_history
+    (incident_id, incident_name, type_id, severity_id, status_id, latitude, longitude, geometry, source, valid_from)
+SELECT z.incident_id, z.incident_name, z.type_id, z.severity_id, z.status_id, z.latitude, z.longitude, z.geometry, z.source, now()
+FROM disaster_zone_view z
+WHERE NOT EXISTS (
+    SELECT 1 FROM disaster_zone_history h WHERE h.incident_id = z.incident_id AND h.valid_to IS NULL
+);
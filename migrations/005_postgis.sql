@@ -0,0 +1,55 @@
+-- Geography columns and GiST indexes so that spatial filters and nearest
+-- neighbour lookups run in PostGIS and can use an index.
+CREATE EXTENSION IF NOT EXISTS postgis;
+
+ALTER TABLE safe_zone
+    ADD COLUMN IF NOT EXISTS geog geography(Point, 4326)
+    GENERATED ALWAYS AS (ST_SetSRID(ST_MakePoint(zone_lon, zone_lat), 4326)::geography) STORED;
+CREATE INDEX IF NOT EXISTS safe_zone_geog_idx ON safe_zone USING GIST (geog);
+
+-- Incident points are indexed by expression to leave the incident table's columns alone.
+CREATE INDEX IF NOT EXISTS incident_geog_idx
+    ON incident USING GIST ((ST_SetSRID(ST_MakePoint(longitude, latitude), 4326)::geography));
+
+ALTER TABLE zone_geometry
+    ADD COLUMN IF NOT EXISTS geog geography
+    GENERATED ALWAYS AS (ST_GeomFromGeoJSON(geometry::text)::geography) STORED;
+CREATE INDEX IF NOT EXISTS zone_geometry_geog_idx ON zone_geometry USING GIST (geog);
+
+-- A manual zone's shape is its drawn outline, or its centre point when it has none.
+ALTER TABLE manual_zone
+    ADD COLUMN IF NOT EXISTS geog geography
+    GENERATED ALWAYS AS (COALESCE(ST_GeomFromGeoJSON(geometry::text), ST_SetSRID(ST_MakePoint(longitude, latitude), 4326))::geography) STORED;
+CREATE INDEX IF NOT EXISTS manual_zone_geog_idx ON manual_zone USING GIST (geog);
+
+ALTER TABLE disaster_zone_history
+    ADD COLUMN IF NOT EXISTS geog geography
+    GENERATED ALWAYS AS (COALESCE(ST_GeomFromGeoJSON(geometry::text), ST_SetSRID(ST_MakePoint(longitude, latitude), 4326))::geography) STORED;
+CREATE INDEX IF NOT EXISTS disaster_zone_history_geog_idx ON disaster_zone_history USING GIST (geog);
+
+CREATE OR REPLACE VIEW disaster_zone_view AS
+SELECT i.incident_id,
+       t.type_name AS incident_name,
+       i.type_id,
+       i.severity_id,
+       i.status_id,
+       i.latitude,
+       i.longitude,
+       g.geometry,
+       'incident'::TEXT AS source,
+       COALESCE(g.geog, ST_SetSRID(ST_MakePoint(i.longitude, i.latitude), 4326)::geography) AS geog
+FROM incident i
+JOIN incident_type t ON i.type_id = t.type_id
+LEFT JOIN zone_geometry g ON g.incident_id = i.incident_id
+UNION ALL
+SELECT m.zone_id,
+       m.zone_name,
+       m.type_id,
+       m.severity_id,
+       m.status_id,
+       m.latitude,
+       m.longitude,
+       m.geometry,
+       'manual'::TEXT,
+       m.geog
+FROM manual_zone m;
@@ -0,0 +1,6 @@
+-- Shelter capacity and occupancy. A NULL capacity means the shelter has no
+-- known limit.
+ALTER TABLE safe_zone
+    ADD COLUMN IF NOT EXISTS capacity  INTEGER CHECK (capacity >= 0),
+    ADD COLUMN IF NOT EXISTS occupancy INTEGER NOT NULL DEFAULT 0 CHECK (occupancy >= 0),
+    ADD COLUMN IF NOT EXISTS is_open   BOOLEAN NOT NULL DEFAULT TRUE;
@@ -0,0 +1,4 @@
+-- Deactivated safe zones are kept for the record but hidden from listings
+-- and never chosen for evacuation.
+ALTER TABLE safe_zone
+    ADD COLUMN IF NOT EXISTS active BOOLEAN NOT NULL DEFAULT TRUE;
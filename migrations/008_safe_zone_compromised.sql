@@ -0,0 +1,6 @@
+-- The active disaster zone a safe zone lies in, if any. The zone ID is not a
+-- foreign key because zones come from both incident and manual_zone.
+ALTER TABLE safe_zone
+    ADD COLUMN IF NOT EXISTS compromised_by INTEGER,
+    ADD COLUMN IF NOT EXISTS compromised_at TIMESTAMPTZ;
+CREATE INDEX IF NOT EXISTS safe_zone_compromised_idx ON safe_zone (compromised_by) WHERE compromised_by IS NOT NULL;
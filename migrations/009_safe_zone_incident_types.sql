@@ -0,0 +1,13 @@
+-- A safe zone can serve several incident types, e.g. a sports hall used as
+-- both a flood and a fire shelter. safe_zone.incident_type_id is kept as the
+-- zone's primary type for existing clients.
+CREATE TABLE IF NOT EXISTS safe_zone_incident_type (
+    zone_id          INTEGER NOT NULL REFERENCES safe_zone (zone_id) ON DELETE CASCADE,
+    incident_type_id INTEGER NOT NULL REFERENCES incident_type (type_id) ON DELETE CASCADE,
+    PRIMARY KEY (zone_id, incident_type_id)
+);
+CREATE INDEX IF NOT EXISTS safe_zone_incident_type_type_idx ON safe_zone_incident_type (incident_type_id);
+
+INSERT INTO safe_zone_incident_type (zone_id, incident_type_id)
+SELECT zone_id, incident_type_id FROM safe_zone WHERE incident_type_id IS NOT NULL
+ON CONFLICT DO NOTHING;
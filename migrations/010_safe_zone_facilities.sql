@@ -0,0 +1,8 @@
+-- Facilities a safe zone offers, used to match evacuees with particular needs.
+ALTER TABLE safe_zone
+    ADD COLUMN IF NOT EXISTS medical_staff     BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN IF NOT EXISTS wheelchair_access BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN IF NOT EXISTS pets_allowed      BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN IF NOT EXISTS water             BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN IF NOT EXISTS power             BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN IF NOT EXISTS beds              INTEGER NOT NULL DEFAULT 0 CHECK (beds >= 0);
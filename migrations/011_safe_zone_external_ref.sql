@@ -0,0 +1,5 @@
+-- The reference a safe zone has in the authority's own shelter list, used to
+-- match rows when the list is imported again.
+ALTER TABLE safe_zone
+    ADD COLUMN IF NOT EXISTS external_ref TEXT;
+CREATE UNIQUE INDEX IF NOT EXISTS safe_zone_external_ref_idx ON safe_zone (external_ref) WHERE external_ref IS NOT NULL;
@@ -0,0 +1,28 @@
+-- Evacuation routes that were issued and kept for review. The safe zone is
+-- copied as it was when the route was issued; zone_id still points at the
+-- live zone until it is deleted. incident_id is the disaster zone being
+-- evacuated, which may be an incident or a manual zone, so it has no foreign
+-- key.
+CREATE TABLE IF NOT EXISTS evacuation (
+    evacuation_id    SERIAL PRIMARY KEY,
+    incident_id      INTEGER,
+    incident_type_id INTEGER          NOT NULL,
+    origin_lat       DOUBLE PRECISION NOT NULL,
+    origin_lon       DOUBLE PRECISION NOT NULL,
+    dest_lat         DOUBLE PRECISION NOT NULL,
+    dest_lon         DOUBLE PRECISION NOT NULL,
+    zone_id          INTEGER REFERENCES safe_zone (zone_id) ON DELETE SET NULL,
+    safe_zone        JSONB,
+    mode             TEXT             NOT NULL,
+    distance         DOUBLE PRECISION NOT NULL,
+    travel_time      BIGINT           NOT NULL,
+    route            JSONB,
+    requested_by     TEXT,
+    status           TEXT             NOT NULL DEFAULT 'issued'
+        CHECK (status IN ('issued', 'in_progress', 'arrived', 'cancelled')),
+    created_at       TIMESTAMPTZ      NOT NULL DEFAULT now(),
+    updated_at       TIMESTAMPTZ      NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS evacuation_incident_idx ON evacuation (incident_id);
+CREATE INDEX IF NOT EXISTS evacuation_zone_idx ON evacuation (zone_id);
@@ -0,0 +1,90 @@
+-- Record each zone version with the radius, buffer and avoidance it had, so
+-- that as_of and history show the zones as responders saw them even after a
+-- zone rule is edited. A rule change records a new version of every zone it
+-- resizes.
+
+-- The size the zone rules give a zone: the rule for its incident type and
+-- severity, then the rule for every type at that severity, then 18 m per
+-- severity level with avoidance 1 - 0.5^severity.
+CREATE OR REPLACE FUNCTION zone_size(
+    zone_type_id     INTEGER,
+    zone_severity_id INTEGER,
+    OUT radius_m     DOUBLE PRECISION,
+    OUT buffer_m     DOUBLE PRECISION,
+    OUT avoidance    DOUBLE PRECISION
+) AS $$
+    SELECT r.radius_m, r.buffer_m, r.avoidance
+    FROM (
+        SELECT radius_m, buffer_m, avoidance, 0 AS preference
+        FROM zone_rule
+        WHERE type_id = zone_type_id AND severity_id = zone_severity_id
+        UNION ALL
+        SELECT radius_m, buffer_m, avoidance, 1
+        FROM zone_rule
+        WHERE type_id IS NULL AND severity_id = zone_severity_id
+        UNION ALL
+        SELECT zone_severity_id * 18.0, 0, 1 - power(0.5, zone_severity_id), 2
+    ) r
+    ORDER BY r.preference
+    LIMIT 1
+$$ LANGUAGE sql STABLE;
+
+ALTER TABLE disaster_zone_history
+    ADD COLUMN IF NOT EXISTS radius_m  DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS buffer_m  DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS avoidance DOUBLE PRECISION;
+
+-- Versions recorded before this migration can only be given today's rules.
+UPDATE disaster_zone_history h
+SET (radius_m, buffer_m, avoidance) = (SELECT s.radius_m, s.buffer_m, s.avoidance FROM zone_size(h.type_id, h.severity_id) s)
+WHERE h.radius_m IS NULL;
+
+ALTER TABLE disaster_zone_history
+    ALTER COLUMN radius_m SET NOT NULL,
+    ALTER COLUMN buffer_m SET NOT NULL,
+    ALTER COLUMN avoidance SET NOT NULL;
+
+-- Brings the history of one zone in line with disaster_zone_view and the
+-- zone rules. Nothing is recorded if neither changed for the zone.
+CREATE OR REPLACE FUNCTION record_disaster_zone_version(zone_id INTEGER) RETURNS VOID AS $$
+BEGIN
+    IF EXISTS (
+        SELECT 1
+        FROM disaster_zone_history h
+        JOIN disaster_zone_view z ON z.incident_id = h.incident_id
+        CROSS JOIN LATERAL zone_size(z.type_id, z.severity_id) s
+        WHERE h.incident_id = zone_id
+          AND h.valid_to IS NULL
+          AND (h.incident_name, h.type_id, h.severity_id, h.status_id, h.latitude, h.longitude, h.geometry, h.source, h.radius_m, h.buffer_m, h.avoidance)
+              IS NOT DISTINCT FROM
+              (z.incident_name, z.type_id, z.severity_id, z.status_id, z.latitude, z.longitude, z.geometry, z.source, s.radius_m, s.buffer_m, s.avoidance)
+    ) THEN
+        RETURN;
+    END IF;
+
+    UPDATE disaster_zone_history
+    SET valid_to = now()
+    WHERE incident_id = zone_id AND valid_to IS NULL;
+
+    INSERT INTO disaster_zone_history
+        (incident_id, incident_name, type_id, severity_id, status_id, latitude, longitude, geometry, source, radius_m, buffer_m, avoidance, valid_from)
+    SELECT z.incident_id, z.incident_name, z.type_id, z.severity_id, z.status_id, z.latitude, z.longitude, z.geometry, z.source, s.radius_m, s.buffer_m, s.avoidance, now()
+    FROM disaster_zone_view z
+    CROSS JOIN LATERAL zone_size(z.type_id, z.severity_id) s
+    WHERE z.incident_id = zone_id;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Statement trigger on zone_rule: re-records every zone, which only adds a
+-- version for the zones whose size changed.
+CREATE OR REPLACE FUNCTION zone_rule_history_trigger() RETURNS TRIGGER AS $$
+BEGIN
+    PERFORM record_disaster_zone_version(z.incident_id) FROM disaster_zone_view z;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS zone_rule_history ON zone_rule;
+CREATE TRIGGER zone_rule_history
+    AFTER INSERT OR UPDATE OR DELETE ON zone_rule
+    FOR EACH STATEMENT EXECUTE FUNCTION zone_rule_history_trigger();
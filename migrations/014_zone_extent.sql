@@ -0,0 +1,18 @@
+-- Give each recorded zone version the ground it covers: its drawn outline, or
+-- the disc of its radius plus buffer around the centre. The open version of a
+-- zone always matches the live zone, so the bbox and near filters use this
+-- column for live zones as well as for as_of.
+DROP INDEX IF EXISTS disaster_zone_history_geog_idx;
+ALTER TABLE disaster_zone_history DROP COLUMN IF EXISTS geog;
+
+ALTER TABLE disaster_zone_history
+    ADD COLUMN geog geography
+    GENERATED ALWAYS AS (COALESCE(
+        ST_GeomFromGeoJSON(geometry::text)::geography,
+        ST_Buffer(ST_SetSRID(ST_MakePoint(longitude, latitude), 4326)::geography, radius_m + buffer_m)
+    )) STORED;
+
+CREATE INDEX IF NOT EXISTS disaster_zone_history_geog_idx
+    ON disaster_zone_history USING GIST (geog);
+CREATE INDEX IF NOT EXISTS disaster_zone_history_current_geog_idx
+    ON disaster_zone_history USING GIST (geog) WHERE valid_to IS NULL;
@@ -0,0 +1,6 @@
+-- The hazards a kept evacuation was routed around, as they were when it was
+-- issued: the zones the origin lay in, how far the route ran inside active
+-- zones, and every active zone with its size. Evacuations kept before this
+-- migration have none.
+ALTER TABLE evacuation
+    ADD COLUMN IF NOT EXISTS conditions JSONB;
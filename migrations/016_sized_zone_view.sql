@@ -0,0 +1,18 @@
+-- Live zones with the size zone_size() gives them. zone_size() is the only
+-- implementation of the zone rules, so the API and the recorded history
+-- always agree on how large a zone is.
+CREATE OR REPLACE VIEW sized_disaster_zone_view AS
+SELECT z.incident_id,
+       z.incident_name,
+       z.type_id,
+       z.severity_id,
+       z.status_id,
+       z.latitude,
+       z.longitude,
+       z.geometry,
+       z.source,
+       s.radius_m,
+       s.buffer_m,
+       s.avoidance
+FROM disaster_zone_view z
+CROSS JOIN LATERAL zone_size(z.type_id, z.severity_id) s;
@@ -8,6 +8,10 @@ import (
 	"github.com/golang-jwt/jwt/v4"
 )
 
+// SubjectKey is the context key under which the auth middlewares store the
+// sub claim of a valid token.
+const SubjectKey = "subject"
+
 func AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
@@ -16,18 +20,39 @@ func AuthMiddleware() gin.HandlerFunc {
 			c.Abort()
 			return
 		}
+		authenticate(c, authHeader)
+	}
+}
 
-		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			return []byte(config.JWT_SECRET), nil
-		})
-
-		if err != nil || !token.Valid {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
-			c.Abort()
+// OptionalAuthMiddleware checks a token like AuthMiddleware when one is sent,
+// and lets requests without one through.
+func OptionalAuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authHeader := c.GetHeader("Authorization")
+		if authHeader == "" {
+			c.Next()
 			return
 		}
+		authenticate(c, authHeader)
+	}
+}
+
+func authenticate(c *gin.Context, authHeader string) {
+	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		return []byte(config.JWT_SECRET), nil
+	})
 
-		c.Next()
+	if err != nil || !token.Valid {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		c.Abort()
+		return
+	}
+
+	if claims, ok := token.Claims.(jwt.MapClaims); ok {
+		if subject, ok := claims["sub"].(string); ok && subject != "" {
+			c.Set(SubjectKey, subject)
+		}
 	}
+	c.Next()
 }
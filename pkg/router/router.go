@@ -1,37 +1,102 @@
 package router
 
 import (
+	"log"
+
+	"disaster-response-map-api/config"
 	"disaster-response-map-api/internal/handlers"
 	"disaster-response-map-api/internal/services"
 	"disaster-response-map-api/pkg/database"
+	"disaster-response-map-api/pkg/middleware"
 
 	"github.com/gin-gonic/gin"
 )
 
+// NewDisasterZoneService returns a disaster zone service using the active
+// statuses from ACTIVE_ZONE_STATUSES.
+func NewDisasterZoneService(db *database.Database) *services.DisasterZoneService {
+	dzService := services.NewDisasterZoneService(db.DB)
+	if statuses, err := services.ParseStatusIDs(config.ACTIVE_ZONE_STATUSES); err != nil {
+		log.Printf("Ignoring ACTIVE_ZONE_STATUSES: %v", err)
+	} else if len(statuses) > 0 {
+		dzService.DefaultActiveStatuses = statuses
+	}
+	return dzService
+}
+
 // SetupRouter initializes the Gin router and routes.
 // It now accepts both a database and a GraphHopper service.
 func SetupRouter(db *database.Database, ghService *services.GraphHopperService, tfService *services.TrafficService) *gin.Engine {
 	r := gin.Default()
-	dzService := services.NewDisasterZoneService(db.DB)
+	dzService := NewDisasterZoneService(db)
 	// Create disaster zone handler (using db)
 	disasterZoneHandler := handlers.NewDisasterZoneHandler(dzService)
 	r.GET("/zones", disasterZoneHandler.GetDisasterZones)
+	r.GET("/zones/:id", disasterZoneHandler.GetDisasterZone)
+	r.GET("/zones/:id/history", disasterZoneHandler.GetDisasterZoneHistory)
+	// Zone changes reroute evacuees, so they need the same auth as admin.
+	zones := r.Group("/zones", middleware.AuthMiddleware())
+	zones.POST("", disasterZoneHandler.CreateDisasterZone)
+	zones.PUT("/:id", disasterZoneHandler.ReplaceDisasterZone)
+	zones.PATCH("/:id", disasterZoneHandler.PatchDisasterZone)
+	zones.DELETE("/:id", disasterZoneHandler.DeleteDisasterZone)
+	zones.PUT("/:id/geometry", disasterZoneHandler.SetZoneGeometry)
+	zones.DELETE("/:id/geometry", disasterZoneHandler.ClearZoneGeometry)
+	r.GET("/incident-statuses", disasterZoneHandler.GetIncidentStatuses)
 	// Traffic handler (using tfService)
 	trafficHandler := handlers.NewTrafficHandler(tfService)
 	r.GET("/traffic", trafficHandler.GetTrafficData)
 	// Routing handler
 	routingHandler := handlers.NewRoutingHandler(ghService, dzService)
+	routingHandler.Traffic = tfService
 	r.GET("/routing", routingHandler.GetSafeRouting)
 
 	r.GET("/route", routingHandler.GetDefaultRoute)
 	// Evacuation endpoint (POST)
-	evacService := services.NewEvacuationService(db.DB, ghService) // assuming db.DB is *sql.DB
+	evacService := services.NewEvacuationService(db.DB, ghService, dzService) // assuming db.DB is *sql.DB
+	evacService.Traffic = tfService
 	evacuationHandler := handlers.NewEvacuationHandler(evacService)
-	r.POST("/evacuation", evacuationHandler.GetEvacuationRoute)
+	// Anyone may ask for a route, but saving one records the caller.
+	r.POST("/evacuation", middleware.OptionalAuthMiddleware(), evacuationHandler.GetEvacuationRoute)
+	r.POST("/evacuation/plan", evacuationHandler.PlanEvacuation)
+	r.GET("/isochrone", evacuationHandler.GetIsochrone)
+	r.GET("/evacuations", evacuationHandler.GetEvacuations)
+	r.GET("/evacuations/:id", evacuationHandler.GetEvacuation)
+	evacuations := r.Group("/evacuations", middleware.AuthMiddleware())
+	evacuations.PATCH("/:id", evacuationHandler.UpdateEvacuationStatus)
 
-	safeZoneService := services.NewSafeZoneService(db.DB)
+	safeZoneService := services.NewSafeZoneService(db.DB, dzService)
+	// Re-check safe zones against the active zones whenever zones or the
+	// rules sizing them change.
+	refreshSafeZones := func() {
+		if err := safeZoneService.RefreshCompromisedSafeZones(); err != nil {
+			log.Printf("Error flagging compromised safe zones: %v", err)
+		}
+	}
+	dzService.OnChange = refreshSafeZones
+	rules := services.NewZoneRuleService(db.DB)
+	rules.OnChange = refreshSafeZones
 	safeZoneHandler := handlers.NewSafeZoneHandler(safeZoneService)
 	r.POST("/safezones", safeZoneHandler.CreateSafeZone)
 	r.GET("/safezones", safeZoneHandler.GetSafeZones)
+	r.GET("/safezones/:id", safeZoneHandler.GetSafeZone)
+	// Safe zone changes decide where evacuees are sent, so like zone changes
+	// they need a JWT.
+	safeZones := r.Group("/safezones", middleware.AuthMiddleware())
+	safeZones.POST("/import", safeZoneHandler.ImportSafeZones)
+	safeZones.PUT("/:id", safeZoneHandler.ReplaceSafeZone)
+	safeZones.PATCH("/:id", safeZoneHandler.PatchSafeZone)
+	safeZones.DELETE("/:id", safeZoneHandler.DeleteSafeZone)
+	safeZones.POST("/:id/deactivate", safeZoneHandler.DeactivateSafeZone)
+	safeZones.POST("/:id/reactivate", safeZoneHandler.ReactivateSafeZone)
+	safeZones.POST("/:id/check-in", safeZoneHandler.CheckIn)
+	safeZones.POST("/:id/check-out", safeZoneHandler.CheckOut)
+
+	// Admin endpoints require a valid JWT
+	admin := r.Group("/admin", middleware.AuthMiddleware())
+	zoneRuleHandler := handlers.NewZoneRuleHandler(rules)
+	admin.GET("/zone-rules", zoneRuleHandler.GetZoneRules)
+	admin.PUT("/zone-rules", zoneRuleHandler.UpsertZoneRule)
+	admin.DELETE("/zone-rules/:id", zoneRuleHandler.DeleteZoneRule)
 	return r
 }
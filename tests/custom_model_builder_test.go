@@ -0,0 +1,164 @@
+package tests
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"testing"
+
+	"disaster-response-map-api/internal/models"
+	"disaster-response-map-api/internal/services"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildDisasterZonesCustomModel_PolygonAndCircle(t *testing.T) {
+	multiPolygon := models.ZoneGeometry{
+		Type: "MultiPolygon",
+		Coordinates: json.RawMessage(`[
+			[[[-6.27,53.34],[-6.25,53.34],[-6.25,53.36],[-6.27,53.36],[-6.27,53.34]],
+			 [[-6.265,53.345],[-6.255,53.345],[-6.255,53.355],[-6.265,53.345]]],
+			[[[-6.20,53.30],[-6.19,53.30],[-6.19,53.31],[-6.20,53.30]]]
+		]`),
+	}
+	zones := []models.DisasterZone{
+		{IncidentID: 7, Latitude: 53.35, Longitude: -6.26, Radius: 36, Geometry: &multiPolygon},
+		{IncidentID: 9, Latitude: 53.349805, Longitude: -6.26031, Radius: 18},
+	}
+
+	model := services.BuildDisasterZonesCustomModel(services.ZoneAreas(zones, services.AvoidanceStrict))
+
+	features := model["areas"].(map[string]interface{})["features"].([]map[string]interface{})
+	assert.Len(t, features, 2)
+	assert.Equal(t, "disaster_zone_7", features[0]["id"])
+	assert.Equal(t, "MultiPolygon", features[0]["geometry"].(map[string]interface{})["type"])
+	assert.Equal(t, "disaster_zone_9", features[1]["id"])
+	assert.Equal(t, "Polygon", features[1]["geometry"].(map[string]interface{})["type"])
+}
+
+func TestDissolveDisasterZones_MergesOverlappingZones(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	zones := []models.DisasterZone{
+		{IncidentID: 12, Latitude: 53.35, Longitude: -6.26, Radius: 50, Avoidance: 1},
+		{IncidentID: 4, Latitude: 53.3502, Longitude: -6.2601, Radius: 50, Avoidance: 1},
+		{IncidentID: 30, Latitude: 53.30, Longitude: -6.20, Radius: 50, Avoidance: 1},
+	}
+	rows := sqlmock.NewRows([]string{"ring", "incident_ids", "priority", "geometry"}).
+		AddRow(0, []byte(`[[12, 4]]`), 0.0, []byte(`{"type":"Polygon","coordinates":[[[-6.261,53.349],[-6.259,53.349],[-6.259,53.351],[-6.261,53.349]]]}`)).
+		AddRow(0, []byte(`[[30]]`), 0.0, []byte(`{"type":"Polygon","coordinates":[[[-6.201,53.299],[-6.199,53.299],[-6.199,53.301],[-6.201,53.299]]]}`))
+	mock.ExpectQuery(`ST_ClusterDBSCAN`).
+		WithArgs(sqlmock.AnyArg(), 5.0/111320.0, 12).
+		WillReturnRows(rows)
+
+	service := services.NewDisasterZoneService(db)
+	areas, err := service.DissolveDisasterZones(zones, services.AvoidanceBalanced)
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	assert.Len(t, areas, 2)
+	assert.Equal(t, "disaster_zone_4", areas[0].AreaID)
+	assert.Equal(t, []int{4, 12}, areas[0].IncidentIDs)
+	assert.Equal(t, "disaster_zone_30", areas[1].AreaID)
+
+	model := services.BuildDisasterZonesCustomModel(areas)
+	assert.Len(t, model["priority"], 2)
+}
+
+// dissolvePriorities captures the priority sent for each ring of each
+// incident in the dissolve query's JSON argument.
+type dissolvePriorities map[int]map[int]float64
+
+func (p dissolvePriorities) Match(v driver.Value) bool {
+	var input []struct {
+		IncidentID int     `json:"incident_id"`
+		Ring       int     `json:"ring"`
+		Priority   float64 `json:"priority"`
+	}
+	if err := json.Unmarshal([]byte(v.(string)), &input); err != nil {
+		return false
+	}
+	for _, area := range input {
+		if p[area.IncidentID] == nil {
+			p[area.IncidentID] = map[int]float64{}
+		}
+		p[area.IncidentID][area.Ring] = area.Priority
+	}
+	return true
+}
+
+func TestDissolveDisasterZones_GroupsOnAbsolutePriorities(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	zones := []models.DisasterZone{
+		{IncidentID: 1, Latitude: 53.35, Longitude: -6.26, Radius: 100, Buffer: 60, Avoidance: 0.8},
+		{IncidentID: 2, Latitude: 53.36, Longitude: -6.25, Radius: 100, Buffer: 60, Avoidance: 0.4},
+	}
+	sent := dissolvePriorities{}
+	disc := []byte(`{"type":"Polygon","coordinates":[[[-6.261,53.349],[-6.259,53.349],[-6.259,53.351],[-6.261,53.349]]]}`)
+	// The area cap grouped both zones in every ring, keeping the strictest
+	// absolute priority, which is zone 1's.
+	rows := sqlmock.NewRows([]string{"ring", "incident_ids", "priority", "geometry"}).
+		AddRow(3, []byte(`[[1], [2]]`), 0.8, disc).
+		AddRow(2, []byte(`[[1], [2]]`), 0.6, disc).
+		AddRow(1, []byte(`[[1], [2]]`), 0.4, disc).
+		AddRow(0, []byte(`[[1], [2]]`), 0.2, disc)
+	mock.ExpectQuery(`ST_ClusterKMeans`).WithArgs(sent, sqlmock.AnyArg(), sqlmock.AnyArg()).WillReturnRows(rows)
+
+	service := services.NewDisasterZoneService(db)
+	areas, err := service.DissolveDisasterZones(zones, services.AvoidanceBalanced)
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	// Each ring is sent at the priority reached inside it, not its step.
+	assert.InDelta(t, 0.8, sent[1][3], 1e-9)
+	assert.InDelta(t, 0.4, sent[1][1], 1e-9)
+	assert.InDelta(t, 0.2, sent[1][0], 1e-9)
+	assert.InDelta(t, 0.6, sent[2][0], 1e-9)
+
+	if !assert.Len(t, areas, 4) {
+		return
+	}
+	// The steps multiply back up to zone 1's priority in the grouped zone.
+	product := 1.0
+	for _, area := range areas {
+		assert.LessOrEqual(t, area.Priority, 1.0)
+		product *= area.Priority
+	}
+	assert.InDelta(t, 0.2, product, 1e-9)
+	assert.InDelta(t, 0.75, areas[1].Priority, 1e-9)
+}
+
+func TestZoneAreas_BufferRingsByAvoidanceLevel(t *testing.T) {
+	zones := []models.DisasterZone{
+		{IncidentID: 5, Latitude: 53.35, Longitude: -6.26, Radius: 100, Buffer: 60, Avoidance: 0.8},
+	}
+
+	balanced := services.ZoneAreas(zones, services.AvoidanceBalanced)
+	assert.Len(t, balanced, 4)
+	assert.Equal(t, "disaster_zone_5_ring_3", balanced[0].AreaID)
+	assert.Equal(t, "disaster_zone_5", balanced[3].AreaID)
+	// Roads in the zone lie inside every disc, so the factors multiply up to
+	// the zone's own priority of 1 - avoidance.
+	product := 1.0
+	for _, area := range balanced {
+		assert.LessOrEqual(t, area.Priority, 1.0)
+		product *= area.Priority
+	}
+	assert.InDelta(t, 0.2, product, 1e-9)
+	assert.InDelta(t, 0.8, balanced[0].Priority, 1e-9)
+
+	strict := services.ZoneAreas(zones, services.AvoidanceStrict)
+	assert.Equal(t, 0.0, strict[len(strict)-1].Priority)
+
+	minimal := services.ZoneAreas(zones, services.AvoidanceMinimal)
+	assert.Len(t, minimal, 1)
+	assert.InDelta(t, 0.6, minimal[0].Priority, 1e-9)
+
+	_, err := services.ParseAvoidanceLevel("reckless")
+	assert.ErrorIs(t, err, services.ErrInvalidAvoidance)
+}
@@ -1,28 +1,46 @@
 package tests
 
 import (
+	"bytes"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"disaster-response-map-api/internal/handlers"
 	"disaster-response-map-api/internal/models"
+	"disaster-response-map-api/internal/services"
 
+	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 )
 
-type MockDisasterZoneService struct{}
+type MockDisasterZoneService struct {
+	lastFilter services.DisasterZoneFilter
+}
 
-func (m *MockDisasterZoneService) GetDisasterZones() ([]models.DisasterZone, error) {
+func (m *MockDisasterZoneService) GetDisasterZones(filter services.DisasterZoneFilter) ([]models.DisasterZone, error) {
+	m.lastFilter = filter
 	zones := []models.DisasterZone{
 		{IncidentID: 1, IncidentName: "Flood Zone", Latitude: 53.349805, Longitude: -6.26031, Radius: 30.5},
 		{IncidentID: 2, IncidentName: "Earthquake Zone", Latitude: 53.3478, Longitude: -6.2597, Radius: 25.0},
 	}
 	return zones, nil
 }
-func (m *MockDisasterZoneService) GetActiveDisasterZones() ([]models.DisasterZone, error) {
+func (m *MockDisasterZoneService) ActiveStatuses() []int {
+	return []int{services.StatusActive}
+}
+
+func (m *MockDisasterZoneService) GetIncidentStatuses() ([]models.IncidentStatus, error) {
+	return []models.IncidentStatus{
+		{StatusID: services.StatusActive, StatusName: "active", Active: true},
+		{StatusID: 4, StatusName: "resolved"},
+	}, nil
+}
+
+func (m *MockDisasterZoneService) GetActiveDisasterZones(statuses []int) ([]models.DisasterZone, error) {
 	zones := []models.DisasterZone{
 		{IncidentID: 1, IncidentName: "Flood Zone", Latitude: 53.349805, Longitude: -6.26031, Radius: 30.5},
 		{IncidentID: 2, IncidentName: "Earthquake Zone", Latitude: 53.3478, Longitude: -6.2597, Radius: 25.0},
@@ -30,6 +48,80 @@ func (m *MockDisasterZoneService) GetActiveDisasterZones() ([]models.DisasterZon
 	return zones, nil
 }
 
+func (m *MockDisasterZoneService) GetDisasterZone(incidentID int) (models.DisasterZone, error) {
+	zones, _ := m.GetDisasterZones(services.DisasterZoneFilter{})
+	for _, zone := range zones {
+		if zone.IncidentID == incidentID {
+			return zone, nil
+		}
+	}
+	return models.DisasterZone{}, services.ErrDisasterZoneNotFound
+}
+
+func (m *MockDisasterZoneService) SetZoneGeometry(incidentID int, geometry models.ZoneGeometry) error {
+	if _, err := services.ParseZoneGeometry(geometry); err != nil {
+		return err
+	}
+	_, err := m.GetDisasterZone(incidentID)
+	return err
+}
+
+func (m *MockDisasterZoneService) ClearZoneGeometry(incidentID int) error {
+	_, err := m.GetDisasterZone(incidentID)
+	return err
+}
+
+func (m *MockDisasterZoneService) CreateDisasterZone(zone models.DisasterZoneCreate) (models.DisasterZone, error) {
+	if zone.ZoneName == "" {
+		return models.DisasterZone{}, services.ErrInvalidDisasterZone
+	}
+	return models.DisasterZone{
+		IncidentID:   101,
+		IncidentName: zone.ZoneName,
+		TypeID:       zone.TypeID,
+		SeverityID:   zone.SeverityID,
+		StatusID:     services.StatusActive,
+		Latitude:     *zone.Latitude,
+		Longitude:    *zone.Longitude,
+		Geometry:     zone.Geometry,
+		Source:       services.ZoneSourceManual,
+	}, nil
+}
+
+func (m *MockDisasterZoneService) ReplaceDisasterZone(zoneID int, zone models.DisasterZoneCreate) (models.DisasterZone, error) {
+	if _, err := m.GetDisasterZone(zoneID); err != nil {
+		return models.DisasterZone{}, err
+	}
+	return models.DisasterZone{}, services.ErrZoneNotEditable
+}
+
+func (m *MockDisasterZoneService) PatchDisasterZone(zoneID int, patch models.DisasterZonePatch) (models.DisasterZone, error) {
+	return m.ReplaceDisasterZone(zoneID, models.DisasterZoneCreate{})
+}
+
+func (m *MockDisasterZoneService) DeleteDisasterZone(zoneID int) error {
+	_, err := m.ReplaceDisasterZone(zoneID, models.DisasterZoneCreate{})
+	return err
+}
+
+func (m *MockDisasterZoneService) DissolveDisasterZones(zones []models.DisasterZone, level services.AvoidanceLevel) ([]services.ZoneArea, error) {
+	return services.ZoneAreas(zones, level), nil
+}
+
+func (m *MockDisasterZoneService) GetDisasterZoneHistory(zoneID int) ([]models.DisasterZoneVersion, error) {
+	zone, err := m.GetDisasterZone(zoneID)
+	if err != nil {
+		return nil, err
+	}
+	escalated := time.Date(2025, 4, 11, 10, 0, 0, 0, time.UTC)
+	original := zone
+	original.SeverityID = 1
+	return []models.DisasterZoneVersion{
+		{DisasterZone: original, ValidFrom: escalated.Add(-time.Hour), ValidTo: &escalated},
+		{DisasterZone: zone, ValidFrom: escalated},
+	}, nil
+}
+
 func TestGetDisasterZonesHandler_Happy(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	mockService := &MockDisasterZoneService{}
@@ -51,3 +143,247 @@ func TestGetDisasterZonesHandler_Happy(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Len(t, zones, 2)
 }
+
+func TestGetDisasterZoneHandler_Happy(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	handler := handlers.NewDisasterZoneHandler(&MockDisasterZoneService{})
+
+	router := gin.Default()
+	router.GET("/zones/:id", handler.GetDisasterZone)
+
+	req, err := http.NewRequest(http.MethodGet, "/zones/2", nil)
+	assert.NoError(t, err)
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+
+	var zone models.DisasterZone
+	err = json.Unmarshal(recorder.Body.Bytes(), &zone)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, zone.IncidentID)
+	assert.Equal(t, "Earthquake Zone", zone.IncidentName)
+}
+
+func TestGetDisasterZoneHandler_NotFound(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	handler := handlers.NewDisasterZoneHandler(&MockDisasterZoneService{})
+
+	router := gin.Default()
+	router.GET("/zones/:id", handler.GetDisasterZone)
+
+	req, err := http.NewRequest(http.MethodGet, "/zones/99", nil)
+	assert.NoError(t, err)
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+}
+
+func TestSetZoneGeometryHandler_InvalidRing(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	handler := handlers.NewDisasterZoneHandler(&MockDisasterZoneService{})
+
+	router := gin.Default()
+	router.PUT("/zones/:id/geometry", handler.SetZoneGeometry)
+
+	// The ring is not closed.
+	payload := []byte(`{"type":"Polygon","coordinates":[[[-6.27,53.34],[-6.25,53.34],[-6.25,53.36],[-6.27,53.36]]]}`)
+	req, err := http.NewRequest(http.MethodPut, "/zones/2/geometry", bytes.NewBuffer(payload))
+	assert.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+}
+
+func TestCreateDisasterZoneHandler_Happy(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	handler := handlers.NewDisasterZoneHandler(&MockDisasterZoneService{})
+
+	router := gin.Default()
+	router.POST("/zones", handler.CreateDisasterZone)
+
+	payload := []byte(`{"zone_name": "Flooded underpass", "type_id": 1, "severity_id": 2, "latitude": 53.3498, "longitude": -6.2603}`)
+	req, err := http.NewRequest(http.MethodPost, "/zones", bytes.NewBuffer(payload))
+	assert.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+
+	assert.Equal(t, http.StatusCreated, recorder.Code)
+
+	var zone models.DisasterZone
+	err = json.Unmarshal(recorder.Body.Bytes(), &zone)
+	assert.NoError(t, err)
+	assert.Equal(t, "manual", zone.Source)
+	assert.Equal(t, "Flooded underpass", zone.IncidentName)
+}
+
+func TestDeleteDisasterZoneHandler_IncidentZoneConflict(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	handler := handlers.NewDisasterZoneHandler(&MockDisasterZoneService{})
+
+	router := gin.Default()
+	router.DELETE("/zones/:id", handler.DeleteDisasterZone)
+
+	req, err := http.NewRequest(http.MethodDelete, "/zones/2", nil)
+	assert.NoError(t, err)
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+
+	assert.Equal(t, http.StatusConflict, recorder.Code)
+}
+
+func TestGetDisasterZonesHandler_Filters(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockService := &MockDisasterZoneService{}
+	handler := handlers.NewDisasterZoneHandler(mockService)
+
+	router := gin.Default()
+	router.GET("/zones", handler.GetDisasterZones)
+
+	req, err := http.NewRequest(http.MethodGet, "/zones?bbox=-6.3,53.3,-6.2,53.4&type=2&severity>=3&status=3", nil)
+	assert.NoError(t, err)
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	filter := mockService.lastFilter
+	assert.Equal(t, &services.BoundingBox{MinLon: -6.3, MinLat: 53.3, MaxLon: -6.2, MaxLat: 53.4}, filter.BBox)
+	assert.Equal(t, 2, *filter.TypeID)
+	assert.Equal(t, 3, *filter.MinSeverity)
+	assert.Equal(t, 3, *filter.StatusID)
+	assert.Nil(t, filter.Near)
+}
+
+func TestGetDisasterZonesHandler_NearWithoutWithin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	handler := handlers.NewDisasterZoneHandler(&MockDisasterZoneService{})
+
+	router := gin.Default()
+	router.GET("/zones", handler.GetDisasterZones)
+
+	req, err := http.NewRequest(http.MethodGet, "/zones?near=53.3498,-6.2603", nil)
+	assert.NoError(t, err)
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+}
+
+func TestGetDisasterZonesHandler_GeoJSON(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	handler := handlers.NewDisasterZoneHandler(&MockDisasterZoneService{})
+
+	router := gin.Default()
+	router.GET("/zones", handler.GetDisasterZones)
+
+	req, err := http.NewRequest(http.MethodGet, "/zones", nil)
+	assert.NoError(t, err)
+	req.Header.Set("Accept", "application/geo+json")
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "application/geo+json", recorder.Header().Get("Content-Type"))
+
+	var collection services.FeatureCollection
+	err = json.Unmarshal(recorder.Body.Bytes(), &collection)
+	assert.NoError(t, err)
+	assert.Len(t, collection.Features, 2)
+	assert.Equal(t, "Polygon", collection.Features[0].Geometry.Type)
+	assert.Equal(t, float64(2), collection.Features[1].Properties["incident_id"])
+}
+
+func TestGetDisasterZonesHandler_AsOf(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockService := &MockDisasterZoneService{}
+	handler := handlers.NewDisasterZoneHandler(mockService)
+
+	router := gin.Default()
+	router.GET("/zones", handler.GetDisasterZones)
+
+	req, err := http.NewRequest(http.MethodGet, "/zones?as_of=2025-04-11T10:30:00Z", nil)
+	assert.NoError(t, err)
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, time.Date(2025, 4, 11, 10, 30, 0, 0, time.UTC), *mockService.lastFilter.AsOf)
+
+	req, err = http.NewRequest(http.MethodGet, "/zones?as_of=yesterday", nil)
+	assert.NoError(t, err)
+
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+}
+
+func TestGetDisasterZoneHistoryHandler_Happy(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	handler := handlers.NewDisasterZoneHandler(&MockDisasterZoneService{})
+
+	router := gin.Default()
+	router.GET("/zones/:id/history", handler.GetDisasterZoneHistory)
+
+	req, err := http.NewRequest(http.MethodGet, "/zones/2/history", nil)
+	assert.NoError(t, err)
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+
+	var versions []models.DisasterZoneVersion
+	err = json.Unmarshal(recorder.Body.Bytes(), &versions)
+	assert.NoError(t, err)
+	assert.Len(t, versions, 2)
+	assert.Equal(t, 2, versions[0].IncidentID)
+	assert.NotNil(t, versions[0].ValidTo)
+	assert.Nil(t, versions[1].ValidTo)
+}
+
+func TestGetIncidentStatusesHandler_ConfiguredStatuses(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	mock.ExpectQuery(`SELECT status_id, status_name FROM incident_status ORDER BY status_id`).
+		WillReturnRows(sqlmock.NewRows([]string{"status_id", "status_name"}).
+			AddRow(1, "reported").
+			AddRow(2, "verified").
+			AddRow(3, "active").
+			AddRow(4, "resolved"))
+
+	service := services.NewDisasterZoneService(db)
+	service.DefaultActiveStatuses = []int{services.StatusActive, 7}
+	handler := handlers.NewDisasterZoneHandler(service)
+
+	router := gin.Default()
+	router.GET("/incident-statuses", handler.GetIncidentStatuses)
+
+	req, _ := http.NewRequest(http.MethodGet, "/incident-statuses", nil)
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+
+	var statuses []models.IncidentStatus
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &statuses))
+	assert.Len(t, statuses, 5)
+	assert.Equal(t, models.IncidentStatus{StatusID: 3, StatusName: "active", Active: true}, statuses[2])
+	assert.Equal(t, models.IncidentStatus{StatusID: 4, StatusName: "resolved", Active: false}, statuses[3])
+	assert.Equal(t, models.IncidentStatus{StatusID: 7, Active: true}, statuses[4])
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,163 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"disaster-response-map-api/internal/models"
+	"disaster-response-map-api/internal/services"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+var sizedZoneColumns = []string{"incident_id", "incident_name", "type_id", "severity_id", "status_id", "latitude", "longitude", "geometry", "source", "radius_m", "buffer_m", "avoidance"}
+
+func TestDisasterZoneService_HistoryKeepsRecordedSizes(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	service := services.NewDisasterZoneService(db)
+
+	// The zone did not change, but a rule edit between the two versions grew
+	// it from the 54 m default to 120 m with a buffer.
+	ruleEdited := time.Date(2025, 4, 11, 10, 0, 0, 0, time.UTC)
+	columns := append(append([]string{}, sizedZoneColumns...), "valid_from", "valid_to")
+	mock.ExpectQuery(`SELECT z.incident_id, .*, z.radius_m, z.buffer_m, z.avoidance, z.valid_from, z.valid_to FROM disaster_zone_history z WHERE z.incident_id = \$1`).
+		WithArgs(42).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(42, "Chemical Leak", 2, 3, 3, 53.35, -6.26, nil, "incident", 54.0, 0.0, 0.875, ruleEdited.Add(-time.Hour), ruleEdited).
+			AddRow(42, "Chemical Leak", 2, 3, 3, 53.35, -6.26, nil, "incident", 120.0, 40.0, 1.0, ruleEdited, nil))
+
+	versions, err := service.GetDisasterZoneHistory(42)
+	if !assert.NoError(t, err) || !assert.Len(t, versions, 2) {
+		return
+	}
+	assert.Equal(t, 54.0, versions[0].Radius)
+	assert.Equal(t, 0.875, versions[0].Avoidance)
+	assert.Equal(t, 120.0, versions[1].Radius)
+	assert.Equal(t, 40.0, versions[1].Buffer)
+
+	asOf := ruleEdited.Add(-time.Minute)
+	mock.ExpectQuery(`FROM disaster_zone_history z WHERE z.valid_from <= \$1`).
+		WithArgs(asOf).
+		WillReturnRows(sqlmock.NewRows(sizedZoneColumns).
+			AddRow(42, "Chemical Leak", 2, 3, 3, 53.35, -6.26, nil, "incident", 54.0, 0.0, 0.875))
+
+	zones, err := service.GetDisasterZones(services.DisasterZoneFilter{AsOf: &asOf})
+	if assert.NoError(t, err) && assert.Len(t, zones, 1) {
+		assert.Equal(t, 54.0, zones[0].Radius)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDisasterZoneService_LiveZonesTakeDatabaseSizes(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	service := services.NewDisasterZoneService(db)
+
+	// The zone rules are applied by zone_size() in the database, so the live
+	// zone is read with its size rather than sized again in Go.
+	mock.ExpectQuery(`SELECT z.incident_id, .*, z.radius_m, z.buffer_m, z.avoidance FROM sized_disaster_zone_view z WHERE z.incident_id = \$1`).
+		WithArgs(42).
+		WillReturnRows(sqlmock.NewRows(sizedZoneColumns).
+			AddRow(42, "Chemical Leak", 4, 3, 3, 53.35, -6.26, nil, "incident", 500.0, 100.0, 0.875))
+
+	zone, err := service.GetDisasterZone(42)
+	if assert.NoError(t, err) {
+		assert.Equal(t, 500.0, zone.Radius)
+		assert.Equal(t, 100.0, zone.Buffer)
+		assert.Equal(t, 0.875, zone.Avoidance)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDisasterZoneService_SpatialFilterUsesZoneExtent(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	service := services.NewDisasterZoneService(db)
+
+	// A zone whose centre is outside the box still matches when its disc
+	// reaches into it, so the filter is on the stored extent, not the centre.
+	mock.ExpectQuery(`FROM sized_disaster_zone_view z WHERE z.incident_id IN \(SELECT h.incident_id FROM disaster_zone_history h WHERE h.valid_to IS NULL AND ST_Intersects\(h.geog, ST_MakeEnvelope\(\$1, \$2, \$3, \$4, 4326\)::geography\) AND ST_DWithin\(h.geog, ST_SetSRID\(ST_MakePoint\(\$6, \$5\), 4326\)::geography, \$7\)\) AND z.type_id = \$8 ORDER BY z.incident_id`).
+		WithArgs(-6.3, 53.3, -6.2, 53.4, 53.35, -6.26, 500.0, 2).
+		WillReturnRows(sqlmock.NewRows(sizedZoneColumns).
+			AddRow(42, "Chemical Leak", 2, 3, 3, 53.41, -6.26, nil, "incident", 1200.0, 0.0, 1.0))
+
+	typeID := 2
+	zones, err := service.GetDisasterZones(services.DisasterZoneFilter{
+		SpatialFilter: services.SpatialFilter{
+			BBox:   &services.BoundingBox{MinLon: -6.3, MinLat: 53.3, MaxLon: -6.2, MaxLat: 53.4},
+			Near:   &[2]float64{53.35, -6.26},
+			Within: 500,
+		},
+		TypeID: &typeID,
+	})
+	if assert.NoError(t, err) {
+		assert.Len(t, zones, 1)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDisasterZoneService_StatusesFollowIncidentStatusTable(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	service := services.NewDisasterZoneService(db)
+	service.DefaultActiveStatuses = []int{20, 25}
+
+	// A renumbered table with a "contained" status between active and resolved.
+	expectStatuses := func() {
+		mock.ExpectQuery(`SELECT status_id, status_name FROM incident_status ORDER BY status_id`).
+			WillReturnRows(sqlmock.NewRows([]string{"status_id", "status_name"}).
+				AddRow(10, "reported").
+				AddRow(20, "active").
+				AddRow(25, "contained").
+				AddRow(30, "resolved"))
+	}
+	expectZone := func(statusID int) {
+		mock.ExpectQuery(`FROM sized_disaster_zone_view z WHERE z.incident_id = \$1`).
+			WithArgs(101).
+			WillReturnRows(sqlmock.NewRows(sizedZoneColumns).
+				AddRow(101, "Flooded underpass", 1, 2, statusID, 53.35, -6.26, nil, "manual", 36.0, 0.0, 0.75))
+	}
+
+	expectStatuses()
+	_, err = service.CreateDisasterZone(models.DisasterZoneCreate{ZoneName: "Gas leak", TypeID: 1, SeverityID: 2, StatusID: 3})
+	assert.ErrorIs(t, err, services.ErrInvalidDisasterZone)
+
+	expectStatuses()
+	_, err = service.CreateDisasterZone(models.DisasterZoneCreate{ZoneName: "Gas leak", TypeID: 1, SeverityID: 2, StatusID: 30})
+	assert.ErrorIs(t, err, services.ErrInvalidDisasterZone)
+
+	// Patching loads the zone, then replaces it, which loads it again.
+	expectZone(25)
+	expectZone(25)
+	expectStatuses()
+	active := 20
+	_, err = service.PatchDisasterZone(101, models.DisasterZonePatch{StatusID: &active})
+	assert.ErrorIs(t, err, services.ErrInvalidStatusTransition)
+
+	expectZone(25)
+	expectZone(25)
+	expectStatuses()
+	mock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM incident_type WHERE type_id = \$1\)`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectExec(`UPDATE manual_zone`).
+		WithArgs(101, "Flooded underpass", 1, 2, 30, 53.35, -6.26, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectZone(30)
+	resolved := 30
+	zone, err := service.PatchDisasterZone(101, models.DisasterZonePatch{StatusID: &resolved})
+	if assert.NoError(t, err) {
+		assert.Equal(t, 30, zone.StatusID)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -3,20 +3,73 @@ package tests
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
+	"disaster-response-map-api/config"
 	"disaster-response-map-api/internal/handlers"
+	"disaster-response-map-api/internal/models"
 	"disaster-response-map-api/internal/services"
+	"disaster-response-map-api/pkg/middleware"
 
 	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
 	"github.com/stretchr/testify/assert"
 )
 
-type MockEvacuationService struct{}
+type MockEvacuationService struct {
+	lastOptions services.EvacuationOptions
+	saved       *models.Evacuation
+	lastFilter  services.EvacuationFilter
+	lastBands   int
+}
+
+func (m *MockEvacuationService) SaveEvacuation(evacuation models.Evacuation) (models.Evacuation, error) {
+	evacuation.EvacuationID = 12
+	m.saved = &evacuation
+	return evacuation, nil
+}
+
+func (m *MockEvacuationService) GetEvacuation(evacuationID int) (models.Evacuation, error) {
+	if evacuationID != 12 {
+		return models.Evacuation{}, services.ErrEvacuationNotFound
+	}
+	return models.Evacuation{EvacuationID: 12, Status: services.EvacuationArrived}, nil
+}
+
+func (m *MockEvacuationService) GetEvacuations(filter services.EvacuationFilter) ([]models.Evacuation, error) {
+	m.lastFilter = filter
+	return []models.Evacuation{{EvacuationID: 12, Status: services.EvacuationArrived}}, nil
+}
+
+// SetEvacuationStatus treats evacuation 12 as arrived.
+func (m *MockEvacuationService) GetIsochrone(point [2]float64, minutes, bands int, mode services.TravelMode) (services.FeatureCollection, error) {
+	m.lastOptions.Mode, m.lastBands = mode, bands
+	if minutes > 60 {
+		return services.FeatureCollection{}, fmt.Errorf("%w: minutes must be between 1 and 60", services.ErrInvalidIsochrone)
+	}
+	return services.FeatureCollection{Type: "FeatureCollection", Features: []services.Feature{{
+		Type:       "Feature",
+		ID:         0,
+		Geometry:   services.GeoJSON{Type: "Polygon", Coordinates: [][][]float64{{{-6.27, 53.34}, {-6.25, 53.34}, {-6.25, 53.36}, {-6.27, 53.34}}}},
+		Properties: map[string]interface{}{"bucket": 0, "minutes": float64(minutes)},
+	}}}, nil
+}
 
-func (m *MockEvacuationService) GetEvacuationRoute(dangerPoint [2]float64, incidentTypeID int, safePoint *[2]float64) (services.EvacuationRouteResponse, error) {
+func (m *MockEvacuationService) SetEvacuationStatus(evacuationID int, status string) (models.Evacuation, error) {
+	if _, err := m.GetEvacuation(evacuationID); err != nil {
+		return models.Evacuation{}, err
+	}
+	if status != services.EvacuationArrived {
+		return models.Evacuation{}, fmt.Errorf("%w: arrived to %s", services.ErrInvalidStatusTransition, status)
+	}
+	return models.Evacuation{EvacuationID: 12, Status: status}, nil
+}
+
+func (m *MockEvacuationService) GetEvacuationRoute(dangerPoint [2]float64, incidentTypeID int, safePoint *[2]float64, opts services.EvacuationOptions) (services.EvacuationRouteResponse, error) {
+	m.lastOptions = opts
 	return services.EvacuationRouteResponse{
 		Hints: map[string]interface{}{"sample_hint": "value"},
 		Info:  map[string]interface{}{"took": 1},
@@ -56,6 +109,23 @@ func (m *MockEvacuationService) GetEvacuationRoute(dangerPoint [2]float64, incid
 	}, nil
 }
 
+func (m *MockEvacuationService) PlanEvacuation(origins []services.PlanOrigin, incidentTypeID int, opts services.EvacuationOptions) (services.EvacuationPlan, error) {
+	m.lastOptions = opts
+	if len(origins) == 0 {
+		return services.EvacuationPlan{}, fmt.Errorf("%w: origins are required", services.ErrInvalidEvacuationPlan)
+	}
+	plan := services.EvacuationPlan{Zones: []services.ZoneLoad{}}
+	for _, origin := range origins {
+		plan.Origins = append(plan.Origins, services.OriginPlan{
+			PlanOrigin:  origin,
+			Assignments: []services.PlanAssignment{{ZoneID: 8, People: origin.People, Time: 600000}},
+		})
+		plan.People += origin.People
+	}
+	plan.Assigned = plan.People
+	return plan, nil
+}
+
 func TestGetEvacuationRouteHandler_Happy(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	mockService := &MockEvacuationService{}
@@ -85,3 +155,141 @@ func TestGetEvacuationRouteHandler_Happy(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Greater(t, len(response.Paths), 0)
 }
+
+func TestGetEvacuationRouteHandler_Needs(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockService := &MockEvacuationService{}
+	handler := handlers.NewEvacuationHandler(mockService)
+
+	router := gin.Default()
+	router.POST("/evacuation", handler.GetEvacuationRoute)
+
+	body := `{"danger_point": [53.349805, -6.26031], "incident_type_id": 3, "needs": ["wheelchair", "Pets", "wheelchair"]}`
+	req, _ := http.NewRequest(http.MethodPost, "/evacuation", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, []services.Need{services.NeedWheelchair, services.NeedPets}, mockService.lastOptions.Needs)
+
+	assert.Equal(t, services.ModeFoot, mockService.lastOptions.Mode)
+
+	body = `{"danger_point": [53.349805, -6.26031], "incident_type_id": 3, "mode": "Bus"}`
+	req, _ = http.NewRequest(http.MethodPost, "/evacuation", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, services.ModeBus, mockService.lastOptions.Mode)
+
+	for _, body := range []string{
+		`{"danger_point": [53.349805, -6.26031], "incident_type_id": 3, "needs": ["jacuzzi"]}`,
+		`{"danger_point": [53.349805, -6.26031], "incident_type_id": 3, "mode": "hovercraft"}`,
+	} {
+		req, _ = http.NewRequest(http.MethodPost, "/evacuation", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		recorder = httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+		assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	}
+}
+
+func TestPlanEvacuationHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	handler := handlers.NewEvacuationHandler(&MockEvacuationService{})
+
+	router := gin.Default()
+	router.POST("/evacuation/plan", handler.PlanEvacuation)
+
+	body := `{"incident_type_id": 3, "origins": [{"id": "block-12", "point": [53.349805, -6.26031], "people": 40}]}`
+	req, _ := http.NewRequest(http.MethodPost, "/evacuation/plan", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+
+	var plan services.EvacuationPlan
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &plan))
+	if assert.Len(t, plan.Origins, 1) {
+		assert.Equal(t, "block-12", plan.Origins[0].ID)
+		assert.Equal(t, 40, plan.Origins[0].Assignments[0].People)
+	}
+
+	req, _ = http.NewRequest(http.MethodPost, "/evacuation/plan", bytes.NewBufferString(`{"incident_type_id": 3, "origins": []}`))
+	req.Header.Set("Content-Type", "application/json")
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+}
+
+func TestGetEvacuationRouteHandler_Save(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	config.JWT_SECRET = "test-secret"
+	mockService := &MockEvacuationService{}
+	handler := handlers.NewEvacuationHandler(mockService)
+
+	router := gin.Default()
+	router.POST("/evacuation", middleware.OptionalAuthMiddleware(), handler.GetEvacuationRoute)
+
+	// requested_by in the body is ignored; the token's subject is kept.
+	body := `{"danger_point": [53.349805, -6.26031], "incident_type_id": 3, "safe_point": [53.344, -6.267], "save": true, "incident_id": 42, "requested_by": "someone-else"}`
+	req, _ := http.NewRequest(http.MethodPost, "/evacuation", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+	assert.Nil(t, mockService.saved)
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"sub": "dispatch-3"}).SignedString([]byte(config.JWT_SECRET))
+	assert.NoError(t, err)
+	req, _ = http.NewRequest(http.MethodPost, "/evacuation", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+
+	var route services.EvacuationRouteResponse
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &route))
+	if assert.NotNil(t, route.EvacuationID) && assert.NotNil(t, mockService.saved) {
+		assert.Equal(t, 12, *route.EvacuationID)
+		assert.Equal(t, 42, *mockService.saved.IncidentID)
+		assert.Equal(t, "dispatch-3", *mockService.saved.RequestedBy)
+		assert.Equal(t, [2]float64{53.344, -6.267}, mockService.saved.Destination)
+	}
+}
+
+func TestEvacuationRecordHandlers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockService := &MockEvacuationService{}
+	handler := handlers.NewEvacuationHandler(mockService)
+
+	router := gin.Default()
+	router.GET("/evacuations", handler.GetEvacuations)
+	router.GET("/evacuations/:id", handler.GetEvacuation)
+	router.PATCH("/evacuations/:id", handler.UpdateEvacuationStatus)
+
+	tests := []struct {
+		method, path, body string
+		status             int
+	}{
+		{http.MethodGet, "/evacuations?incident_id=42&zone_id=8&status=arrived", "", http.StatusOK},
+		{http.MethodGet, "/evacuations?zone_id=hall", "", http.StatusBadRequest},
+		{http.MethodGet, "/evacuations/12", "", http.StatusOK},
+		{http.MethodGet, "/evacuations/13", "", http.StatusNotFound},
+		{http.MethodGet, "/evacuations/abc", "", http.StatusBadRequest},
+		{http.MethodPatch, "/evacuations/12", `{"status": "arrived"}`, http.StatusOK},
+		{http.MethodPatch, "/evacuations/12", `{"status": "cancelled"}`, http.StatusConflict},
+		{http.MethodPatch, "/evacuations/13", `{"status": "arrived"}`, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest(tt.method, tt.path, bytes.NewBufferString(tt.body))
+		req.Header.Set("Content-Type", "application/json")
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+		assert.Equal(t, tt.status, recorder.Code, "%s %s", tt.method, tt.path)
+	}
+	assert.Equal(t, 42, *mockService.lastFilter.IncidentID)
+	assert.Equal(t, 8, *mockService.lastFilter.ZoneID)
+	assert.Equal(t, "arrived", *mockService.lastFilter.Status)
+}
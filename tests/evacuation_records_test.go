@@ -0,0 +1,165 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"disaster-response-map-api/internal/models"
+	"disaster-response-map-api/internal/services"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+var evacuationColumns = []string{"evacuation_id", "incident_id", "incident_type_id", "origin_lat", "origin_lon", "dest_lat", "dest_lon",
+	"zone_id", "safe_zone", "mode", "distance", "travel_time", "route", "conditions", "requested_by", "status", "created_at", "updated_at"}
+
+func TestNewEvacuation_FromChosenSafeZone(t *testing.T) {
+	capacity := 200
+	route := services.EvacuationRouteResponse{
+		Paths: []services.RoutePath{{
+			Distance: 1060.8,
+			Time:     780435,
+			Points:   services.GeoJSON{Type: "LineString", Coordinates: [][]float64{{-6.26031, 53.349805}, {-6.267, 53.344}}},
+		}},
+		SafeZone:       &models.SafeZone{ZoneID: 8, ZoneName: "Community Hall", ZoneLat: 53.344, ZoneLon: -6.267, Capacity: &capacity},
+		Mode:           services.ModeWheelchair,
+		StartZones:     []int{42, 17},
+		HazardDistance: 48.2,
+		HazardZones:    []services.HazardExposure{{IncidentID: 42, Distance: 48.2}},
+		ActiveZones: []models.DisasterZone{
+			{IncidentID: 17, Radius: 54, Avoidance: 0.875},
+			{IncidentID: 42, Radius: 120, Buffer: 40, Avoidance: 0.8},
+			{IncidentID: 60, Radius: 36, Avoidance: 0.75},
+		},
+	}
+
+	evacuation := services.NewEvacuation([2]float64{53.349805, -6.26031}, nil, 3, route)
+	assert.Equal(t, 42, *evacuation.IncidentID)
+	assert.Equal(t, 8, *evacuation.ZoneID)
+	assert.Equal(t, [2]float64{53.344, -6.267}, evacuation.Destination)
+	assert.Equal(t, "Community Hall", evacuation.SafeZone.ZoneName)
+	assert.Equal(t, "wheelchair", evacuation.Mode)
+	assert.Equal(t, 780435, evacuation.Time)
+	assert.JSONEq(t, `{"type": "LineString", "coordinates": [[-6.26031, 53.349805], [-6.267, 53.344]]}`, string(evacuation.Route))
+	assert.Equal(t, services.EvacuationIssued, evacuation.Status)
+	// The zones the route was planned around are kept with it.
+	if assert.NotNil(t, evacuation.Conditions) {
+		assert.Equal(t, []int{42, 17}, evacuation.Conditions.StartZones)
+		assert.Equal(t, 48.2, evacuation.Conditions.HazardDistance)
+		assert.Equal(t, route.HazardZones, evacuation.Conditions.HazardZones)
+		assert.Len(t, evacuation.Conditions.ActiveZones, 3)
+		assert.Equal(t, 40.0, evacuation.Conditions.ActiveZones[1].Buffer)
+	}
+}
+
+func TestEvacuationService_SaveEvacuation(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	incidentID, zoneID, requestedBy := 42, 8, "dispatch-3"
+	now := time.Date(2025, 4, 11, 10, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(`INSERT INTO evacuation`).
+		WithArgs(42, 3, 53.349805, -6.26031, 53.344, -6.267, 8, sqlmock.AnyArg(), "foot", 1060.8, 780435, `{"type":"LineString"}`, sqlmock.AnyArg(), "dispatch-3").
+		WillReturnRows(sqlmock.NewRows(evacuationColumns).
+			AddRow(12, 42, 3, 53.349805, -6.26031, 53.344, -6.267, 8, []byte(`{"zone_id": 8, "zone_name": "Community Hall"}`), "foot", 1060.8, 780435, []byte(`{"type":"LineString"}`), []byte(`{"start_zones": [42], "hazard_distance": 48.2, "hazard_zones": [{"incident_id": 42, "distance": 48.2}], "active_zones": [{"incident_id": 42, "radius": 120}]}`), "dispatch-3", "issued", now, now))
+
+	service := services.NewEvacuationService(db, &MockGraphHopperService{}, &MockDisasterZoneService{})
+	saved, err := service.SaveEvacuation(models.Evacuation{
+		IncidentID:     &incidentID,
+		IncidentTypeID: 3,
+		Origin:         [2]float64{53.349805, -6.26031},
+		Destination:    [2]float64{53.344, -6.267},
+		ZoneID:         &zoneID,
+		SafeZone:       &models.SafeZone{ZoneID: 8},
+		Mode:           "foot",
+		Distance:       1060.8,
+		Time:           780435,
+		Route:          []byte(`{"type":"LineString"}`),
+		RequestedBy:    &requestedBy,
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, 12, saved.EvacuationID)
+	assert.Equal(t, "Community Hall", saved.SafeZone.ZoneName)
+	assert.Equal(t, "dispatch-3", *saved.RequestedBy)
+	if assert.NotNil(t, saved.Conditions) {
+		assert.Equal(t, []int{42}, saved.Conditions.StartZones)
+		assert.Equal(t, 120.0, saved.Conditions.ActiveZones[0].Radius)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestEvacuationService_GetEvacuationsFiltered(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	now := time.Date(2025, 4, 11, 10, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(`FROM evacuation WHERE incident_id = \$1 AND zone_id = \$2 ORDER BY created_at DESC`).
+		WithArgs(42, 8).
+		WillReturnRows(sqlmock.NewRows(evacuationColumns).
+			AddRow(12, 42, 3, 53.349805, -6.26031, 53.344, -6.267, 8, nil, "foot", 1060.8, 780435, nil, nil, nil, "in_progress", now, now))
+
+	service := services.NewEvacuationService(db, &MockGraphHopperService{}, &MockDisasterZoneService{})
+	incidentID, zoneID := 42, 8
+	evacuations, err := service.GetEvacuations(services.EvacuationFilter{IncidentID: &incidentID, ZoneID: &zoneID})
+	if !assert.NoError(t, err) {
+		return
+	}
+	if assert.Len(t, evacuations, 1) {
+		assert.Nil(t, evacuations[0].SafeZone)
+		assert.Nil(t, evacuations[0].RequestedBy)
+		assert.Nil(t, evacuations[0].Conditions)
+		assert.Equal(t, "in_progress", evacuations[0].Status)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	status := "lost"
+	_, err = service.GetEvacuations(services.EvacuationFilter{Status: &status})
+	assert.ErrorIs(t, err, services.ErrInvalidEvacuationStatus)
+}
+
+func TestEvacuationService_SetEvacuationStatus(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	service := services.NewEvacuationService(db, &MockGraphHopperService{}, &MockDisasterZoneService{})
+
+	now := time.Date(2025, 4, 11, 10, 0, 0, 0, time.UTC)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT status FROM evacuation WHERE evacuation_id = \$1 FOR UPDATE`).
+		WithArgs(12).
+		WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow("issued"))
+	mock.ExpectQuery(`UPDATE evacuation SET status = \$2, updated_at = now\(\) WHERE evacuation_id = \$1`).
+		WithArgs(12, "in_progress").
+		WillReturnRows(sqlmock.NewRows(evacuationColumns).
+			AddRow(12, 42, 3, 53.349805, -6.26031, 53.344, -6.267, 8, nil, "foot", 1060.8, 780435, nil, nil, nil, "in_progress", now, now))
+	mock.ExpectCommit()
+	evacuation, err := service.SetEvacuationStatus(12, services.EvacuationInProgress)
+	assert.NoError(t, err)
+	assert.Equal(t, "in_progress", evacuation.Status)
+
+	// Arrived evacuations are final.
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT status FROM evacuation`).
+		WithArgs(12).
+		WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow("arrived"))
+	mock.ExpectRollback()
+	_, err = service.SetEvacuationStatus(12, services.EvacuationCancelled)
+	assert.ErrorIs(t, err, services.ErrInvalidStatusTransition)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT status FROM evacuation`).
+		WithArgs(99).
+		WillReturnRows(sqlmock.NewRows([]string{"status"}))
+	mock.ExpectRollback()
+	_, err = service.SetEvacuationStatus(99, services.EvacuationArrived)
+	assert.ErrorIs(t, err, services.ErrEvacuationNotFound)
+
+	_, err = service.SetEvacuationStatus(12, "teleported")
+	assert.ErrorIs(t, err, services.ErrInvalidEvacuationStatus)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,307 @@
+package tests
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+
+	"disaster-response-map-api/internal/models"
+	"disaster-response-map-api/internal/services"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// MockEvacuationGraphHopper routes to each safe point in the time given for
+// it, in minutes, and fails for points it has no time for. Routes run in a
+// straight line, and the areas they were asked to avoid are recorded.
+type MockEvacuationGraphHopper struct {
+	MockGraphHopperService
+	minutes map[[2]float64]int
+	mu      sync.Mutex
+	areas   []services.ZoneArea
+	mode    services.TravelMode
+	// matrix holds the minutes from each origin to each safe zone for
+	// GetTravelMatrix; negative entries are unreachable. matrixFrom records
+	// the origins of each GetTravelMatrix call.
+	matrix     map[[2]float64][]int
+	matrixFrom [][][2]float64
+}
+
+func (m *MockEvacuationGraphHopper) GetTravelMatrix(from, to [][2]float64, mode services.TravelMode, areas []services.ZoneArea) (services.TravelMatrix, error) {
+	m.matrixFrom = append(m.matrixFrom, from)
+	matrix := services.TravelMatrix{Times: make([][]*float64, len(from)), Distances: make([][]*float64, len(from))}
+	for i := range from {
+		matrix.Times[i] = make([]*float64, len(to))
+		matrix.Distances[i] = make([]*float64, len(to))
+		for j := range to {
+			if minutes := m.matrix[from[i]][j]; minutes >= 0 {
+				seconds, metres := float64(minutes*60), float64(minutes*80)
+				matrix.Times[i][j], matrix.Distances[i][j] = &seconds, &metres
+			}
+		}
+	}
+	return matrix, nil
+}
+
+func (m *MockEvacuationGraphHopper) GetEvacuationRoute(dangerPoint, safePoint [2]float64, mode services.TravelMode, areas []services.ZoneArea) (services.EvacuationRouteResponse, error) {
+	m.mu.Lock()
+	m.areas, m.mode = areas, mode
+	m.mu.Unlock()
+	minutes, ok := m.minutes[safePoint]
+	if m.minutes != nil && !ok {
+		return services.EvacuationRouteResponse{}, errors.New("GraphHopper API error: 400 Bad Request - Connection between locations not found")
+	}
+	if !ok {
+		minutes = 10
+	}
+	return services.EvacuationRouteResponse{
+		Paths: []services.RoutePath{{
+			Distance: float64(minutes * 80),
+			Time:     minutes * 60000,
+			Points: services.GeoJSON{Type: "LineString", Coordinates: [][]float64{
+				{dangerPoint[1], dangerPoint[0]},
+				{safePoint[1], safePoint[0]},
+			}},
+		}},
+	}, nil
+}
+
+func (m *MockEvacuationGraphHopper) GetIsochrone(point [2]float64, timeLimit, buckets int, mode services.TravelMode) ([]services.Feature, error) {
+	m.mu.Lock()
+	m.mode = mode
+	m.mu.Unlock()
+	return m.MockGraphHopperService.GetIsochrone(point, timeLimit, buckets, mode)
+}
+
+// MockEvacuationZones serves the given zones as the active disaster zones.
+type MockEvacuationZones struct {
+	MockDisasterZoneService
+	zones []models.DisasterZone
+}
+
+func (m *MockEvacuationZones) GetActiveDisasterZones(statuses []int) ([]models.DisasterZone, error) {
+	return m.zones, nil
+}
+
+// hazardIncidents matches a zoneAreasJSON argument holding exactly the areas
+// of these incidents, in order.
+type hazardIncidents []int
+
+func (h hazardIncidents) Match(v driver.Value) bool {
+	var areas []struct {
+		IncidentID int `json:"incident_id"`
+	}
+	if err := json.Unmarshal([]byte(v.(string)), &areas); err != nil || len(areas) != len(h) {
+		return false
+	}
+	for i, area := range areas {
+		if area.IncidentID != h[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestEvacuationService_NearestSafeZoneUsesKNN(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	rows := sqlmock.NewRows([]string{"zone_id", "zone_name", "zone_lat", "zone_lon", "incident_type_id", "capacity", "occupancy", "is_open", "active", "compromised_by", "compromised_at", "medical_staff", "wheelchair_access", "pets_allowed", "water", "power", "beds", "external_ref", "incident_type_ids"}).
+		AddRow(8, "Community Hall", 53.36, -6.27, 2, 100, 40, true, true, nil, nil, false, false, false, false, false, 0, nil, "{2}")
+	mock.ExpectQuery(`WHERE EXISTS \(SELECT 1 FROM safe_zone_incident_type t WHERE t.zone_id = safe_zone.zone_id AND t.incident_type_id = \$1\)\s+AND active\s+AND is_open\s+AND \(capacity IS NULL OR occupancy < capacity\)\s+AND compromised_by IS NULL\s+AND NOT EXISTS \(SELECT 1 FROM hazard h WHERE ST_Intersects\(h.geog, safe_zone.geog\)\)\s+ORDER BY geog <-> ST_SetSRID\(ST_MakePoint\(\$3, \$2\), 4326\)::geography\s+LIMIT \$5`).
+		// Every active zone is checked live, including the one the danger
+		// point lies in, since the stored flag may not have caught up yet.
+		WithArgs(2, 53.349805, -6.26031, hazardIncidents{1, 2}, 5).
+		WillReturnRows(rows)
+
+	service := services.NewEvacuationService(db, &MockEvacuationGraphHopper{}, &MockDisasterZoneService{})
+	route, err := service.GetEvacuationRoute([2]float64{53.349805, -6.26031}, 2, nil, services.EvacuationOptions{})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, 8, route.SafeZone.ZoneID)
+	assert.Equal(t, 60, *route.SafeZone.RemainingCapacity)
+	assert.Equal(t, []int{2}, route.SafeZone.IncidentTypeIDs)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestEvacuationService_NearestSafeZoneMeetsNeeds(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectQuery(`ST_Intersects\(h.geog, safe_zone.geog\)\) AND wheelchair_access AND beds > 0\s+ORDER BY`).
+		WithArgs(2, 53.349805, -6.26031, sqlmock.AnyArg(), 5).
+		WillReturnRows(sqlmock.NewRows([]string{"zone_id"}))
+
+	service := services.NewEvacuationService(db, &MockGraphHopperService{}, &MockDisasterZoneService{})
+	_, err = service.GetEvacuationRoute([2]float64{53.349805, -6.26031}, 2, nil, services.EvacuationOptions{
+		Needs: []services.Need{services.NeedWheelchair, services.NeedBed},
+	})
+	assert.ErrorIs(t, err, services.ErrNoSafeZoneAvailable)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestEvacuationService_PicksFastestRoute(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	columns := []string{"zone_id", "zone_name", "zone_lat", "zone_lon", "incident_type_id", "capacity", "occupancy", "is_open", "active", "compromised_by", "compromised_at", "medical_staff", "wheelchair_access", "pets_allowed", "water", "power", "beds", "external_ref", "incident_type_ids"}
+	mock.ExpectQuery(`LIMIT \$5`).
+		WithArgs(2, 53.349805, -6.26031, sqlmock.AnyArg(), 3).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(1, "Across the River", 53.351, -6.261, 2, nil, 0, true, true, nil, nil, false, false, false, false, false, 0, nil, "{2}").
+			AddRow(2, "Community Hall", 53.345, -6.265, 2, nil, 0, true, true, nil, nil, false, false, false, false, false, 0, nil, "{2}").
+			AddRow(3, "Sports Hall", 53.340, -6.270, 2, nil, 0, true, true, nil, nil, false, false, false, false, false, 0, nil, "{2}"))
+
+	gh := &MockEvacuationGraphHopper{minutes: map[[2]float64]int{
+		{53.351, -6.261}: 45,
+		{53.345, -6.265}: 12,
+	}}
+	service := services.NewEvacuationService(db, gh, &MockDisasterZoneService{})
+	service.Candidates = 3
+	route, err := service.GetEvacuationRoute([2]float64{53.349805, -6.26031}, 2, nil, services.EvacuationOptions{})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, 2, route.SafeZone.ZoneID)
+	assert.Equal(t, 12*60000, route.Paths[0].Time)
+	if assert.Len(t, route.Alternatives, 3) {
+		assert.Equal(t, 2, route.Alternatives[0].SafeZone.ZoneID)
+		assert.Equal(t, 1, route.Alternatives[1].SafeZone.ZoneID)
+		assert.Equal(t, 45*60000, route.Alternatives[1].Time)
+		assert.Equal(t, 3, route.Alternatives[2].SafeZone.ZoneID)
+		assert.False(t, route.Alternatives[2].Reachable)
+		assert.Contains(t, route.Alternatives[2].Error, "Connection between locations not found")
+		assert.Greater(t, route.Alternatives[0].StraightLineDistance, route.Alternatives[1].StraightLineDistance)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestEvacuationService_LeavesStartZoneAndAvoidsOthers(t *testing.T) {
+	gh := &MockEvacuationGraphHopper{}
+	dz := &MockEvacuationZones{zones: []models.DisasterZone{
+		{IncidentID: 1, Latitude: 53.349805, Longitude: -6.26031, Radius: 100, Avoidance: 1},
+		{IncidentID: 17, Latitude: 53.3478, Longitude: -6.2597, Radius: 25, Avoidance: 0.5},
+	}}
+	service := services.NewEvacuationService(nil, gh, dz)
+
+	route, err := service.GetEvacuationRoute([2]float64{53.349805, -6.26031}, 2, &[2]float64{53.3478, -6.2597}, services.EvacuationOptions{})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, []int{1}, route.StartZones)
+	if assert.NotEmpty(t, gh.areas) {
+		// A low-severity zone is still impassable on an evacuation route.
+		for _, area := range gh.areas {
+			assert.Equal(t, []int{17}, area.IncidentIDs)
+			assert.Equal(t, 0.0, area.Priority)
+		}
+	}
+
+	assert.InDelta(t, 125, route.HazardDistance, 10)
+	if assert.Len(t, route.HazardZones, 2) {
+		assert.Equal(t, 1, route.HazardZones[0].IncidentID)
+		assert.InDelta(t, 100, route.HazardZones[0].Distance, 10)
+		assert.Equal(t, 17, route.HazardZones[1].IncidentID)
+		assert.InDelta(t, 25, route.HazardZones[1].Distance, 10)
+	}
+}
+
+func TestEvacuationService_PlanRespectsCapacity(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	columns := []string{"zone_id", "zone_name", "zone_lat", "zone_lon", "incident_type_id", "capacity", "occupancy", "is_open", "active", "compromised_by", "compromised_at", "medical_staff", "wheelchair_access", "pets_allowed", "water", "power", "beds", "external_ref", "incident_type_ids"}
+	mock.ExpectQuery(`LIMIT \$5`).
+		WithArgs(2, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), 25).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(1, "Community Hall", 53.345, -6.265, 2, 60, 10, true, true, nil, nil, false, false, false, false, false, 0, nil, "{2}").
+			AddRow(2, "Stadium", 53.340, -6.270, 2, nil, 0, true, true, nil, nil, false, false, false, false, false, 0, nil, "{2}").
+			AddRow(3, "Library", 53.360, -6.250, 2, 100, 0, true, true, nil, nil, false, false, false, false, false, 0, nil, "{2}"))
+
+	// Sending the first origin to the hall first would leave the second a
+	// 20 minute walk; the cheapest plan fills the hall with the second origin.
+	gh := &MockEvacuationGraphHopper{matrix: map[[2]float64][]int{
+		{53.35, -6.26}:   {5, 10, -1},
+		{53.351, -6.261}: {6, 20, -1},
+		{53.36, -6.30}:   {-1, -1, -1},
+	}}
+	service := services.NewEvacuationService(db, gh, &MockDisasterZoneService{})
+	plan, err := service.PlanEvacuation([]services.PlanOrigin{
+		{ID: "a", Point: [2]float64{53.35, -6.26}, People: 30},
+		{Point: [2]float64{53.351, -6.261}, People: 40},
+		{ID: "cut-off", Point: [2]float64{53.36, -6.30}, People: 5},
+	}, 2, services.EvacuationOptions{})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// Origin a starts inside zone 1, so its routes may cross it and its
+	// travel times are asked for separately from the other origins'.
+	assert.Equal(t, []int{1}, plan.Origins[0].StartZones)
+	assert.Empty(t, plan.Origins[1].StartZones)
+	assert.Equal(t, [][][2]float64{{{53.35, -6.26}}, {{53.351, -6.261}, {53.36, -6.30}}}, gh.matrixFrom)
+
+	assert.Equal(t, 75, plan.People)
+	assert.Equal(t, 70, plan.Assigned)
+	assert.Equal(t, 5, plan.Unassigned)
+	assert.Equal(t, int64((10*5+20*10+40*6)*60000), plan.TotalTime)
+
+	assert.Equal(t, "2", plan.Origins[1].ID)
+	assert.Equal(t, []services.PlanAssignment{{ZoneID: 1, People: 40, Distance: 480, Time: 360000}}, withoutRoutes(plan.Origins[1].Assignments))
+	assert.Equal(t, []services.PlanAssignment{
+		{ZoneID: 1, People: 10, Distance: 400, Time: 300000},
+		{ZoneID: 2, People: 20, Distance: 800, Time: 600000},
+	}, withoutRoutes(plan.Origins[0].Assignments))
+	assert.Empty(t, plan.Origins[2].Assignments)
+	assert.Equal(t, 5, plan.Origins[2].Unassigned)
+	assert.NotNil(t, plan.Origins[0].Assignments[0].Route)
+
+	if assert.Len(t, plan.Zones, 2) {
+		assert.Equal(t, 50, plan.Zones[0].Assigned)
+		assert.Equal(t, 0, *plan.Zones[0].RemainingCapacity)
+		assert.Equal(t, 20, plan.Zones[1].Assigned)
+		assert.Nil(t, plan.Zones[1].RemainingCapacity)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// withoutRoutes drops the routes, and the hazard distance measured along
+// them, from plan assignments for comparison.
+func withoutRoutes(assignments []services.PlanAssignment) []services.PlanAssignment {
+	out := make([]services.PlanAssignment, len(assignments))
+	for i, assignment := range assignments {
+		assignment.Route, assignment.HazardDistance = nil, 0
+		out[i] = assignment
+	}
+	return out
+}
+
+func TestEvacuationService_WheelchairModeNeedsAccessibleZone(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	columns := []string{"zone_id", "zone_name", "zone_lat", "zone_lon", "incident_type_id", "capacity", "occupancy", "is_open", "active", "compromised_by", "compromised_at", "medical_staff", "wheelchair_access", "pets_allowed", "water", "power", "beds", "external_ref", "incident_type_ids"}
+	mock.ExpectQuery(`ST_Intersects\(h.geog, safe_zone.geog\)\) AND wheelchair_access\s+ORDER BY`).
+		WithArgs(2, 53.349805, -6.26031, sqlmock.AnyArg(), 5).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(8, "Community Hall", 53.345, -6.265, 2, nil, 0, true, true, nil, nil, false, true, false, false, false, 0, nil, "{2}"))
+
+	gh := &MockEvacuationGraphHopper{}
+	service := services.NewEvacuationService(db, gh, &MockDisasterZoneService{})
+	route, err := service.GetEvacuationRoute([2]float64{53.349805, -6.26031}, 2, nil, services.EvacuationOptions{Mode: services.ModeWheelchair})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, services.ModeWheelchair, route.Mode)
+	assert.Equal(t, services.ModeWheelchair, gh.mode)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,110 @@
+package tests
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"testing"
+
+	"disaster-response-map-api/internal/models"
+	"disaster-response-map-api/internal/services"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFeatureCollectionToGPX_RouteTrackAndInstructionWaypoints(t *testing.T) {
+	paths := []services.RoutePath{
+		{
+			Distance: 800,
+			Time:     900,
+			Points: services.GeoJSON{
+				Type:        "LineString",
+				Coordinates: []interface{}{[]interface{}{-6.26031, 53.349805}, []interface{}{-6.2597, 53.3478}},
+			},
+			Instructions: []services.Instruction{
+				{Text: "Continue onto Dame Street", Interval: []int{0, 1}, StreetName: "Dame Street"},
+				{Text: "Arrive at destination", Interval: []int{1, 1}},
+			},
+		},
+	}
+
+	body, err := services.FeatureCollectionToGPX(services.RouteToFeatureCollection(paths), "route")
+	assert.NoError(t, err)
+
+	var gpx struct {
+		Waypoints []struct {
+			Lat  float64 `xml:"lat,attr"`
+			Name string  `xml:"name"`
+		} `xml:"wpt"`
+		Tracks []struct {
+			Points []struct {
+				Lon float64 `xml:"lon,attr"`
+			} `xml:"trkseg>trkpt"`
+		} `xml:"trk"`
+	}
+	assert.NoError(t, xml.Unmarshal(body, &gpx))
+	assert.Len(t, gpx.Tracks, 1)
+	assert.Len(t, gpx.Tracks[0].Points, 2)
+	assert.Len(t, gpx.Waypoints, 2)
+	assert.Equal(t, "Continue onto Dame Street", gpx.Waypoints[0].Name)
+	assert.Equal(t, 53.3478, gpx.Waypoints[1].Lat)
+}
+
+func TestFeatureCollectionToKML_PolygonWithHole(t *testing.T) {
+	geometry := models.ZoneGeometry{
+		Type: "Polygon",
+		Coordinates: json.RawMessage(`[
+			[[-6.27,53.34],[-6.25,53.34],[-6.25,53.36],[-6.27,53.36],[-6.27,53.34]],
+			[[-6.265,53.345],[-6.255,53.345],[-6.255,53.355],[-6.265,53.345]]
+		]`),
+	}
+	zones := []models.DisasterZone{{IncidentID: 7, IncidentName: "River flood", Geometry: &geometry}}
+
+	body, err := services.FeatureCollectionToKML(services.DisasterZonesToFeatureCollection(zones), "zones")
+	assert.NoError(t, err)
+
+	var kml struct {
+		Placemarks []struct {
+			Name  string   `xml:"name"`
+			Outer string   `xml:"Polygon>outerBoundaryIs>LinearRing>coordinates"`
+			Inner []string `xml:"Polygon>innerBoundaryIs>LinearRing>coordinates"`
+		} `xml:"Document>Placemark"`
+	}
+	assert.NoError(t, xml.Unmarshal(body, &kml))
+	assert.Len(t, kml.Placemarks, 1)
+	assert.Equal(t, "River flood", kml.Placemarks[0].Name)
+	assert.Contains(t, kml.Placemarks[0].Outer, "-6.27,53.34")
+	assert.Len(t, kml.Placemarks[0].Inner, 1)
+}
+
+func TestDisasterZonesToFeatureCollection_BufferRing(t *testing.T) {
+	zone := models.DisasterZone{IncidentID: 9, IncidentName: "Gas leak", Latitude: 53.3498, Longitude: -6.2603, Radius: 50, Buffer: 30}
+	collection := services.DisasterZonesToFeatureCollection([]models.DisasterZone{zone})
+	if !assert.Len(t, collection.Features, 2) {
+		return
+	}
+	assert.Equal(t, "zone", collection.Features[0].Properties["part"])
+	ring := collection.Features[1]
+	assert.Equal(t, "buffer", ring.Properties["part"])
+	assert.Equal(t, "9-buffer", ring.ID)
+
+	// The ring reaches as far as the zone is avoided, with the zone cut out.
+	polygon := ring.Geometry.Coordinates.([][][]float64)
+	if assert.Len(t, polygon, 2) {
+		outer, hole := polygon[0][0], polygon[1][0]
+		assert.InDelta(t, 80, services.HaversineDistance(zone.Latitude, zone.Longitude, outer[1], outer[0]), 0.5)
+		assert.InDelta(t, 50, services.HaversineDistance(zone.Latitude, zone.Longitude, hole[1], hole[0]), 0.5)
+		assert.True(t, services.ZoneContainsPoint(zone, outer[1]+(zone.Latitude-outer[1])*0.01, outer[0]+(zone.Longitude-outer[0])*0.01))
+	}
+
+	body, err := services.FeatureCollectionToKML(collection, "zones")
+	assert.NoError(t, err)
+	var kml struct {
+		Placemarks []struct {
+			Inner []string `xml:"Polygon>innerBoundaryIs>LinearRing>coordinates"`
+		} `xml:"Document>Placemark"`
+	}
+	assert.NoError(t, xml.Unmarshal(body, &kml))
+	if assert.Len(t, kml.Placemarks, 2) {
+		assert.Len(t, kml.Placemarks[1].Inner, 1)
+	}
+}
@@ -0,0 +1,61 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"disaster-response-map-api/internal/services"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// captureGraphHopper serves an empty GraphHopper response and records the
+// path and JSON body of the last request.
+func captureGraphHopper(t *testing.T, path *string, body *map[string]interface{}) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*path = r.URL.Path
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(body))
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"paths": [], "times": [[60]], "distances": [[80]]}`))
+	}))
+}
+
+func TestGraphHopperService_WheelchairEvacuationRoute(t *testing.T) {
+	var path string
+	var body map[string]interface{}
+	server := captureGraphHopper(t, &path, &body)
+	defer server.Close()
+
+	gh := services.NewGraphHopperService("key", server.URL+"/route")
+	_, err := gh.GetEvacuationRoute([2]float64{53.3498, -6.2603}, [2]float64{53.344, -6.267}, services.ModeWheelchair, nil)
+	assert.NoError(t, err)
+
+	assert.Equal(t, "/route", path)
+	assert.Equal(t, "foot", body["profile"])
+	assert.Equal(t, true, body["ch.disable"])
+	model := body["custom_model"].(map[string]interface{})
+	assert.Contains(t, model["priority"], map[string]interface{}{"if": "road_class == STEPS", "multiply_by": 0.0})
+	assert.NotEmpty(t, model["speed"])
+}
+
+func TestGraphHopperService_FootRouteKeepsSpeedMode(t *testing.T) {
+	var path string
+	var body map[string]interface{}
+	server := captureGraphHopper(t, &path, &body)
+	defer server.Close()
+
+	gh := services.NewGraphHopperService("key", server.URL+"/route")
+	_, err := gh.GetEvacuationRoute([2]float64{53.3498, -6.2603}, [2]float64{53.344, -6.267}, services.ModeFoot, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "foot", body["profile"])
+	assert.NotContains(t, body, "custom_model")
+	assert.NotContains(t, body, "ch.disable")
+
+	_, err = gh.GetTravelMatrix([][2]float64{{53.3498, -6.2603}}, [][2]float64{{53.344, -6.267}}, services.ModeBus, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "/matrix", path)
+	assert.Equal(t, "car", body["profile"])
+	assert.Contains(t, body, "custom_model")
+}
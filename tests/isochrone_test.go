@@ -0,0 +1,137 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"disaster-response-map-api/internal/handlers"
+	"disaster-response-map-api/internal/models"
+	"disaster-response-map-api/internal/services"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGraphHopperService_IsochroneIsAGetRequest(t *testing.T) {
+	var request *http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		request = r
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"polygons": [{"type": "Feature", "geometry": {"type": "Polygon", "coordinates": []}, "properties": {"bucket": 0}}]}`))
+	}))
+	defer server.Close()
+
+	gh := services.NewGraphHopperService("key", server.URL+"/route")
+	polygons, err := gh.GetIsochrone([2]float64{53.3498, -6.2603}, 900, 3, services.ModeWheelchair)
+	assert.NoError(t, err)
+	assert.Len(t, polygons, 1)
+
+	if assert.NotNil(t, request) {
+		assert.Equal(t, http.MethodGet, request.Method)
+		assert.Equal(t, "/isochrone", request.URL.Path)
+		query := request.URL.Query()
+		assert.Equal(t, "key", query.Get("key"))
+		assert.Equal(t, "53.349800,-6.260300", query.Get("point"))
+		assert.Equal(t, "900", query.Get("time_limit"))
+		assert.Equal(t, "3", query.Get("buckets"))
+		// Wheelchairs fall back to the plain foot profile.
+		assert.Equal(t, "foot", query.Get("profile"))
+	}
+}
+
+func TestEvacuationService_IsochroneBands(t *testing.T) {
+	gh := &MockEvacuationGraphHopper{}
+	service := services.NewEvacuationService(nil, gh, &MockEvacuationZones{})
+
+	isochrone, err := service.GetIsochrone([2]float64{53.349805, -6.26031}, 15, 0, services.ModeWheelchair)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, services.ModeWheelchair, gh.mode)
+	if assert.Len(t, isochrone.Features, 3) {
+		for i, feature := range isochrone.Features {
+			assert.Equal(t, "Polygon", feature.Geometry.Type)
+			assert.Equal(t, i, feature.Properties["bucket"])
+			assert.Equal(t, float64(5*(i+1)), feature.Properties["minutes"])
+			assert.Equal(t, services.ModeWheelchair, feature.Properties["mode"])
+			assert.Equal(t, "foot", feature.Properties["profile"])
+		}
+		assert.Equal(t, "Within 15 min", isochrone.Features[2].Properties["name"])
+	}
+
+	_, err = service.GetIsochrone([2]float64{53.349805, -6.26031}, 90, 3, services.ModeFoot)
+	assert.ErrorIs(t, err, services.ErrInvalidIsochrone)
+	_, err = service.GetIsochrone([2]float64{53.349805, -6.26031}, 15, 7, services.ModeFoot)
+	assert.ErrorIs(t, err, services.ErrInvalidIsochrone)
+}
+
+func TestEvacuationService_IsochroneAvoidsActiveZones(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	zones := &MockEvacuationZones{zones: []models.DisasterZone{
+		{IncidentID: 42, Latitude: 53.349805, Longitude: -6.26031, Radius: 100},
+		{IncidentID: 7, Latitude: 53.352, Longitude: -6.26031, Radius: 50},
+	}}
+	rows := sqlmock.NewRows([]string{"ord", "geometry"})
+	for ord := 1; ord <= 3; ord++ {
+		rows.AddRow(ord, `{"type": "MultiPolygon", "coordinates": [[[[-6.2613, 53.3488], [-6.2593, 53.3488], [-6.2593, 53.3508], [-6.2613, 53.3488]]]]}`)
+	}
+	// Only the zone away from the point is cut out; the point's own zone
+	// must stay passable.
+	mock.ExpectQuery(`ST_Difference\(ST_GeomFromGeoJSON\(b\), \(SELECT geom FROM blocked\)\)`).
+		WithArgs(sqlmock.AnyArg(), hazardIncidents{7}, 53.349805, -6.26031).
+		WillReturnRows(rows)
+
+	service := services.NewEvacuationService(db, &MockEvacuationGraphHopper{}, zones)
+	isochrone, err := service.GetIsochrone([2]float64{53.349805, -6.26031}, 15, 3, services.ModeFoot)
+	if !assert.NoError(t, err) {
+		return
+	}
+	if assert.Len(t, isochrone.Features, 3) {
+		for _, feature := range isochrone.Features {
+			assert.Equal(t, "MultiPolygon", feature.Geometry.Type)
+			assert.Equal(t, []int{42}, feature.Properties["start_zones"])
+		}
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetIsochroneHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockService := &MockEvacuationService{}
+	handler := handlers.NewEvacuationHandler(mockService)
+
+	router := gin.Default()
+	router.GET("/isochrone", handler.GetIsochrone)
+
+	recorder := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/isochrone?point=53.349805,-6.26031&minutes=15&profile=bike&bands=5", nil)
+	router.ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, services.ModeBike, mockService.lastOptions.Mode)
+	assert.Equal(t, 5, mockService.lastBands)
+
+	var collection services.FeatureCollection
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &collection))
+	assert.Equal(t, "FeatureCollection", collection.Type)
+	if assert.Len(t, collection.Features, 1) {
+		assert.Equal(t, "Polygon", collection.Features[0].Geometry.Type)
+	}
+
+	for _, query := range []string{
+		"point=53.349805&minutes=15",
+		"point=53.349805,-6.26031",
+		"point=53.349805,-6.26031&minutes=15&profile=boat",
+		"point=53.349805,-6.26031&minutes=90",
+	} {
+		recorder = httptest.NewRecorder()
+		req, _ = http.NewRequest(http.MethodGet, "/isochrone?"+query, nil)
+		router.ServeHTTP(recorder, req)
+		assert.Equal(t, http.StatusBadRequest, recorder.Code, query)
+	}
+}
@@ -38,11 +38,38 @@ func (m *MockGraphHopperService) GetRoute(origin, destination string) (services.
 	}, nil
 }
 
-func (m *MockGraphHopperService) GetEvacuationRoute(dangerPoint, safePoint [2]float64) (services.EvacuationRouteResponse, error) {
+func (m *MockGraphHopperService) GetEvacuationRoute(dangerPoint, safePoint [2]float64, mode services.TravelMode, areas []services.ZoneArea) (services.EvacuationRouteResponse, error) {
 	return services.EvacuationRouteResponse{}, nil
 }
 
-func (m *MockGraphHopperService) GetSafeRoute(origin, destination string, zones []models.DisasterZone) (services.RouteResponse, error) {
+func (m *MockGraphHopperService) GetTravelMatrix(from, to [][2]float64, mode services.TravelMode, areas []services.ZoneArea) (services.TravelMatrix, error) {
+	matrix := services.TravelMatrix{Times: make([][]*float64, len(from)), Distances: make([][]*float64, len(from))}
+	for i := range from {
+		matrix.Times[i] = make([]*float64, len(to))
+		matrix.Distances[i] = make([]*float64, len(to))
+	}
+	return matrix, nil
+}
+
+// GetIsochrone returns a square around the point per bucket, growing with
+// the bucket, largest first.
+func (m *MockGraphHopperService) GetIsochrone(point [2]float64, timeLimit, buckets int, mode services.TravelMode) ([]services.Feature, error) {
+	polygons := make([]services.Feature, 0, buckets)
+	for bucket := buckets - 1; bucket >= 0; bucket-- {
+		d := 0.001 * float64(bucket+1)
+		lat, lon := point[0], point[1]
+		polygons = append(polygons, services.Feature{
+			Type: "Feature",
+			Geometry: services.GeoJSON{Type: "Polygon", Coordinates: [][][]float64{{
+				{lon - d, lat - d}, {lon + d, lat - d}, {lon + d, lat + d}, {lon - d, lat + d}, {lon - d, lat - d},
+			}}},
+			Properties: map[string]interface{}{"bucket": float64(bucket)},
+		})
+	}
+	return polygons, nil
+}
+
+func (m *MockGraphHopperService) GetSafeRoute(origin, destination string, areas []services.ZoneArea) (services.RouteResponse, error) {
 	return services.RouteResponse{
 		Hints: map[string]interface{}{"sample_hint": "safe"},
 		Info:  map[string]interface{}{"took": 3},
@@ -64,13 +91,19 @@ func (m *MockGraphHopperService) GetSafeRoute(origin, destination string, zones
 	}, nil
 }
 
-type MockDisasterZoneServiceForActive struct{}
+// MockDisasterZoneServiceForActive only overrides the zone listings; the
+// remaining service methods come from MockDisasterZoneService.
+type MockDisasterZoneServiceForActive struct {
+	MockDisasterZoneService
+	lastStatuses []int
+}
 
-func (m *MockDisasterZoneServiceForActive) GetDisasterZones() ([]models.DisasterZone, error) {
+func (m *MockDisasterZoneServiceForActive) GetDisasterZones(filter services.DisasterZoneFilter) ([]models.DisasterZone, error) {
 	return nil, nil
 }
 
-func (m *MockDisasterZoneServiceForActive) GetActiveDisasterZones() ([]models.DisasterZone, error) {
+func (m *MockDisasterZoneServiceForActive) GetActiveDisasterZones(statuses []int) ([]models.DisasterZone, error) {
+	m.lastStatuses = statuses
 	zones := []models.DisasterZone{
 		{IncidentID: 1, IncidentName: "Flood Zone", Latitude: 53.349805, Longitude: -6.26031, Radius: 30.5},
 	}
@@ -124,3 +157,116 @@ func TestGetSafeRoutingHandler_Happy(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Greater(t, len(route.Paths), 0)
 }
+
+func TestGetDefaultRouteHandler_GeoJSON(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	handler := handlers.NewRoutingHandler(&MockGraphHopperService{}, &MockDisasterZoneServiceForActive{})
+
+	router := gin.Default()
+	router.GET("/route", handler.GetDefaultRoute)
+
+	req, err := http.NewRequest(http.MethodGet, "/route?origin=53.349805,-6.26031&destination=53.3478,-6.2597", nil)
+	assert.NoError(t, err)
+	req.Header.Set("Accept", "application/geo+json")
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "application/geo+json", recorder.Header().Get("Content-Type"))
+
+	var collection services.FeatureCollection
+	err = json.Unmarshal(recorder.Body.Bytes(), &collection)
+	assert.NoError(t, err)
+	assert.Equal(t, "FeatureCollection", collection.Type)
+	assert.Len(t, collection.Features, 1)
+	assert.Equal(t, "LineString", collection.Features[0].Geometry.Type)
+	assert.NotEmpty(t, collection.Features[0].Properties["instructions"])
+}
+
+func TestGetDefaultRouteHandler_Traffic(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	handler := handlers.NewRoutingHandler(&MockGraphHopperService{}, &MockDisasterZoneServiceForActive{})
+	handler.Traffic = &MockTrafficService{}
+
+	router := gin.Default()
+	router.GET("/route", handler.GetDefaultRoute)
+
+	recorder := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/route?origin=53.349805,-6.26031&destination=53.3478,-6.2597", nil)
+	router.ServeHTTP(recorder, req)
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	var route services.RouteResponse
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &route))
+	if assert.NotNil(t, route.Traffic) {
+		// 50 km/h against a free flow of 60 takes 1.2 times as long.
+		assert.Equal(t, 720, route.Traffic.Time)
+		assert.InDelta(t, 1.2, route.Traffic.Ratio, 0.001)
+		assert.Empty(t, route.Traffic.CongestedSegments)
+	}
+
+	recorder = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/route?origin=53.349805,-6.26031&destination=53.3478,-6.2597&traffic=false", nil)
+	router.ServeHTTP(recorder, req)
+	route = services.RouteResponse{}
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &route))
+	assert.Nil(t, route.Traffic)
+
+	recorder = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/route?origin=53.349805,-6.26031&destination=53.3478,-6.2597&traffic=maybe", nil)
+	router.ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+}
+
+func TestGetSafeRoutingHandler_Avoidance(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	handler := handlers.NewRoutingHandler(&MockGraphHopperService{}, &MockDisasterZoneServiceForActive{})
+
+	router := gin.Default()
+	router.GET("/routing", handler.GetSafeRouting)
+
+	recorder := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/routing?origin=53.349805,-6.26031&destination=53.3478,-6.2597&avoidance=strict", nil)
+	router.ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+
+	var route services.RouteResponse
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &route))
+	assert.Equal(t, services.AvoidanceStrict, route.Avoidance)
+
+	recorder = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/routing?origin=53.349805,-6.26031&destination=53.3478,-6.2597&avoidance=reckless", nil)
+	router.ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+}
+
+func TestGetSafeRoutingHandler_Statuses(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockDZService := &MockDisasterZoneServiceForActive{}
+	handler := handlers.NewRoutingHandler(&MockGraphHopperService{}, mockDZService)
+
+	router := gin.Default()
+	router.GET("/routing", handler.GetSafeRouting)
+
+	recorder := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/routing?origin=53.349805,-6.26031&destination=53.3478,-6.2597", nil)
+	router.ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	var route services.RouteResponse
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &route))
+	assert.Equal(t, []int{services.StatusActive}, route.ActiveStatuses)
+
+	recorder = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/routing?origin=53.349805,-6.26031&destination=53.3478,-6.2597&statuses=3,4", nil)
+	router.ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, []int{3, 4}, mockDZService.lastStatuses)
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &route))
+	assert.Equal(t, []int{3, 4}, route.ActiveStatuses)
+
+	recorder = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/routing?origin=53.349805,-6.26031&destination=53.3478,-6.2597&statuses=active", nil)
+	router.ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+}
@@ -9,18 +9,22 @@ import (
 
 	"disaster-response-map-api/internal/handlers"
 	"disaster-response-map-api/internal/models"
+	"disaster-response-map-api/internal/services"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 )
 
-type MockSafeZoneService struct{}
+type MockSafeZoneService struct {
+	lastFilter services.SafeZoneFilter
+}
 
 func (m *MockSafeZoneService) CreateSafeZone(sz models.SafeZoneCreate) (int, error) {
 	// Just pretend we inserted row ID 42
 	return 42, nil
 }
-func (m *MockSafeZoneService) GetSafeZones() ([]models.SafeZone, error) {
+func (m *MockSafeZoneService) GetSafeZones(filter services.SafeZoneFilter) ([]models.SafeZone, error) {
+	m.lastFilter = filter
 	zones := []models.SafeZone{
 		{ZoneID: 1, ZoneName: "Flood Safe Zone", ZoneLat: 53.349805, ZoneLon: -6.26031, IncidentTypeID: 1},
 		{ZoneID: 1, ZoneName: "EarthQuake Safe Zone", ZoneLat: 53.349805, ZoneLon: -6.26031, IncidentTypeID: 1},
@@ -28,6 +32,82 @@ func (m *MockSafeZoneService) GetSafeZones() ([]models.SafeZone, error) {
 
 	return zones, nil
 }
+
+// CheckIn treats zone 2 as full and zone 3 as unknown.
+func (m *MockSafeZoneService) CheckIn(zoneID, count int) (models.SafeZone, error) {
+	switch zoneID {
+	case 2:
+		return models.SafeZone{}, services.ErrSafeZoneFull
+	case 3:
+		return models.SafeZone{}, services.ErrSafeZoneNotFound
+	}
+	capacity, remaining := 100, 100-count
+	return models.SafeZone{ZoneID: zoneID, ZoneName: "Community Hall", Capacity: &capacity, Occupancy: count, IsOpen: true, RemainingCapacity: &remaining}, nil
+}
+
+// GetSafeZone knows only zone 1, which has 10 people checked in.
+func (m *MockSafeZoneService) GetSafeZone(zoneID int) (models.SafeZone, error) {
+	if zoneID != 1 {
+		return models.SafeZone{}, services.ErrSafeZoneNotFound
+	}
+	return models.SafeZone{ZoneID: 1, ZoneName: "Community Hall", ZoneLat: 53.35, ZoneLon: -6.25, IncidentTypeID: 1, Occupancy: 10, IsOpen: true, Active: true}, nil
+}
+
+func (m *MockSafeZoneService) ReplaceSafeZone(zoneID int, sz models.SafeZoneCreate) (models.SafeZone, error) {
+	if _, err := m.GetSafeZone(zoneID); err != nil {
+		return models.SafeZone{}, err
+	}
+	if sz.Capacity != nil && *sz.Capacity < 10 {
+		return models.SafeZone{}, services.ErrSafeZoneOccupied
+	}
+	if sz.ExternalRef != nil && *sz.ExternalRef == "DCC-7" {
+		return models.SafeZone{}, services.ErrDuplicateExternalRef
+	}
+	return models.SafeZone{ZoneID: zoneID, ZoneName: sz.ZoneName, ZoneLat: sz.ZoneLat, ZoneLon: sz.ZoneLon, IncidentTypeID: sz.IncidentTypeID, Capacity: sz.Capacity, Occupancy: 10, IsOpen: true, Active: true}, nil
+}
+
+func (m *MockSafeZoneService) PatchSafeZone(zoneID int, patch models.SafeZonePatch) (models.SafeZone, error) {
+	current, err := m.GetSafeZone(zoneID)
+	if err != nil {
+		return models.SafeZone{}, err
+	}
+	return m.ReplaceSafeZone(zoneID, models.SafeZoneCreate{ZoneName: current.ZoneName, ZoneLat: current.ZoneLat, ZoneLon: current.ZoneLon, IncidentTypeID: current.IncidentTypeID, Capacity: patch.Capacity})
+}
+
+func (m *MockSafeZoneService) DeleteSafeZone(zoneID int) error {
+	if _, err := m.GetSafeZone(zoneID); err != nil {
+		return err
+	}
+	return services.ErrSafeZoneOccupied
+}
+
+func (m *MockSafeZoneService) SetSafeZoneActive(zoneID int, active bool) (models.SafeZone, error) {
+	zone, err := m.GetSafeZone(zoneID)
+	zone.Active = active
+	return zone, err
+}
+
+func (m *MockSafeZoneService) CheckOut(zoneID, count int) (models.SafeZone, error) {
+	return models.SafeZone{}, services.ErrNotEnoughOccupants
+}
+
+// ImportSafeZones pretends to create every entry that could be read.
+func (m *MockSafeZoneService) ImportSafeZones(rows []services.SafeZoneImportRow, dryRun bool) (services.SafeZoneImportReport, error) {
+	report := services.SafeZoneImportReport{DryRun: dryRun}
+	for _, row := range rows {
+		result := services.SafeZoneImportResult{Row: row.Row, ExternalRef: row.SafeZone.ExternalRef, Action: services.ImportCreated}
+		if row.Err != nil {
+			result.Action, result.Error = services.ImportRejected, row.Err.Error()
+			report.Rejected++
+		} else {
+			report.Created++
+		}
+		report.Rows = append(report.Rows, result)
+	}
+	report.Committed = !dryRun && report.Rejected == 0
+	return report, nil
+}
+
 func TestCreateSafeZone_Happy(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
@@ -53,3 +133,154 @@ func TestCreateSafeZone_Happy(t *testing.T) {
 
 	assert.Equal(t, http.StatusCreated, rec.Code)
 }
+
+func TestGetSafeZones_NearFilter(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockSvc := &MockSafeZoneService{}
+	handler := handlers.NewSafeZoneHandler(mockSvc)
+
+	router := gin.Default()
+	router.GET("/safezones", handler.GetSafeZones)
+
+	req, _ := http.NewRequest(http.MethodGet, "/safezones?near=53.3498,-6.2603&within=2500&type=1", nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, &[2]float64{53.3498, -6.2603}, mockSvc.lastFilter.Near)
+	assert.Equal(t, 2500.0, mockSvc.lastFilter.Within)
+	assert.Equal(t, 1, *mockSvc.lastFilter.IncidentTypeID)
+}
+
+func TestGetSafeZones_KMLFormat(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	handler := handlers.NewSafeZoneHandler(&MockSafeZoneService{})
+
+	router := gin.Default()
+	router.GET("/safezones", handler.GetSafeZones)
+
+	req, _ := http.NewRequest(http.MethodGet, "/safezones?format=kml", nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/vnd.google-earth.kml+xml", rec.Header().Get("Content-Type"))
+	assert.Contains(t, rec.Body.String(), "<name>Flood Safe Zone</name>")
+	assert.Contains(t, rec.Body.String(), "<coordinates>-6.26031,53.349805</coordinates>")
+}
+
+func TestCheckInSafeZone(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	handler := handlers.NewSafeZoneHandler(&MockSafeZoneService{})
+
+	router := gin.Default()
+	router.POST("/safezones/:id/check-in", handler.CheckIn)
+	router.POST("/safezones/:id/check-out", handler.CheckOut)
+
+	cases := []struct {
+		path string
+		body string
+		code int
+	}{
+		{"/safezones/1/check-in", `{"count": 4}`, http.StatusOK},
+		{"/safezones/2/check-in", `{"count": 4}`, http.StatusConflict},
+		{"/safezones/3/check-in", `{"count": 4}`, http.StatusNotFound},
+		{"/safezones/abc/check-in", `{"count": 4}`, http.StatusBadRequest},
+		{"/safezones/1/check-out", `{"count": 4}`, http.StatusConflict},
+	}
+	for _, tc := range cases {
+		req, _ := http.NewRequest(http.MethodPost, tc.path, bytes.NewBufferString(tc.body))
+		req.Header.Set("Content-Type", "application/json")
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+		assert.Equal(t, tc.code, recorder.Code, tc.path)
+	}
+
+	req, _ := http.NewRequest(http.MethodPost, "/safezones/1/check-in", bytes.NewBufferString(`{"count": 4}`))
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+	var zone models.SafeZone
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &zone))
+	assert.Equal(t, 4, zone.Occupancy)
+	assert.Equal(t, 96, *zone.RemainingCapacity)
+}
+
+func TestSafeZoneManagementHandlers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	handler := handlers.NewSafeZoneHandler(&MockSafeZoneService{})
+
+	router := gin.Default()
+	router.GET("/safezones/:id", handler.GetSafeZone)
+	router.PUT("/safezones/:id", handler.ReplaceSafeZone)
+	router.PATCH("/safezones/:id", handler.PatchSafeZone)
+	router.DELETE("/safezones/:id", handler.DeleteSafeZone)
+	router.POST("/safezones/:id/deactivate", handler.DeactivateSafeZone)
+
+	cases := []struct {
+		method, path, body string
+		code               int
+	}{
+		{http.MethodGet, "/safezones/1", "", http.StatusOK},
+		{http.MethodGet, "/safezones/9", "", http.StatusNotFound},
+		{http.MethodPut, "/safezones/1", `{"zone_name": "Hall", "zone_lat": 53.35, "zone_lon": -6.25, "incident_type_id": 1, "capacity": 50}`, http.StatusOK},
+		{http.MethodPut, "/safezones/1", `{"zone_name": "Hall", "zone_lat": 53.35, "zone_lon": -6.25, "incident_type_id": 1, "external_ref": "DCC-7"}`, http.StatusConflict},
+		{http.MethodPatch, "/safezones/1", `{"capacity": 5}`, http.StatusConflict},
+		{http.MethodPatch, "/safezones/9", `{"capacity": 5}`, http.StatusNotFound},
+		{http.MethodDelete, "/safezones/1", "", http.StatusConflict},
+		{http.MethodPost, "/safezones/1/deactivate", "", http.StatusOK},
+	}
+	for _, tc := range cases {
+		req, _ := http.NewRequest(tc.method, tc.path, bytes.NewBufferString(tc.body))
+		req.Header.Set("Content-Type", "application/json")
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+		assert.Equal(t, tc.code, recorder.Code, tc.method+" "+tc.path)
+	}
+}
+
+func TestImportSafeZonesHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	handler := handlers.NewSafeZoneHandler(&MockSafeZoneService{})
+
+	router := gin.Default()
+	router.POST("/safezones/import", handler.ImportSafeZones)
+
+	csv := "external_ref,zone_name,zone_lat,zone_lon,incident_type_id\n" +
+		"DCC-1,Sports Hall,53.35,-6.25,1\n" +
+		"DCC-2,Library,north,-6.26,1\n"
+	geojson := `{"type": "FeatureCollection", "features": [
+		{"type": "Feature", "geometry": {"type": "Point", "coordinates": [-6.25, 53.35]}, "properties": {"zone_name": "Sports Hall", "incident_type_ids": [1, 2]}}
+	]}`
+
+	cases := []struct {
+		path, contentType, body string
+		code                    int
+	}{
+		{"/safezones/import", "text/csv", csv, http.StatusUnprocessableEntity},
+		{"/safezones/import?dry_run=true", "text/csv", csv, http.StatusOK},
+		{"/safezones/import", "application/geo+json", geojson, http.StatusOK},
+		{"/safezones/import", "text/csv", "name,lat,lon\n", http.StatusBadRequest},
+		{"/safezones/import", "application/xml", "<shelters/>", http.StatusUnsupportedMediaType},
+	}
+	for _, tc := range cases {
+		req, _ := http.NewRequest(http.MethodPost, tc.path, bytes.NewBufferString(tc.body))
+		req.Header.Set("Content-Type", tc.contentType)
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+		assert.Equal(t, tc.code, recorder.Code, tc.path+" "+tc.contentType)
+	}
+
+	req, _ := http.NewRequest(http.MethodPost, "/safezones/import", bytes.NewBufferString(csv))
+	req.Header.Set("Content-Type", "text/csv")
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+	var report services.SafeZoneImportReport
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &report))
+	assert.False(t, report.Committed)
+	assert.Equal(t, 1, report.Created)
+	assert.Equal(t, 1, report.Rejected)
+	assert.Equal(t, services.ImportRejected, report.Rows[1].Action)
+	assert.Contains(t, report.Rows[1].Error, "zone_lat")
+}
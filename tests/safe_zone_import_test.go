@@ -0,0 +1,112 @@
+package tests
+
+import (
+	"strings"
+	"testing"
+
+	"disaster-response-map-api/internal/services"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSafeZoneCSV(t *testing.T) {
+	csv := "\ufeffExternal_Ref,zone_name,zone_lat,zone_lon,incident_type_ids,capacity,pets_allowed,beds,notes\n" +
+		"DCC-1,Sports Hall,53.35,-6.25,1;3,200,yes,40,gym floor\n" +
+		"DCC-2,Library,53.34,-6.26,2,,maybe,,\n" +
+		"DCC-3,Church\n"
+
+	rows, err := services.ParseSafeZoneCSV(strings.NewReader(csv))
+	assert.NoError(t, err)
+	if !assert.Len(t, rows, 3) {
+		return
+	}
+
+	hall := rows[0].SafeZone
+	assert.NoError(t, rows[0].Err)
+	assert.Equal(t, "DCC-1", *hall.ExternalRef)
+	assert.Equal(t, []int{1, 3}, hall.IncidentTypeIDs)
+	assert.Equal(t, 200, *hall.Capacity)
+	assert.True(t, hall.Facilities.PetsAllowed)
+	assert.Equal(t, 40, hall.Facilities.Beds)
+
+	assert.ErrorIs(t, rows[1].Err, services.ErrInvalidSafeZone)
+	assert.Contains(t, rows[1].Err.Error(), "pets_allowed")
+	assert.ErrorIs(t, rows[2].Err, services.ErrInvalidSafeZone)
+	assert.Equal(t, 3, rows[2].Row)
+
+	_, err = services.ParseSafeZoneCSV(strings.NewReader("name,lat,lon\n"))
+	assert.ErrorIs(t, err, services.ErrInvalidImport)
+}
+
+func TestParseSafeZoneGeoJSON(t *testing.T) {
+	geojson := `{"type": "FeatureCollection", "features": [
+		{"type": "Feature", "geometry": {"type": "Point", "coordinates": [-6.25, 53.35]},
+		 "properties": {"external_ref": "DCC-1", "zone_name": "Sports Hall", "incident_type_ids": [1, 3], "wheelchair_access": true, "OBJECTID": 7}},
+		{"type": "Feature", "geometry": {"type": "Polygon", "coordinates": []}, "properties": {"zone_name": "Park"}}
+	]}`
+
+	rows, err := services.ParseSafeZoneGeoJSON(strings.NewReader(geojson))
+	assert.NoError(t, err)
+	if !assert.Len(t, rows, 2) {
+		return
+	}
+	assert.NoError(t, rows[0].Err)
+	assert.Equal(t, 53.35, rows[0].SafeZone.ZoneLat)
+	assert.Equal(t, -6.25, rows[0].SafeZone.ZoneLon)
+	assert.True(t, rows[0].SafeZone.Facilities.WheelchairAccess)
+	assert.ErrorIs(t, rows[1].Err, services.ErrInvalidSafeZone)
+
+	_, err = services.ParseSafeZoneGeoJSON(strings.NewReader(`{"type": "Feature"}`))
+	assert.ErrorIs(t, err, services.ErrInvalidImport)
+}
+
+func TestSafeZoneService_ImportDryRun(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	rows, err := services.ParseSafeZoneCSV(strings.NewReader(
+		"external_ref,zone_name,zone_lat,zone_lon,incident_type_id,capacity\n" +
+			"DCC-1,Sports Hall,53.35,-6.25,1,200\n" +
+			"DCC-2,Library,53.34,-6.26,1,\n" +
+			"DCC-3,Church,53.33,-6.27,9,\n" +
+			"DCC-1,Sports Hall Annex,53.35,-6.25,1,\n"))
+	assert.NoError(t, err)
+
+	mock.ExpectQuery(`SELECT type_id FROM incident_type`).
+		WillReturnRows(sqlmock.NewRows([]string{"type_id"}).AddRow(1).AddRow(2))
+	mock.ExpectBegin()
+	// DCC-1 is already known and is updated.
+	mock.ExpectQuery(`SELECT zone_id FROM safe_zone WHERE external_ref = \$1 FOR UPDATE`).
+		WithArgs("DCC-1").
+		WillReturnRows(sqlmock.NewRows([]string{"zone_id"}).AddRow(5))
+	mock.ExpectQuery(`UPDATE safe_zone`).
+		WillReturnRows(sqlmock.NewRows([]string{"zone_id"}).AddRow(5))
+	mock.ExpectExec(`DELETE FROM safe_zone_incident_type`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`INSERT INTO safe_zone_incident_type`).WillReturnResult(sqlmock.NewResult(0, 0))
+	// DCC-2 is new.
+	mock.ExpectQuery(`SELECT zone_id FROM safe_zone WHERE external_ref = \$1 FOR UPDATE`).
+		WithArgs("DCC-2").
+		WillReturnRows(sqlmock.NewRows([]string{"zone_id"}))
+	mock.ExpectQuery(`INSERT INTO safe_zone`).
+		WillReturnRows(sqlmock.NewRows([]string{"zone_id"}).AddRow(12))
+	mock.ExpectExec(`DELETE FROM safe_zone_incident_type`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`INSERT INTO safe_zone_incident_type`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectRollback()
+
+	report, err := services.NewSafeZoneService(db, &MockDisasterZoneService{}).ImportSafeZones(rows, true)
+	assert.NoError(t, err)
+	assert.False(t, report.Committed)
+	assert.Equal(t, 1, report.Created)
+	assert.Equal(t, 1, report.Updated)
+	assert.Equal(t, 2, report.Rejected)
+
+	assert.Equal(t, services.ImportUpdated, report.Rows[0].Action)
+	assert.Equal(t, 5, report.Rows[0].ZoneID)
+	assert.Equal(t, services.ImportCreated, report.Rows[1].Action)
+	assert.Zero(t, report.Rows[1].ZoneID)
+	assert.Contains(t, report.Rows[2].Error, "unknown incident type 9")
+	assert.Contains(t, report.Rows[3].Error, "row 1")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
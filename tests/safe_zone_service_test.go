@@ -0,0 +1,204 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"disaster-response-map-api/internal/models"
+	"disaster-response-map-api/internal/services"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSafeZoneService_GetSafeZones_FiltersInSQL(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	incidentType := 2
+	filter := services.SafeZoneFilter{
+		SpatialFilter: services.SpatialFilter{
+			BBox: &services.BoundingBox{MinLon: -6.3, MinLat: 53.3, MaxLon: -6.2, MaxLat: 53.4},
+		},
+		IncidentTypeID: &incidentType,
+	}
+
+	rows := sqlmock.NewRows([]string{"zone_id", "zone_name", "zone_lat", "zone_lon", "incident_type_id", "capacity", "occupancy", "is_open", "active", "compromised_by", "compromised_at", "medical_staff", "wheelchair_access", "pets_allowed", "water", "power", "beds", "external_ref", "incident_type_ids"}).
+		AddRow(1, "Community Hall", 53.35, -6.25, 2, nil, 0, true, true, nil, nil, false, false, false, false, false, 0, nil, "{2}")
+	mock.ExpectQuery(`FROM safe_zone WHERE active AND ST_Intersects\(geog, ST_MakeEnvelope\(\$1, \$2, \$3, \$4, 4326\)::geography\) AND EXISTS \(SELECT 1 FROM safe_zone_incident_type t WHERE t.zone_id = safe_zone.zone_id AND t.incident_type_id = \$5\)`).
+		WithArgs(-6.3, 53.3, -6.2, 53.4, 2).
+		WillReturnRows(rows)
+
+	zones, err := services.NewSafeZoneService(db, &MockDisasterZoneService{}).GetSafeZones(filter)
+	assert.NoError(t, err)
+	assert.Len(t, zones, 1)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSafeZoneService_CheckInFull(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	columns := []string{"zone_id", "zone_name", "zone_lat", "zone_lon", "incident_type_id", "capacity", "occupancy", "is_open", "active", "compromised_by", "compromised_at", "medical_staff", "wheelchair_access", "pets_allowed", "water", "power", "beds", "external_ref", "incident_type_ids"}
+	mock.ExpectQuery(`UPDATE safe_zone SET occupancy = occupancy \+ \$2`).
+		WithArgs(1, 5).
+		WillReturnRows(sqlmock.NewRows(columns))
+	mock.ExpectQuery(`FROM safe_zone WHERE zone_id = \$1`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(1, "Community Hall", 53.35, -6.25, 2, 100, 98, true, true, nil, nil, false, false, false, false, false, 0, nil, "{2}"))
+
+	_, err = services.NewSafeZoneService(db, &MockDisasterZoneService{}).CheckIn(1, 5)
+	assert.ErrorIs(t, err, services.ErrSafeZoneFull)
+	assert.Contains(t, err.Error(), "2 places remaining")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSafeZoneService_CreateValidation(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	service := services.NewSafeZoneService(db, &MockDisasterZoneService{})
+
+	_, err = service.CreateSafeZone(models.SafeZoneCreate{ZoneName: " ", ZoneLat: 53.35, ZoneLon: -6.25, IncidentTypeID: 1})
+	assert.ErrorIs(t, err, services.ErrInvalidSafeZone)
+
+	_, err = service.CreateSafeZone(models.SafeZoneCreate{ZoneName: "Hall", ZoneLat: 153.35, ZoneLon: -6.25, IncidentTypeID: 1})
+	assert.ErrorIs(t, err, services.ErrInvalidSafeZone)
+
+	mock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM incident_type WHERE type_id = \$1\)`).
+		WithArgs(99).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	_, err = service.CreateSafeZone(models.SafeZoneCreate{ZoneName: "Hall", ZoneLat: 53.35, ZoneLon: -6.25, IncidentTypeID: 99})
+	assert.ErrorIs(t, err, services.ErrInvalidSafeZone)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSafeZoneService_CompromisedFilterUsesStoredFlag(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	// Reading must not re-check the zones; any UPDATE would be unexpected.
+	columns := []string{"zone_id", "zone_name", "zone_lat", "zone_lon", "incident_type_id", "capacity", "occupancy", "is_open", "active", "compromised_by", "compromised_at", "medical_staff", "wheelchair_access", "pets_allowed", "water", "power", "beds", "external_ref", "incident_type_ids"}
+	mock.ExpectQuery(`FROM safe_zone WHERE active AND compromised_by IS NOT NULL`).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(1, "Community Hall", 53.35, -6.25, 2, nil, 0, true, true, 17, time.Now(), false, false, false, false, false, 0, nil, "{2}"))
+
+	compromised := true
+	zones, err := services.NewSafeZoneService(db, &MockDisasterZoneService{}).GetSafeZones(services.SafeZoneFilter{Compromised: &compromised})
+	assert.NoError(t, err)
+	assert.Len(t, zones, 1)
+	assert.Equal(t, 17, *zones[0].CompromisedBy)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSafeZoneService_CreateWithSeveralIncidentTypes(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	for _, typeID := range []int{3, 1} {
+		mock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM incident_type WHERE type_id = \$1\)`).
+			WithArgs(typeID).
+			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	}
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO safe_zone`).
+		WithArgs("Sports Hall", 53.35, -6.25, 3, nil, true, false, true, false, false, false, 40, nil).
+		WillReturnRows(sqlmock.NewRows([]string{"zone_id"}).AddRow(7))
+	mock.ExpectExec(`DELETE FROM safe_zone_incident_type WHERE zone_id = \$1 AND incident_type_id <> ALL\(\$2\)`).
+		WithArgs(7, "{3,1}").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`INSERT INTO safe_zone_incident_type`).
+		WithArgs(7, "{3,1}").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+	mock.ExpectExec(`UPDATE safe_zone s\s+SET compromised_by`).
+		WithArgs(sqlmock.AnyArg(), 7).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	id, err := services.NewSafeZoneService(db, &MockDisasterZoneService{}).CreateSafeZone(models.SafeZoneCreate{
+		ZoneName:        "Sports Hall",
+		ZoneLat:         53.35,
+		ZoneLon:         -6.25,
+		IncidentTypeID:  3,
+		IncidentTypeIDs: []int{1, 3},
+		Facilities:      &models.SafeZoneFacilities{WheelchairAccess: true, Beds: 40},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 7, id)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSafeZoneService_ReplaceKeepsAndGuardsExternalRef(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	service := services.NewSafeZoneService(db, &MockDisasterZoneService{})
+	hall := models.SafeZoneCreate{ZoneName: "Hall", ZoneLat: 53.35, ZoneLon: -6.25, IncidentTypeID: 1}
+
+	// No external_ref in the payload: the stored one is kept.
+	mock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM incident_type WHERE type_id = \$1\)`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectBegin()
+	mock.ExpectQuery(`external_ref = COALESCE\(\$14, external_ref\)`).
+		WithArgs(5, "Hall", 53.35, -6.25, 1, nil, true, false, false, false, false, false, 0, nil).
+		WillReturnRows(sqlmock.NewRows([]string{"zone_id"}).AddRow(5))
+	mock.ExpectExec(`DELETE FROM safe_zone_incident_type`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`INSERT INTO safe_zone_incident_type`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+	mock.ExpectExec(`UPDATE safe_zone s\s+SET compromised_by`).WillReturnResult(sqlmock.NewResult(0, 0))
+	columns := []string{"zone_id", "zone_name", "zone_lat", "zone_lon", "incident_type_id", "capacity", "occupancy", "is_open", "active", "compromised_by", "compromised_at", "medical_staff", "wheelchair_access", "pets_allowed", "water", "power", "beds", "external_ref", "incident_type_ids"}
+	mock.ExpectQuery(`FROM safe_zone WHERE zone_id = \$1`).
+		WithArgs(5).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(5, "Hall", 53.35, -6.25, 1, nil, 0, true, true, nil, nil, false, false, false, false, false, 0, "DCC-5", "{1}"))
+
+	zone, err := service.ReplaceSafeZone(5, hall)
+	if assert.NoError(t, err) && assert.NotNil(t, zone.ExternalRef) {
+		assert.Equal(t, "DCC-5", *zone.ExternalRef)
+	}
+
+	// Another zone's external_ref is a conflict, not a server error.
+	taken := "DCC-7"
+	hall.ExternalRef = &taken
+	mock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM incident_type WHERE type_id = \$1\)`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectBegin()
+	mock.ExpectQuery(`UPDATE safe_zone`).
+		WillReturnError(&pq.Error{Code: "23505", Constraint: "safe_zone_external_ref_idx"})
+	mock.ExpectRollback()
+
+	_, err = service.ReplaceSafeZone(5, hall)
+	assert.ErrorIs(t, err, services.ErrDuplicateExternalRef)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSafeZoneService_RefreshCompromisedEveryStopsWithContext(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectExec(`UPDATE safe_zone s\s+SET compromised_by = hit.incident_id`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	service := services.NewSafeZoneService(db, &MockDisasterZoneService{})
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		service.RefreshCompromisedEvery(ctx, 10*time.Millisecond)
+		close(stopped)
+	}()
+
+	assert.Eventually(t, func() bool { return mock.ExpectationsWereMet() == nil }, time.Second, 5*time.Millisecond)
+	cancel()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("refresh kept running after its context was cancelled")
+	}
+}
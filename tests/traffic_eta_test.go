@@ -0,0 +1,126 @@
+package tests
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+
+	"disaster-response-map-api/internal/services"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// MockFlowTraffic reports free-flow traffic at 50 km/h south of slowNorthOf
+// and 20 km/h north of it, or no data there when failNorth is set. Every
+// lookup is counted.
+type MockFlowTraffic struct {
+	slowNorthOf float64
+	failNorth   bool
+	mu          sync.Mutex
+	lookups     int
+}
+
+func (m *MockFlowTraffic) GetTrafficData(lat, lon string) ([]byte, error) {
+	m.mu.Lock()
+	m.lookups++
+	m.mu.Unlock()
+	latitude, err := strconv.ParseFloat(lat, 64)
+	if err != nil {
+		return nil, err
+	}
+	speed := 50.0
+	if latitude > m.slowNorthOf {
+		if m.failNorth {
+			return nil, errors.New("TomTom API error: 400 Bad Request")
+		}
+		speed = 20.0
+	}
+	return tomTomFlow(lat, lon, speed, 50), nil
+}
+
+// tomTomFlow returns a flow segment response shaped like TomTom's, with the
+// segment's coordinates as an object and a fractional confidence.
+func tomTomFlow(lat, lon string, currentSpeed, freeFlowSpeed float64) []byte {
+	return []byte(`{"flowSegmentData": {
+		"frc": "FRC2",
+		"currentSpeed": ` + strconv.FormatFloat(currentSpeed, 'f', -1, 64) + `,
+		"freeFlowSpeed": ` + strconv.FormatFloat(freeFlowSpeed, 'f', -1, 64) + `,
+		"currentTravelTime": 94,
+		"freeFlowTravelTime": 38,
+		"confidence": 0.95,
+		"roadClosure": false,
+		"coordinates": {"coordinate": [
+			{"latitude": ` + lat + `, "longitude": ` + lon + `},
+			{"latitude": ` + lat + `, "longitude": -6.255}
+		]},
+		"@version": "traffic-service-flow 1.0.120"
+	}}`)
+}
+
+// northboundPath runs straight north for about 4.4 km in ten minutes.
+func northboundPath() services.RoutePath {
+	return services.RoutePath{
+		Distance: 4448,
+		Time:     600000,
+		Points: services.GeoJSON{Type: "LineString", Coordinates: [][]float64{
+			{-6.26, 53.30},
+			{-6.26, 53.32},
+			{-6.26, 53.34},
+		}},
+	}
+}
+
+func TestEstimateTrafficETA_ScalesCongestedStretches(t *testing.T) {
+	traffic := &MockFlowTraffic{slowNorthOf: 53.32}
+	eta, err := services.EstimateTrafficETA(traffic, northboundPath())
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, 8, eta.Samples)
+	assert.Equal(t, 8, traffic.lookups)
+	assert.Equal(t, 0, eta.Missing)
+	assert.Equal(t, 600000, eta.FreeFlowTime)
+	// Half the route at free flow, half at 2.5 times as long.
+	assert.InDelta(t, 1050000, eta.Time, 1)
+	assert.InDelta(t, 1.75, eta.Ratio, 0.001)
+	assert.False(t, eta.RoadClosed)
+	if assert.Len(t, eta.CongestedSegments, 4) {
+		segment := eta.CongestedSegments[0]
+		assert.InDelta(t, 2.5, segment.Ratio, 0.001)
+		assert.Equal(t, 20.0, segment.CurrentSpeed)
+		assert.InDelta(t, 556, segment.Distance, 1)
+		assert.InDelta(t, 53.32, segment.Coordinates[0][1], 0.0001)
+	}
+}
+
+func TestEstimateTrafficETA_MissingData(t *testing.T) {
+	eta, err := services.EstimateTrafficETA(&MockFlowTraffic{slowNorthOf: 53.32, failNorth: true}, northboundPath())
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, 4, eta.Missing)
+	assert.Equal(t, 600000, eta.Time)
+	assert.Empty(t, eta.CongestedSegments)
+
+	_, err = services.EstimateTrafficETA(&MockFlowTraffic{slowNorthOf: 0, failNorth: true}, northboundPath())
+	assert.Error(t, err)
+}
+
+func TestEvacuationService_CarRouteCarriesTraffic(t *testing.T) {
+	service := services.NewEvacuationService(nil, &MockEvacuationGraphHopper{}, &MockDisasterZoneService{})
+	service.Traffic = &MockFlowTraffic{slowNorthOf: 90}
+	safePoint := [2]float64{53.36, -6.25}
+
+	route, err := service.GetEvacuationRoute([2]float64{53.349805, -6.26031}, 2, &safePoint, services.EvacuationOptions{Mode: services.ModeCar})
+	if !assert.NoError(t, err) || !assert.NotNil(t, route.Traffic) {
+		return
+	}
+	assert.Equal(t, route.Paths[0].Time, route.Traffic.Time)
+	assert.InDelta(t, 1, route.Traffic.Ratio, 0.001)
+
+	route, err = service.GetEvacuationRoute([2]float64{53.349805, -6.26031}, 2, &safePoint, services.EvacuationOptions{Mode: services.ModeFoot})
+	assert.NoError(t, err)
+	assert.Nil(t, route.Traffic)
+}
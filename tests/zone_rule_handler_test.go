@@ -0,0 +1,79 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"disaster-response-map-api/internal/handlers"
+	"disaster-response-map-api/internal/models"
+	"disaster-response-map-api/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+type MockZoneRuleService struct {
+	rules []models.ZoneRule
+}
+
+func (m *MockZoneRuleService) GetZoneRules() ([]models.ZoneRule, error) {
+	return m.rules, nil
+}
+
+func (m *MockZoneRuleService) UpsertZoneRule(rule models.ZoneRule) (models.ZoneRule, error) {
+	if err := services.ValidateZoneRule(rule); err != nil {
+		return models.ZoneRule{}, err
+	}
+	rule.RuleID = len(m.rules) + 1
+	m.rules = append(m.rules, rule)
+	return rule, nil
+}
+
+func (m *MockZoneRuleService) DeleteZoneRule(ruleID int) error {
+	return services.ErrZoneRuleNotFound
+}
+
+func TestUpsertZoneRuleHandler_Happy(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	handler := handlers.NewZoneRuleHandler(&MockZoneRuleService{})
+
+	router := gin.Default()
+	router.PUT("/admin/zone-rules", handler.UpsertZoneRule)
+
+	payload := []byte(`{"type_id": 4, "severity_id": 3, "radius": 500, "buffer": 100, "avoidance": 1}`)
+	req, err := http.NewRequest(http.MethodPut, "/admin/zone-rules", bytes.NewBuffer(payload))
+	assert.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+
+	var rule models.ZoneRule
+	err = json.Unmarshal(recorder.Body.Bytes(), &rule)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, rule.RuleID)
+	assert.Equal(t, 500.0, rule.Radius)
+}
+
+func TestUpsertZoneRuleHandler_InvalidAvoidance(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	handler := handlers.NewZoneRuleHandler(&MockZoneRuleService{})
+
+	router := gin.Default()
+	router.PUT("/admin/zone-rules", handler.UpsertZoneRule)
+
+	payload := []byte(`{"severity_id": 3, "radius": 500, "avoidance": 2}`)
+	req, err := http.NewRequest(http.MethodPut, "/admin/zone-rules", bytes.NewBuffer(payload))
+	assert.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+}